@@ -82,7 +82,7 @@ func ReadFile(filename string) ([]byte, error) {
 	return io.ReadAll(f)
 }
 
-/* 处理互斥锁也可以用defer */
+/* 处理互斥锁也可以用defer。多个goroutine频繁访问时，这把锁会成为瓶颈，分片的并发map见98-topic/06-struct/empty-struct/set/concurrent。 */
 var mu sync.Mutex
 var m = make(map[string]int)
 
@@ -35,6 +35,7 @@ func fib(x int) int {
 	return fib(x-1) + fib(x-2)
 }
 
+// fib在多个goroutine和不同GOMAXPROCS下的加速比见98-topic/02-GMP/scaling。
 func calc_fib() {
 	// spinner和fib并发执行
 	go spinner(100 * time.Millisecond)
@@ -18,6 +18,7 @@ import (
 */
 
 // 这个程序可能还存在问题
+// 用可以被取消的信号量实现的完整版本见du目录。
 
 var sema = make(chan struct{}, 20)
 var done = make(chan struct{})
@@ -50,8 +51,9 @@ func canceled() bool {
 }
 
 // 增加信号量控制并发
+// 获取信号量时也要检查done，否则取消之后等待信号量的goroutine会一直阻塞。
+// 每次只能获取一个信号量：获取两次的话，20个goroutine各持有一个之后谁也拿不到第二个，遍历就死锁了。
 func dirents2(dir string) []os.FileInfo {
-	sema <- struct{}{}
 	select {
 	case sema <- struct{}{}:
 	case <-done:
@@ -0,0 +1,34 @@
+// Package abort 提供countdown和du共用的“按回车键中止”。
+//
+// 06-multiplexing_select.go中的rocket_countdown2用一个goroutine读取标准输入，
+// 读到一个字节就向abort发送一个值；倒计时结束后没有接收方，这个goroutine会永远阻塞在发送上。
+// OnInput改为关闭channel，并且只在读到一整行时中止：标准输入被关闭（EOF）不算中止，
+// 所以`cmd </dev/null`这样的运行结果是确定的。
+package abort
+
+import (
+	"context"
+	"io"
+)
+
+// OnInput 启动一个goroutine从r读取一行输入，读到输入时关闭返回的channel。
+// 读到EOF或出错时不会关闭它。
+// 用关闭channel代替发送事件，所以没有接收方时这个goroutine也不会阻塞。
+// 对标准输入的Read无法被打断，但读取结束或者ctx被取消后goroutine就会退出。
+func OnInput(ctx context.Context, r io.Reader) <-chan struct{} {
+	abort := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 && buf[0] == '\n' {
+				close(abort)
+				return
+			}
+			if err != nil || ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return abort
+}
@@ -0,0 +1,44 @@
+package abort
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnInput(t *testing.T) {
+	select {
+	case <-OnInput(context.Background(), strings.NewReader("go\n")):
+	case <-time.After(5 * time.Second):
+		t.Fatal("no abort after a line of input")
+	}
+}
+
+func TestEOFDoesNotAbort(t *testing.T) {
+	for _, input := range []string{"", "no newline"} {
+		r := &eofReader{r: strings.NewReader(input), eof: make(chan struct{})}
+		abort := OnInput(context.Background(), r)
+		<-r.eof
+		select {
+		case <-abort:
+			t.Errorf("input %q: aborted at EOF", input)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// eofReader 在读到EOF时关闭eof。
+type eofReader struct {
+	r   io.Reader
+	eof chan struct{}
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		close(r.eof)
+	}
+	return n, err
+}
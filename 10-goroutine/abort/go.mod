@@ -0,0 +1,3 @@
+module abort
+
+go 1.19
@@ -0,0 +1,7 @@
+module clock
+
+go 1.19
+
+require script v0.0.0
+
+replace script => ../../12-testing/script
@@ -0,0 +1,75 @@
+// Package clock 用脚本测试章节中的clock服务器02-example_clock1.go和03-example_clock2.go。
+//
+// 两个服务器都监听localhost:8000，所以它们在同一个脚本中依次运行。
+package clock
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"script"
+)
+
+// cmds 是TestMain编译的clock1和clock2。
+var cmds map[string]string
+
+// 设置了CLOCK_NETCAT时，测试程序本身作为脚本中的netcat命令运行：
+//
+//	netcat addr n   连接addr，把收到的前n行输出到标准输出后断开
+func TestMain(m *testing.M) {
+	if os.Getenv("CLOCK_NETCAT") == "1" {
+		os.Exit(netcat(os.Args[1:]))
+	}
+	script.Main(m, &cmds, map[string]string{
+		"clock1": "../02-example_clock1.go",
+		"clock2": "../03-example_clock2.go",
+	})
+}
+
+// netcat 是netcat命令。服务器在后台启动，可能还没有开始监听，所以连接失败时在5秒内重试。
+func netcat(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: netcat addr n")
+		return 2
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var conn net.Conn
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		conn, err = net.Dial("tcp", args[0])
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	in := bufio.NewScanner(conn)
+	for i := 0; i < n; i++ {
+		if !in.Scan() {
+			fmt.Fprintf(os.Stderr, "got %d lines, want %d: %v\n", i, n, in.Err())
+			return 1
+		}
+		fmt.Println(in.Text())
+	}
+	return 0
+}
+
+// TestScripts 在testdata/script中的脚本里运行clock服务器，用netcat连接它们。
+func TestScripts(t *testing.T) {
+	p := script.Params{Dir: "testdata/script", Cmds: map[string]string{"netcat": os.Args[0]}, Env: []string{"CLOCK_NETCAT=1"}}
+	for name, bin := range cmds {
+		p.Cmds[name] = bin
+	}
+	script.Run(t, p)
+}
@@ -0,0 +1,28 @@
+package main
+
+import "time"
+
+// clock 抽象了倒计时用到的时间源，测试时用一个假的时钟替换它，这样测试不需要真的等待。
+type clock interface {
+	NewTicker(d time.Duration) ticker
+}
+
+// ticker 对应time.Ticker。
+type ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// realClock 使用time包实现clock。
+type realClock struct{}
+
+func (realClock) NewTicker(d time.Duration) ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time   { return t.t.C }
+func (t realTicker) Reset(d time.Duration) { t.t.Reset(d) }
+func (t realTicker) Stop()                 { t.t.Stop() }
@@ -0,0 +1,15 @@
+module countdown
+
+go 1.19
+
+require (
+	abort v0.0.0
+	leakcheck v0.0.0
+	script v0.0.0
+)
+
+replace (
+	abort => ../abort
+	leakcheck => ../leakcheck
+	script => ../../12-testing/script
+)
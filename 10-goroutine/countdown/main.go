@@ -0,0 +1,99 @@
+// Countdown 进行火箭发射倒计时，倒计时结束后可以执行一个命令。
+//
+// 它是06-multiplexing_select.go中rocket_countdown1/2/3的完整版本：
+//   - 倒计时的时长和间隔可以配置；
+//   - 按下回车键、收到SIGINT或SIGTERM时中止发射；
+//   - 读取标准输入的goroutine在倒计时结束后不会因为发送abort事件而永远阻塞；
+//   - 退出码可以区分发射成功（0）、中止发射（3）和出错（1）。
+//
+// 用法：
+//
+//	$ countdown -d 10s -i 1s [command [args...]]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"abort"
+)
+
+// 退出码
+const (
+	exitLaunched = 0
+	exitError    = 1
+	exitAborted  = 3
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, realClock{}))
+}
+
+// run 解析命令行参数并进行倒计时，返回进程的退出码。
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, clk clock) int {
+	flags := flag.NewFlagSet("countdown", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	duration := flags.Duration("d", 10*time.Second, "countdown duration")
+	interval := flags.Duration("i", 1*time.Second, "tick interval")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *duration < 0 || *interval <= 0 {
+		fmt.Fprintln(stderr, "countdown: duration must be >= 0 and interval > 0")
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintln(stdout, "Commencing countdown. Press return to abort.")
+	if !countdown(ctx, clk, *duration, *interval, abort.OnInput(ctx, stdin), stdout) {
+		fmt.Fprintln(stdout, "Launch aborted.")
+		return exitAborted
+	}
+	fmt.Fprintln(stdout, "Liftoff!")
+
+	if hook := flags.Args(); len(hook) > 0 {
+		cmd := exec.CommandContext(ctx, hook[0], hook[1:]...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(stderr, "countdown: %s: %v\n", hook[0], err)
+			return exitError
+		}
+	}
+	return exitLaunched
+}
+
+// countdown 每隔interval打印一次剩余时间，直到剩余时间为0。
+// 如果倒计时完成则返回true；如果abort收到事件或ctx被取消则返回false。
+func countdown(ctx context.Context, clk clock, duration, interval time.Duration,
+	abort <-chan struct{}, out io.Writer) bool {
+	tick := clk.NewTicker(interval)
+	defer tick.Stop() // 与time.Tick不同，Ticker停止以后不会再有goroutine泄露
+
+	for remaining := duration; remaining > 0; {
+		fmt.Fprintf(out, "T-%v\n", remaining)
+		step := interval
+		if remaining < step {
+			step = remaining
+			tick.Reset(step) // 最后一次只等待剩余的时间，总时长不会超过duration
+		}
+		select {
+		case <-tick.C():
+		case <-abort:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+		remaining -= step
+	}
+	return true
+}
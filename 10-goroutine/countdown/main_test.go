@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"leakcheck"
+	"script"
+)
+
+// fakeClock 创建的ticker只在测试调用tick时才触发。
+type fakeClock struct {
+	tickers chan *fakeTicker
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{tickers: make(chan *fakeTicker, 1)}
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	t := &fakeTicker{c: make(chan time.Time), stopped: make(chan struct{})}
+	c.tickers <- t
+	return t
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	stopped chan struct{}
+
+	mu     sync.Mutex
+	resets []time.Duration // Reset的参数
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+func (t *fakeTicker) Stop()               { close(t.stopped) }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resets = append(t.resets, d)
+}
+
+// tick 触发一次ticker，在倒计时收到事件后返回。
+func (t *fakeTicker) tick() {
+	select {
+	case t.c <- time.Now():
+	case <-t.stopped:
+	}
+}
+
+// start 在一个新的goroutine中运行run，返回退出码的channel和运行时使用的ticker。
+func start(t *testing.T, args []string, stdin io.Reader, stdout, stderr io.Writer) (<-chan int, *fakeTicker) {
+	t.Helper()
+	clk := newFakeClock()
+	code := make(chan int, 1)
+	go func() { code <- run(args, stdin, stdout, stderr, clk) }()
+	select {
+	case tk := <-clk.tickers:
+		return code, tk
+	case c := <-code:
+		t.Fatalf("run(%q) exited with %d before starting the countdown", args, c)
+	}
+	panic("unreachable")
+}
+
+func TestLaunch(t *testing.T) {
+	leakcheck.Check(t)
+	var stdout, stderr bytes.Buffer
+	code, tk := start(t, []string{"-d", "3s", "-i", "1s"}, strings.NewReader(""), &stdout, &stderr)
+	for i := 0; i < 3; i++ {
+		tk.tick()
+	}
+	if c := <-code; c != exitLaunched {
+		t.Fatalf("exit code = %d, want %d; stderr: %s", c, exitLaunched, stderr.String())
+	}
+	want := "Commencing countdown. Press return to abort.\nT-3s\nT-2s\nT-1s\nLiftoff!\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+// TestPartialInterval 检查duration不是interval的整数倍时，最后一次只等待剩余的时间。
+func TestPartialInterval(t *testing.T) {
+	leakcheck.Check(t)
+	var stdout, stderr bytes.Buffer
+	code, tk := start(t, []string{"-d", "5s", "-i", "2s"}, strings.NewReader(""), &stdout, &stderr)
+	for i := 0; i < 3; i++ {
+		tk.tick()
+	}
+	if c := <-code; c != exitLaunched {
+		t.Fatalf("exit code = %d, want %d; stderr: %s", c, exitLaunched, stderr.String())
+	}
+	want := "Commencing countdown. Press return to abort.\nT-5s\nT-3s\nT-1s\nLiftoff!\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	if len(tk.resets) != 1 || tk.resets[0] != time.Second {
+		t.Errorf("ticker resets = %v, want [1s]", tk.resets)
+	}
+}
+
+func TestAbortOnEnter(t *testing.T) {
+	leakcheck.Check(t)
+	var stdout, stderr bytes.Buffer
+	r, w := io.Pipe()
+	defer w.Close()
+	code, tk := start(t, []string{"-d", "10s"}, r, &stdout, &stderr)
+	tk.tick()
+	fmt.Fprintln(w)
+	if c := <-code; c != exitAborted {
+		t.Fatalf("exit code = %d, want %d", c, exitAborted)
+	}
+	if got := stdout.String(); !strings.HasSuffix(got, "T-9s\nLaunch aborted.\n") {
+		t.Errorf("output = %q, want abort after T-9s", got)
+	}
+}
+
+func TestAbortOnSignal(t *testing.T) {
+	leakcheck.Check(t)
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		var stdout, stderr bytes.Buffer
+		r, w := io.Pipe()
+		code, tk := start(t, []string{"-d", "10s"}, r, &stdout, &stderr)
+		tk.tick()
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+		if c := <-code; c != exitAborted {
+			t.Errorf("%v: exit code = %d, want %d", sig, c, exitAborted)
+		}
+		w.Close()
+	}
+}
+
+func TestHook(t *testing.T) {
+	leakcheck.Check(t)
+	t.Setenv("COUNTDOWN_HELPER", "1")
+	for _, test := range []struct {
+		status int
+		want   int
+	}{
+		{0, exitLaunched},
+		{7, exitError},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := []string{"-d", "1s", os.Args[0], "-test.run=^TestHelperHook$", "--", fmt.Sprint(test.status)}
+		code, tk := start(t, args, strings.NewReader(""), &stdout, &stderr)
+		tk.tick()
+		if c := <-code; c != test.want {
+			t.Errorf("hook exiting with %d: exit code = %d, want %d", test.status, c, test.want)
+		}
+		if !strings.Contains(stdout.String(), "Liftoff!\nhook ran\n") {
+			t.Errorf("hook output missing: %q", stdout.String())
+		}
+	}
+}
+
+// TestHelperHook 不是真正的测试，TestHook把它当作倒计时结束后执行的命令。
+func TestHelperHook(t *testing.T) {
+	if os.Getenv("COUNTDOWN_HELPER") != "1" {
+		return
+	}
+	fmt.Println("hook ran")
+	var status int
+	fmt.Sscan(os.Args[len(os.Args)-1], &status)
+	os.Exit(status)
+}
+
+func TestBadFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-d", "soon"},
+		{"-i", "0s"},
+		{"-d", "-1s"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if c := run(args, strings.NewReader(""), &stdout, &stderr, newFakeClock()); c != exitError {
+			t.Errorf("run(%q) = %d, want %d", args, c, exitError)
+		}
+	}
+}
+
+// TestStdinReader 检查倒计时结束后，读取标准输入的goroutine不会像rocket_countdown2那样
+// 阻塞在发送abort上，输入关闭后就会退出。关闭输入的cleanup在Check之后注册，会先执行。
+func TestStdinReader(t *testing.T) {
+	leakcheck.Check(t)
+	r, w := io.Pipe()
+	t.Cleanup(func() { w.Close() })
+	code, tk := start(t, []string{"-d", "1s"}, r, io.Discard, io.Discard)
+	tk.tick()
+	if c := <-code; c != exitLaunched {
+		t.Fatalf("exit code = %d, want %d", c, exitLaunched)
+	}
+}
+
+// cmds 是TestMain编译的countdown。
+var cmds map[string]string
+
+func TestMain(m *testing.M) {
+	script.Main(m, &cmds, map[string]string{"countdown": "."})
+}
+
+// TestScripts 用真实的时钟运行countdown，检查输出和退出码。
+func TestScripts(t *testing.T) {
+	script.Run(t, script.Params{Dir: "testdata/script", Cmds: cmds})
+}
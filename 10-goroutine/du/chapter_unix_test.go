@@ -0,0 +1,61 @@
+//go:build unix
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestChapterAbort 检查du5读到输入时只打印"user abort"。
+//
+// 目录树再小，遍历也可能在du5读到输入之前结束，所以根目录是一个命名管道：
+// 打开它会一直阻塞到有写入方，遍历在中止之前不可能结束。看到"user abort"之后，
+// 测试以读写方式打开管道，让阻塞的Open返回，du5才能排空fileSizes并退出。
+func TestChapterAbort(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Skip(err)
+	}
+	cmd := exec.Command(cmds["du5"], fifo)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	if _, err := io.WriteString(stdin, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	out := bufio.NewReader(stdout)
+	line, err := out.ReadString('\n')
+	if line != "user abort\n" {
+		t.Fatalf("du5 printed %q, %v; want \"user abort\\n\"", line, err)
+	}
+
+	// 以读写方式打开命名管道不会阻塞，du5中阻塞的Open随之返回。
+	f, err := os.OpenFile(fifo, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rest, err := io.ReadAll(out)
+	if err != nil || len(rest) != 0 {
+		t.Errorf("du5 printed %q after aborting (%v)", rest, err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("du5: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+module du
+
+go 1.19
+
+require (
+	abort v0.0.0
+	leakcheck v0.0.0
+	script v0.0.0
+	semaphore v0.0.0
+)
+
+replace (
+	abort => ../abort
+	leakcheck => ../leakcheck
+	script => ../../12-testing/script
+	semaphore => ../../98-topic/06-struct/empty-struct/semaphore
+)
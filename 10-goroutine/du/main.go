@@ -0,0 +1,184 @@
+// Du 统计目录中文件的数量和总大小。
+//
+// 它是06-multiplexing_select.go和07-cancellation.go中du的完整版本：
+//   - 每个子目录由一个goroutine遍历，同时读取目录的goroutine数量由信号量限制，
+//     dirents2中的sema换成了可以被取消的semaphore.Weighted；
+//   - 按下回车键、收到SIGINT或SIGTERM时取消遍历，等待读取目录的goroutine不会继续阻塞；
+//   - 退出码可以区分完成（0）、出错（1）和被取消（3）。
+//
+// 用法：
+//
+//	$ du [-v] [-j 20] [dir...]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"abort"
+	"semaphore"
+)
+
+// 退出码
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitAborted = 3
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 解析命令行参数并统计目录的大小，返回进程的退出码。
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("du", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	verbose := flags.Bool("v", false, "show verbose progress messages")
+	jobs := flags.Int64("j", 20, "maximum number of directories read concurrently")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *jobs <= 0 {
+		fmt.Fprintln(stderr, "du: -j must be positive")
+		return exitError
+	}
+	roots := flags.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-abort.OnInput(ctx, stdin):
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	w := &walker{sema: semaphore.NewWeighted(*jobs), stderr: stderr}
+	fileSizes := w.walk(ctx, roots)
+
+	var tick <-chan time.Time
+	if *verbose {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var nfiles, nbytes int64
+loop:
+	for {
+		select {
+		case size, ok := <-fileSizes:
+			if !ok {
+				break loop
+			}
+			nfiles++
+			nbytes += size
+		case <-tick:
+			printDiskUsage(stdout, nfiles, nbytes)
+		}
+	}
+	printDiskUsage(stdout, nfiles, nbytes)
+
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintln(stderr, "du: aborted")
+		return exitAborted
+	case w.failed():
+		return exitError
+	}
+	return exitOK
+}
+
+func printDiskUsage(w io.Writer, nfiles, nbytes int64) {
+	fmt.Fprintf(w, "%d files %.1f MB\n", nfiles, float64(nbytes)/1e6)
+}
+
+// walker 并发地遍历文件树。
+type walker struct {
+	sema   *semaphore.Weighted // 限制同时读取目录的goroutine数量
+	stderr io.Writer
+
+	mu     sync.Mutex // 保护stderr和errors
+	errors int
+}
+
+// walk 遍历roots下的所有文件，把每个文件的大小发送到返回的channel。
+// 遍历结束或者ctx被取消之后，channel会被关闭。
+func (w *walker) walk(ctx context.Context, roots []string) <-chan int64 {
+	fileSizes := make(chan int64)
+	var n sync.WaitGroup
+	for _, root := range roots {
+		n.Add(1)
+		go w.walkDir(ctx, root, &n, fileSizes)
+	}
+	go func() {
+		n.Wait()
+		close(fileSizes)
+	}()
+	return fileSizes
+}
+
+// walkDir 递归遍历dir，每个子目录由一个新的goroutine遍历。
+func (w *walker) walkDir(ctx context.Context, dir string, n *sync.WaitGroup, fileSizes chan<- int64) {
+	defer n.Done()
+	for _, entry := range w.dirents(ctx, dir) {
+		if entry.IsDir() {
+			n.Add(1)
+			go w.walkDir(ctx, filepath.Join(dir, entry.Name()), n, fileSizes)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			w.errorf("%v", err)
+			continue
+		}
+		select {
+		case fileSizes <- info.Size():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dirents 返回目录下的条目。它先获取信号量，所以同时读取目录的goroutine不超过信号量的容量；
+// ctx被取消时，等待信号量的goroutine立即返回nil。
+func (w *walker) dirents(ctx context.Context, dir string) []os.DirEntry {
+	if err := w.sema.Acquire(ctx, 1); err != nil {
+		return nil
+	}
+	defer w.sema.Release(1)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.errorf("%v", err)
+	}
+	return entries
+}
+
+func (w *walker) errorf(format string, args ...any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errors++
+	fmt.Fprintf(w.stderr, "du: "+format+"\n", args...)
+}
+
+func (w *walker) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.errors > 0
+}
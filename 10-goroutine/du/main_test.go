@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"leakcheck"
+	"script"
+	"semaphore"
+)
+
+// cmds 是TestMain编译的du和章节中的du4、du5。
+var cmds map[string]string
+
+func TestMain(m *testing.M) {
+	script.Main(m, &cmds, map[string]string{
+		"du":  ".",
+		"du4": "../06-multiplexing_select.go",
+		"du5": "../07-cancellation.go",
+	})
+}
+
+// TestScripts 在testdata/script中的目录树上运行各个版本的du。
+func TestScripts(t *testing.T) {
+	script.Run(t, script.Params{Dir: "testdata/script", Cmds: cmds})
+}
+
+// makeTree 在一个临时目录中创建depth层、每层fanout个子目录的目录树，
+// 每个目录中有一个size字节的文件。返回根目录和文件数。
+func makeTree(t *testing.T, depth, fanout, size int) (string, int) {
+	t.Helper()
+	root := t.TempDir()
+	files := 0
+	var mk func(dir string, depth int)
+	mk = func(dir string, depth int) {
+		if err := os.WriteFile(filepath.Join(dir, "file"), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files++
+		if depth == 0 {
+			return
+		}
+		for i := 0; i < fanout; i++ {
+			sub := filepath.Join(dir, fmt.Sprintf("d%d", i))
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			mk(sub, depth-1)
+		}
+	}
+	mk(root, depth)
+	return root, files
+}
+
+func TestDu(t *testing.T) {
+	leakcheck.Check(t)
+	root, files := makeTree(t, 3, 3, 1000)
+	for _, jobs := range []string{"1", "4", "20"} {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-j", jobs, root}, strings.NewReader(""), &stdout, &stderr)
+		if code != exitOK {
+			t.Fatalf("-j %s: exit %d, stderr %q", jobs, code, stderr.String())
+		}
+		want := fmt.Sprintf("%d files %.1f MB\n", files, float64(files*1000)/1e6)
+		if stdout.String() != want {
+			t.Errorf("-j %s: output %q, want %q", jobs, stdout.String(), want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	leakcheck.Check(t)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{filepath.Join(t.TempDir(), "missing")}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Errorf("missing dir: exit %d, want %d", code, exitError)
+	}
+	if !strings.Contains(stderr.String(), "no such file or directory") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	if code := run([]string{"-j", "0"}, strings.NewReader(""), io.Discard, io.Discard); code != exitError {
+		t.Errorf("-j 0: exit %d, want %d", code, exitError)
+	}
+}
+
+// TestCancel 检查遍历被取消时，等待信号量的goroutine会退出，结果channel会被关闭。
+func TestCancel(t *testing.T) {
+	leakcheck.Check(t) // 被取消的walkDir都要退出，不能阻塞在信号量或者发送上
+	root, _ := makeTree(t, 2, 3, 1)
+	w := &walker{sema: semaphore.NewWeighted(2), stderr: io.Discard}
+	// 占住所有许可，使所有walkDir都阻塞在dirents中。
+	if !w.sema.TryAcquire(2) {
+		t.Fatal("TryAcquire failed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	fileSizes := w.walk(ctx, []string{root, root, root})
+	cancel()
+	select {
+	case _, ok := <-fileSizes:
+		if ok {
+			t.Error("got a file size although no directory could be read")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("walk did not finish after cancel")
+	}
+}
+
+// TestStdinReader 检查读取标准输入的goroutine在输入关闭后退出。
+// 对输入的Read无法被打断，所以遍历结束时它仍然阻塞在Read上，这是预期的；
+// 关闭输入的cleanup在Check之后注册，会先于Check的检查执行。
+func TestStdinReader(t *testing.T) {
+	leakcheck.Check(t)
+	root, _ := makeTree(t, 1, 2, 10)
+	r, w := io.Pipe()
+	t.Cleanup(func() { w.Close() })
+	if code := run([]string{root}, r, io.Discard, io.Discard); code != exitOK {
+		t.Errorf("exit %d, want %d", code, exitOK)
+	}
+}
@@ -0,0 +1,119 @@
+package leakcheck
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// 下面是10-goroutine中几个会泄露goroutine的例子。章节中的文件都是独立的main程序，
+// 不能被导入，所以这里复制了它们的核心逻辑，去掉了打印和睡眠。
+// 泄露的goroutine会一直阻塞到测试进程退出，这正是要演示的问题。
+
+// sendAbort 是04-channel.go中的chan_send_abort：发送方要发5个值，接收方只接收3个。
+func sendAbort() {
+	a := make(chan int)
+	go func(c chan int) {
+		for i := 0; i < 5; i++ {
+			c <- i
+		}
+	}(a)
+	for i := 0; i < 3; i++ {
+		<-a
+	}
+}
+
+var errBadImage = errors.New("bad image")
+
+func imageFile(f string) (string, error) {
+	if strings.HasPrefix(f, "bad") {
+		return "", errBadImage
+	}
+	return f + ".thumb", nil
+}
+
+// makeThumbnails4 与05-loop_in_paralel.go中的相同：遇到第一个错误就返回，
+// 其余的goroutine阻塞在没有接收方的errors上。
+func makeThumbnails4(filenames []string) error {
+	errors := make(chan error)
+	for _, f := range filenames {
+		go func(f string) {
+			_, err := imageFile(f)
+			errors <- err
+		}(f)
+	}
+	for range filenames {
+		if err := <-errors; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeThumbnails5 用有缓冲的channel修正了makeThumbnails4。
+func makeThumbnails5(filenames []string) error {
+	errors := make(chan error, len(filenames))
+	for _, f := range filenames {
+		go func(f string) {
+			_, err := imageFile(f)
+			errors <- err
+		}(f)
+	}
+	for range filenames {
+		if err := <-errors; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countdown2 是06-multiplexing_select.go中rocket_countdown2的读取输入的部分：
+// 倒计时结束后没有人再接收abort，读到输入的goroutine会阻塞在发送上；
+// 没有输入时则一直阻塞在读取上。
+func countdown2(stdin io.Reader, tick <-chan struct{}) bool {
+	abort := make(chan struct{})
+	go func() {
+		stdin.Read(make([]byte, 1))
+		abort <- struct{}{}
+	}()
+	select {
+	case <-tick:
+		return true
+	case <-abort:
+		return false
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		f     func()
+		leaks int
+		state string
+	}{
+		{"chan_send_abort", sendAbort, 1, "chan send"},
+		{"makeThumbnails4", func() { makeThumbnails4([]string{"bad1", "bad2", "bad3", "bad4"}) }, 3, "chan send"},
+		{"makeThumbnails5", func() { makeThumbnails5([]string{"bad1", "bad2", "bad3", "bad4"}) }, 0, ""},
+		{"rocket_countdown2", func() {
+			r, _ := io.Pipe() // 永远没有输入
+			tick := make(chan struct{})
+			close(tick)
+			countdown2(r, tick)
+		}, 1, "select"},
+	} {
+		errs := checkFunc(t, c.f)
+		if c.leaks == 0 {
+			if errs != "" {
+				t.Errorf("%s: unexpected report:\n%s", c.name, errs)
+			}
+			continue
+		}
+		if got := strings.Count(errs, "\ngoroutine "); got != c.leaks {
+			t.Errorf("%s: %d goroutines reported, want %d:\n%s", c.name, got, c.leaks, errs)
+		}
+		if !strings.Contains(errs, "["+c.state+"]") {
+			t.Errorf("%s: report does not mention state %q:\n%s", c.name, c.state, errs)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package leakcheck 在测试结束时检查测试中启动的goroutine是否都已经退出。
+//
+// 04-channel.go中的chan_send_abort、05-loop_in_paralel.go中的makeThumbnails4、
+// 06-multiplexing_select.go中的rocket_countdown2都会让goroutine永远阻塞在channel的发送或者输入的读取上。
+// 这样的泄露不会让程序出错，只会让内存和goroutine越来越多，普通的测试发现不了。
+//
+// 在测试开始时调用Check：
+//
+//	func TestX(t *testing.T) {
+//		leakcheck.Check(t)
+//		...
+//	}
+//
+// Check记录当时所有goroutine的ID，测试结束时（t.Cleanup）再取一次，
+// 多出来的goroutine就是测试中启动、还没有退出的。刚结束工作的goroutine可能还需要一点时间才能退出，
+// 所以Check会在一段时间内反复检查，超时后仍然存在的goroutine连同创建它的位置和调用栈一起报告为测试失败。
+//
+// Check不能用于调用了t.Parallel的测试：并行运行的其他测试启动的goroutine也会被当成泄露。
+//
+// 注意06-multiplexing_select.go中rocket_countdown1的time.Tick泄露的是ticker而不是goroutine，
+// Check发现不了；而且从Go 1.23开始，不再被引用的ticker会被垃圾回收，time.Tick也不再泄露。
+package leakcheck
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Options 是Check的选项。
+type Options struct {
+	// Timeout 是等待goroutine退出的最长时间，为0时是1秒。
+	Timeout time.Duration
+	// Ignore 是允许继续运行的goroutine的函数名，比如"net/http.(*persistConn).readLoop"。
+	// 调用栈中任何一帧是这些函数的goroutine都被忽略。DefaultIgnore总是被忽略。
+	Ignore []string
+}
+
+// DefaultIgnore 是总是被忽略的函数：它们属于标准库在第一次使用时启动、之后一直运行的goroutine。
+var DefaultIgnore = []string{
+	"os/signal.signal_recv", // signal.Notify启动的接收信号的goroutine
+	"os/signal.loop",
+	"runtime.ensureSigM",
+}
+
+// Check 记录当前的goroutine，在t结束时报告之后启动、在Timeout内没有退出的goroutine。
+func Check(t testing.TB) {
+	t.Helper()
+	CheckWith(t, Options{})
+}
+
+// CheckWith 与Check相同，但使用opts中的选项。
+func CheckWith(t testing.TB, opts Options) {
+	t.Helper()
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	ignore := append(append([]string(nil), DefaultIgnore...), opts.Ignore...)
+	before := make(map[int64]bool)
+	for _, g := range goroutines() {
+		before[g.ID] = true
+	}
+	t.Cleanup(func() {
+		t.Helper()
+		leaked := wait(before, ignore, opts.Timeout)
+		if len(leaked) == 0 {
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "leakcheck: %d goroutine(s) still running after %v:", len(leaked), opts.Timeout)
+		for _, g := range leaked {
+			fmt.Fprintf(&b, "\n\ngoroutine %d [%s]", g.ID, g.State)
+			if g.CreatedBy != "" {
+				fmt.Fprintf(&b, ", created by %s", g.CreatedBy)
+			}
+			fmt.Fprintf(&b, ":\n%s", g.Stack)
+		}
+		t.Errorf("%s", b.String())
+	})
+}
+
+// wait 反复检查，直到before之外的goroutine都退出或者超时，返回超时时仍然存在的goroutine。
+func wait(before map[int64]bool, ignore []string, timeout time.Duration) []Goroutine {
+	deadline := time.Now().Add(timeout)
+	delay := time.Millisecond
+	for {
+		leaked := extra(before, ignore)
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		runtime.Gosched()
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+func extra(before map[int64]bool, ignore []string) []Goroutine {
+	var leaked []Goroutine
+	for _, g := range goroutines() {
+		if !before[g.ID] && !g.current && !g.calls(ignore) {
+			leaked = append(leaked, g)
+		}
+	}
+	sort.Slice(leaked, func(i, j int) bool { return leaked[i].ID < leaked[j].ID })
+	return leaked
+}
+
+// Goroutine 是runtime.Stack输出中的一个goroutine。
+type Goroutine struct {
+	ID        int64
+	State     string // 比如"chan send"、"select"、"IO wait"
+	Stack     string // 调用栈，不包括第一行和"created by"
+	CreatedBy string // 创建它的函数和位置，比如"main.walk at /src/du/main.go:126"
+
+	current bool // 是否是调用runtime.Stack的goroutine
+}
+
+func (g Goroutine) calls(funcs []string) bool {
+	for _, line := range strings.Split(g.Stack, "\n") {
+		for _, fn := range funcs {
+			if strings.HasPrefix(line, fn+"(") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// goroutines 返回所有goroutine。
+func goroutines() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return parseStacks(string(buf[:n]))
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// parseStacks 解析runtime.Stack(buf, true)的输出。每个goroutine之间用空行分隔：
+//
+//	goroutine 7 [chan send]:
+//	main.leak(...)
+//		/tmp/st.go:9
+//	created by main.main in goroutine 1
+//		/tmp/st.go:13 +0x76
+//
+// 第一个goroutine是调用runtime.Stack的goroutine。
+func parseStacks(s string) []Goroutine {
+	var gs []Goroutine
+	for i, block := range strings.Split(strings.TrimSpace(s), "\n\n") {
+		header, stack, _ := strings.Cut(block, "\n")
+		// header形如"goroutine 7 [chan send, 2 minutes]:"
+		if !strings.HasPrefix(header, "goroutine ") {
+			continue
+		}
+		id, state, _ := strings.Cut(strings.TrimPrefix(header, "goroutine "), " ")
+		g := Goroutine{State: strings.TrimSuffix(strings.TrimPrefix(state, "["), "]:"), current: i == 0}
+		g.ID, _ = strconv.ParseInt(id, 10, 64)
+		if j := strings.Index(stack, "\ncreated by "); j >= 0 {
+			created := stack[j+len("\ncreated by "):]
+			stack = stack[:j]
+			fn, loc, _ := strings.Cut(created, "\n")
+			if k := strings.Index(fn, " in goroutine "); k >= 0 {
+				fn = fn[:k]
+			}
+			if k := strings.LastIndex(loc, " +0x"); k >= 0 {
+				loc = loc[:k]
+			}
+			g.CreatedBy = fn + " at " + strings.TrimSpace(loc)
+		}
+		g.Stack = stack
+		gs = append(gs, g)
+	}
+	return gs
+}
@@ -0,0 +1,109 @@
+package leakcheck
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder 代替testing.T，记录Check报告的错误，由测试自己执行cleanup。
+type recorder struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorder) Helper()          {}
+func (r *recorder) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// finish 像测试结束时一样，以相反的顺序执行cleanup，返回报告的错误。
+func (r *recorder) finish() string {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+	return strings.Join(r.errors, "\n")
+}
+
+// checkFunc 在Check之下运行f，返回Check报告的错误。
+func checkFunc(t *testing.T, f func()) string {
+	r := &recorder{TB: t}
+	CheckWith(r, Options{Timeout: 50 * time.Millisecond})
+	f()
+	return r.finish()
+}
+
+func TestNoLeak(t *testing.T) {
+	errs := checkFunc(t, func() {
+		done := make(chan struct{})
+		for i := 0; i < 10; i++ {
+			go func() { <-done }()
+		}
+		close(done) // goroutine在Check等待期间退出
+	})
+	if errs != "" {
+		t.Errorf("unexpected report:\n%s", errs)
+	}
+}
+
+func TestLeak(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	errs := checkFunc(t, func() { go blockOn(block) })
+	for _, want := range []string{
+		"leakcheck: 1 goroutine(s) still running after 50ms",
+		"[chan receive]",
+		"created by leakcheck.TestLeak.func",
+		"leakcheck_test.go:",
+		"leakcheck.blockOn(",
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("report does not contain %q:\n%s", want, errs)
+		}
+	}
+}
+
+func blockOn(c chan struct{}) { <-c }
+
+func TestIgnore(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	r := &recorder{TB: t}
+	CheckWith(r, Options{Timeout: 10 * time.Millisecond, Ignore: []string{"leakcheck.blockOn"}})
+	go blockOn(block)
+	if errs := r.finish(); errs != "" {
+		t.Errorf("ignored goroutine reported:\n%s", errs)
+	}
+}
+
+func TestParseStacks(t *testing.T) {
+	const stacks = `goroutine 1 [running]:
+main.main()
+	/tmp/st.go:16 +0xae
+
+goroutine 7 [chan send, 2 minutes]:
+main.leak(...)
+	/tmp/st.go:9
+created by main.main in goroutine 1
+	/tmp/st.go:13 +0x76
+`
+	gs := parseStacks(stacks)
+	if len(gs) != 2 {
+		t.Fatalf("got %d goroutines, want 2", len(gs))
+	}
+	if g := gs[0]; g.ID != 1 || g.State != "running" || !g.current || g.CreatedBy != "" {
+		t.Errorf("gs[0] = %+v", g)
+	}
+	want := Goroutine{
+		ID:        7,
+		State:     "chan send, 2 minutes",
+		Stack:     "main.leak(...)\n\t/tmp/st.go:9",
+		CreatedBy: "main.main at /tmp/st.go:13",
+	}
+	if gs[1] != want {
+		t.Errorf("gs[1] = %+v\nwant %+v", gs[1], want)
+	}
+}
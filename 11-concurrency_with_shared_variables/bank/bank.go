@@ -0,0 +1,205 @@
+// Package bank 是一个支持多个账户的银行。
+//
+// 11-concurrency_with_shared_variables中的银行例子只有一个全局的balance，由一把全局的锁保护。
+// 这里每个账户都有自己的互斥锁，不同账户上的操作可以并发进行。
+//
+// Transfer需要同时持有两个账户的锁。如果一个goroutine按A、B的顺序加锁，
+// 另一个goroutine按B、A的顺序加锁，两者可能各持有一把锁并等待对方，造成死锁。
+// 所以所有需要持有多把锁的操作都按照账户ID从小到大的顺序加锁。
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrNoAccount         = errors.New("bank: no such account")
+	ErrInsufficientFunds = errors.New("bank: insufficient funds")
+	ErrInvalidAmount     = errors.New("bank: amount must be positive")
+	ErrSameAccount       = errors.New("bank: transfer to the same account")
+)
+
+// ID 标识一个账户。
+type ID uint64
+
+type account struct {
+	mu      sync.Mutex
+	balance int // 由mu保护
+}
+
+// Bank 是一组账户。Bank的所有方法都是并发安全的。
+type Bank struct {
+	mu       sync.RWMutex // 保护accounts和next，不保护账户的余额
+	accounts map[ID]*account
+	next     ID
+
+	// net 是所有存款减去所有取款的值，它只在持有对应账户的锁时被修改。
+	// 不变量：持有所有账户的锁时，net等于所有账户余额的和。
+	net atomic.Int64
+}
+
+// New 返回一个没有任何账户的银行。
+func New() *Bank {
+	return &Bank{accounts: make(map[ID]*account)}
+}
+
+// Open 开设一个余额为0的账户并返回它的ID。
+func (b *Bank) Open() ID {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	b.accounts[b.next] = new(account)
+	return b.next
+}
+
+// Accounts 返回所有账户的ID，按从小到大的顺序排列。
+func (b *Bank) Accounts() []ID {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ids := make([]ID, 0, len(b.accounts))
+	for id := range b.accounts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (b *Bank) account(id ID) (*account, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	a, ok := b.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrNoAccount, id)
+	}
+	return a, nil
+}
+
+// Deposit 向账户id存入amount。
+func (b *Bank) Deposit(id ID, amount int) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	a, err := b.account(id)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.balance += amount
+	b.net.Add(int64(amount))
+	return nil
+}
+
+// Withdraw 从账户id取出amount，余额不足时返回ErrInsufficientFunds。
+// 与02-mutex.go中的Withdraw3一样，检查余额和扣款在同一个临界区内完成。
+func (b *Bank) Withdraw(id ID, amount int) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	a, err := b.account(id)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.debit(id, amount); err != nil {
+		return err
+	}
+	b.net.Add(-int64(amount))
+	return nil
+}
+
+// debit 从账户中扣除amount。调用者必须持有a.mu。
+func (a *account) debit(id ID, amount int) error {
+	if a.balance < amount {
+		return fmt.Errorf("%w: account %d has %d, need %d",
+			ErrInsufficientFunds, id, a.balance, amount)
+	}
+	a.balance -= amount
+	return nil
+}
+
+// Balance 返回账户id的余额。
+func (b *Bank) Balance(id ID) (int, error) {
+	a, err := b.account(id)
+	if err != nil {
+		return 0, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.balance, nil
+}
+
+// Transfer 从账户from向账户to转账amount。
+// 转账是原子的：其它goroutine要么看到转账之前的两个余额，要么看到转账之后的两个余额。
+func (b *Bank) Transfer(from, to ID, amount int) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if from == to {
+		return ErrSameAccount
+	}
+	src, err := b.account(from)
+	if err != nil {
+		return err
+	}
+	dst, err := b.account(to)
+	if err != nil {
+		return err
+	}
+
+	// 按ID的顺序加锁，避免两个方向相反的转账互相等待。
+	first, second := src, dst
+	if to < from {
+		first, second = dst, src
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	if err := src.debit(from, amount); err != nil {
+		return err
+	}
+	dst.balance += amount
+	return nil
+}
+
+// Total 返回所有账户余额的和。它同时持有所有账户的锁，所以结果是某一时刻的一致快照。
+func (b *Bank) Total() int {
+	total, _ := b.audit()
+	return total
+}
+
+// Audit 检查银行里的钱是否守恒：所有账户余额的和必须等于所有存款减去所有取款。
+// 转账只在账户之间移动钱，不应该改变总额。
+func (b *Bank) Audit() error {
+	total, net := b.audit()
+	if int64(total) != net {
+		return fmt.Errorf("bank: audit failed: balances sum to %d, but net deposits are %d", total, net)
+	}
+	return nil
+}
+
+func (b *Bank) audit() (total int, net int64) {
+	// 持有读锁，防止审计过程中开设新的账户。
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ids := make([]ID, 0, len(b.accounts))
+	for id := range b.accounts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids { // 与Transfer相同的加锁顺序
+		a := b.accounts[id]
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		total += a.balance
+	}
+	return total, b.net.Load()
+}
@@ -0,0 +1,168 @@
+package bank
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	b := New()
+	alice, bob := b.Open(), b.Open()
+
+	if err := b.Deposit(alice, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer(alice, bob, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw(bob, 10); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		id   ID
+		want int
+	}{
+		{alice, 70},
+		{bob, 20},
+	} {
+		if got, err := b.Balance(test.id); err != nil || got != test.want {
+			t.Errorf("Balance(%d) = %d, %v, want %d", test.id, got, err, test.want)
+		}
+	}
+	if err := b.Audit(); err != nil {
+		t.Error(err)
+	}
+	if got := b.Total(); got != 90 {
+		t.Errorf("Total() = %d, want 90", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	b := New()
+	alice, bob := b.Open(), b.Open()
+	b.Deposit(alice, 50)
+
+	for _, test := range []struct {
+		descr string
+		err   error
+		want  error
+	}{
+		{"Withdraw too much", b.Withdraw(alice, 51), ErrInsufficientFunds},
+		{"Transfer too much", b.Transfer(alice, bob, 51), ErrInsufficientFunds},
+		{"Transfer to self", b.Transfer(alice, alice, 1), ErrSameAccount},
+		{"Deposit zero", b.Deposit(alice, 0), ErrInvalidAmount},
+		{"Withdraw negative", b.Withdraw(alice, -1), ErrInvalidAmount},
+		{"Deposit to unknown", b.Deposit(42, 1), ErrNoAccount},
+		{"Transfer to unknown", b.Transfer(alice, 42, 1), ErrNoAccount},
+	} {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.descr, test.err, test.want)
+		}
+	}
+	// 失败的操作不能改变余额。
+	if got, _ := b.Balance(alice); got != 50 {
+		t.Errorf("Balance(alice) = %d after failed operations, want 50", got)
+	}
+	if err := b.Audit(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestOppositeTransfers 让两个goroutine不断地进行方向相反的转账。
+// 如果Transfer不按固定的顺序加锁，这个测试很快就会死锁。
+func TestOppositeTransfers(t *testing.T) {
+	b := New()
+	x, y := b.Open(), b.Open()
+	b.Deposit(x, 1000)
+	b.Deposit(y, 1000)
+
+	var wg sync.WaitGroup
+	for _, pair := range [][2]ID{{x, y}, {y, x}} {
+		wg.Add(1)
+		go func(from, to ID) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				b.Transfer(from, to, 1)
+			}
+		}(pair[0], pair[1])
+	}
+	wg.Wait()
+	if err := b.Audit(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestStress 用go test -race运行：多个goroutine随机地存款、取款和转账，
+// 同时审计goroutine不断检查钱是否守恒。
+func TestStress(t *testing.T) {
+	const (
+		accounts   = 10
+		workers    = 8
+		operations = 2000
+	)
+	b := New()
+	var ids []ID
+	for i := 0; i < accounts; i++ {
+		id := b.Open()
+		b.Deposit(id, 100)
+		ids = append(ids, id)
+	}
+
+	done := make(chan struct{})
+	audits := make(chan error, 1)
+	go func() {
+		defer close(audits)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := b.Audit(); err != nil {
+				audits <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < operations; i++ {
+				from, to := ids[rng.Intn(accounts)], ids[rng.Intn(accounts)]
+				amount := rng.Intn(50) + 1
+				var err error
+				switch rng.Intn(4) {
+				case 0:
+					err = b.Deposit(from, amount)
+				case 1:
+					err = b.Withdraw(from, amount)
+				default:
+					err = b.Transfer(from, to, amount)
+				}
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) && !errors.Is(err, ErrSameAccount) {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+	close(done)
+
+	if err := <-audits; err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Audit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if bal, _ := b.Balance(id); bal < 0 {
+			t.Errorf("Balance(%d) = %d, want >= 0", id, bal)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Bankd 通过HTTP JSON接口提供一个内存中的银行。
+//
+//	$ go run ./cmd/bankd -addr localhost:8000 &
+//	$ curl -X POST localhost:8000/accounts
+//	{"account":1}
+//	$ curl -H 'Idempotency-Key: 42' -d '{"account":1,"amount":100}' localhost:8000/deposit
+//	{"ok":true}
+//	$ curl 'localhost:8000/balance?account=1'
+//	{"account":1,"balance":100}
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"bank"
+	"bank/httpapi"
+)
+
+var (
+	addr    = flag.String("addr", "localhost:8000", "listen address")
+	maxKeys = flag.Int("keys", httpapi.DefaultMaxKeys, "number of idempotency keys to remember")
+)
+
+func main() {
+	flag.Parse()
+	srv := httpapi.NewServer(bank.New(), *maxKeys)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
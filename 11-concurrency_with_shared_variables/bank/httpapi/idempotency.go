@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"container/list"
+	"sync"
+)
+
+// response 是一次请求的结果，重复的请求会直接得到相同的结果。
+type response struct {
+	status int
+	body   []byte
+}
+
+// keyEntry 记录一个幂等键对应的请求。
+type keyEntry struct {
+	key         string
+	fingerprint string        // 方法、路径和请求体，用来发现被不同请求复用的键
+	done        chan struct{} // 请求处理完成后关闭
+	resp        response      // done关闭之后才可以读取
+	elem        *list.Element
+}
+
+// keyStore 保存最近见过的幂等键，最多保存max个，超出时淘汰最久没有使用的键。
+// 请求还在处理中的键不会被淘汰，否则重试会再执行一次操作；
+// 所以同时处理的请求多于max个时，键的数量会暂时超过max。
+type keyStore struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*keyEntry
+	lru     *list.List // 最近使用的在前面
+}
+
+func newKeyStore(max int) *keyStore {
+	return &keyStore{max: max, entries: make(map[string]*keyEntry), lru: list.New()}
+}
+
+// begin 查找键key。如果这是第一次见到这个键，则登记它并返回first为true，
+// 调用者处理完请求后必须调用finish；否则返回之前登记的条目，调用者应等待它的done。
+func (s *keyStore) begin(key, fingerprint string) (e *keyEntry, first bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(e.elem)
+		return e, false
+	}
+	e = &keyEntry{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+	e.elem = s.lru.PushFront(e)
+	s.entries[key] = e
+	for el := s.lru.Back(); el != nil && s.lru.Len() > s.max; {
+		prev := el.Prev()
+		if old := el.Value.(*keyEntry); old.finished() {
+			s.lru.Remove(el)
+			delete(s.entries, old.key)
+		}
+		el = prev
+	}
+	return e, true
+}
+
+// forget 删除e，之后同一个键的请求会被当作第一次见到。
+func (s *keyStore) forget(e *keyEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[e.key] == e {
+		s.lru.Remove(e.elem)
+		delete(s.entries, e.key)
+	}
+}
+
+// finished 报告e的请求是否已经处理完成。
+func (e *keyEntry) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish 记录请求的结果，并唤醒等待同一个键的请求。
+func (e *keyEntry) finish(resp response) {
+	e.resp = resp
+	close(e.done)
+}
+
+// len 返回保存的键的数量。
+func (s *keyStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
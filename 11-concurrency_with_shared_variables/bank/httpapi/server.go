@@ -0,0 +1,253 @@
+// Package httpapi 通过HTTP JSON接口提供bank包中的银行，方便用curl或集成测试来操作它。
+//
+//	POST /accounts                                  开设账户，返回 {"account": 1}
+//	POST /deposit   {"account": 1, "amount": 100}   存款
+//	POST /withdraw  {"account": 1, "amount": 30}    取款
+//	POST /transfer  {"from": 1, "to": 2, "amount": 20}
+//	GET  /balance?account=1                         返回 {"account": 1, "balance": 70}
+//
+// 网络请求可能失败后被客户端重试，而重试一次存款会让钱凭空多出来。
+// 所以POST请求可以带上Idempotency-Key头：服务器记住最近见过的键和对应的响应，
+// 带着相同键的重复请求不会再执行一次，而是得到第一次请求的响应。
+// 同一个键的并发请求会等待第一个请求完成。用同一个键发送不同的请求立即得到422；
+// 5xx的响应不会被记住，重试会再执行一次。
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"bank"
+)
+
+// DefaultMaxKeys 是默认最多保存的幂等键的数量。
+const DefaultMaxKeys = 10000
+
+const maxBodySize = 1 << 20
+
+// Server 是银行的HTTP服务器。
+type Server struct {
+	bank *bank.Bank
+	keys *keyStore
+	mux  *http.ServeMux
+}
+
+// NewServer 返回一个操作b的服务器，它最多保存maxKeys个幂等键。
+func NewServer(b *bank.Bank, maxKeys int) *Server {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	s := &Server{bank: b, keys: newKeyStore(maxKeys), mux: http.NewServeMux()}
+	s.mux.Handle("/accounts", s.post(s.openAccount))
+	s.mux.Handle("/deposit", s.post(s.deposit))
+	s.mux.Handle("/withdraw", s.post(s.withdraw))
+	s.mux.Handle("/transfer", s.post(s.transfer))
+	s.mux.HandleFunc("/balance", s.balance)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// operation 处理一个POST请求的请求体，返回状态码和需要编码为JSON的响应。
+type operation func(body []byte) (int, interface{})
+
+// post 把op包装为一个只接受POST请求、支持Idempotency-Key的handler。
+func (s *Server) post(op operation) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeResponse(w, errorResponse(http.StatusMethodNotAllowed, errors.New("method not allowed")))
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			writeResponse(w, errorResponse(http.StatusBadRequest, err))
+			return
+		}
+		if len(body) > maxBodySize {
+			writeResponse(w, errorResponse(http.StatusRequestEntityTooLarge, errors.New("request body too large")))
+			return
+		}
+
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			writeResponse(w, encode(op(body)))
+			return
+		}
+		fingerprint := r.Method + " " + r.URL.Path + "\n" + string(body)
+		for {
+			e, first := s.keys.begin(key, fingerprint)
+			if e.fingerprint != fingerprint {
+				writeResponse(w, errorResponse(http.StatusUnprocessableEntity,
+					errors.New("idempotency key reused with a different request")))
+				return
+			}
+			if first {
+				writeResponse(w, s.runFirst(e, op, body))
+				return
+			}
+
+			// 重复的请求：等待第一个请求完成后返回它的响应。
+			select {
+			case <-e.done:
+			case <-r.Context().Done():
+				return
+			}
+			if e.resp.status < 500 {
+				w.Header().Set("Idempotent-Replayed", "true")
+				writeResponse(w, e.resp)
+				return
+			}
+			// 第一个请求遇到了服务器错误，键已经被删除，由这个请求重新执行。
+		}
+	})
+}
+
+// runFirst 为键的第一个请求执行op，记录响应并唤醒等待同一个键的请求。
+// 服务器的错误不记住，重试时再执行一次。op panic时同样删除键，
+// 并用500唤醒等待的请求，然后继续panic，由net/http记录并关闭连接。
+func (s *Server) runFirst(e *keyEntry, op operation, body []byte) response {
+	defer func() {
+		if p := recover(); p != nil {
+			s.keys.forget(e)
+			e.finish(errorResponse(http.StatusInternalServerError, errors.New("internal server error")))
+			panic(p)
+		}
+	}()
+	resp := encode(op(body))
+	if resp.status >= 500 {
+		s.keys.forget(e)
+	}
+	e.finish(resp)
+	return resp
+}
+
+type accountResponse struct {
+	Account bank.ID `json:"account"`
+}
+
+type amountRequest struct {
+	Account bank.ID `json:"account"`
+	Amount  int     `json:"amount"`
+}
+
+type transferRequest struct {
+	From   bank.ID `json:"from"`
+	To     bank.ID `json:"to"`
+	Amount int     `json:"amount"`
+}
+
+type balanceResponse struct {
+	Account bank.ID `json:"account"`
+	Balance int     `json:"balance"`
+}
+
+type okResponse struct {
+	OK bool `json:"ok"`
+}
+
+type errResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) openAccount(body []byte) (int, interface{}) {
+	return http.StatusCreated, accountResponse{s.bank.Open()}
+}
+
+func (s *Server) deposit(body []byte) (int, interface{}) {
+	var req amountRequest
+	if err := decode(body, &req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return result(s.bank.Deposit(req.Account, req.Amount))
+}
+
+func (s *Server) withdraw(body []byte) (int, interface{}) {
+	var req amountRequest
+	if err := decode(body, &req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return result(s.bank.Withdraw(req.Account, req.Amount))
+}
+
+func (s *Server) transfer(body []byte) (int, interface{}) {
+	var req transferRequest
+	if err := decode(body, &req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return result(s.bank.Transfer(req.From, req.To, req.Amount))
+}
+
+func (s *Server) balance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeResponse(w, errorResponse(http.StatusMethodNotAllowed, errors.New("method not allowed")))
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("account"), 10, 64)
+	if err != nil {
+		writeResponse(w, errorResponse(http.StatusBadRequest, fmt.Errorf("bad account: %v", err)))
+		return
+	}
+	balance, err := s.bank.Balance(bank.ID(id))
+	if err != nil {
+		writeResponse(w, encode(result(err)))
+		return
+	}
+	writeResponse(w, encode(http.StatusOK, balanceResponse{bank.ID(id), balance}))
+}
+
+// result 把bank包返回的错误映射为HTTP状态码。
+func result(err error) (int, interface{}) {
+	switch {
+	case err == nil:
+		return http.StatusOK, okResponse{true}
+	case errors.Is(err, bank.ErrNoAccount):
+		return http.StatusNotFound, err
+	case errors.Is(err, bank.ErrInsufficientFunds):
+		return http.StatusConflict, err
+	case errors.Is(err, bank.ErrInvalidAmount), errors.Is(err, bank.ErrSameAccount):
+		return http.StatusBadRequest, err
+	default:
+		return http.StatusInternalServerError, err
+	}
+}
+
+func decode(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("bad request body: %v", err)
+	}
+	return nil
+}
+
+// encode 把handler的结果编码为响应，error被编码为{"error": "..."}。
+func encode(status int, v interface{}) response {
+	if err, ok := v.(error); ok {
+		v = errResponse{err.Error()}
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, err)
+	}
+	return response{status, append(body, '\n')}
+}
+
+func errorResponse(status int, err error) response {
+	body, _ := json.Marshal(errResponse{err.Error()})
+	return response{status, append(body, '\n')}
+}
+
+func writeResponse(w http.ResponseWriter, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
@@ -0,0 +1,392 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bank"
+)
+
+// client 是测试用的客户端，它向一个httptest服务器发送请求。
+type client struct {
+	t   *testing.T
+	url string
+}
+
+func newClient(t *testing.T, maxKeys int) (*client, *Server) {
+	srv := NewServer(bank.New(), maxKeys)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return &client{t, ts.URL}, srv
+}
+
+// do 发送一个请求，返回状态码和解码后的JSON响应。
+func (c *client) do(method, path, key, body string) (int, map[string]interface{}, http.Header) {
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Error(err)
+		return 0, nil, nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Error(err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		c.t.Errorf("%s %s: bad JSON %q: %v", method, path, data, err)
+	}
+	return resp.StatusCode, v, resp.Header
+}
+
+func (c *client) open() int {
+	c.t.Helper()
+	status, v, _ := c.do("POST", "/accounts", "", "")
+	if status != http.StatusCreated {
+		c.t.Fatalf("POST /accounts = %d %v", status, v)
+	}
+	return int(v["account"].(float64))
+}
+
+func (c *client) balance(id int) int {
+	c.t.Helper()
+	status, v, _ := c.do("GET", fmt.Sprintf("/balance?account=%d", id), "", "")
+	if status != http.StatusOK {
+		c.t.Fatalf("GET /balance = %d %v", status, v)
+	}
+	return int(v["balance"].(float64))
+}
+
+func TestAPI(t *testing.T) {
+	c, _ := newClient(t, 0)
+	alice, bob := c.open(), c.open()
+
+	for _, test := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/deposit", fmt.Sprintf(`{"account":%d,"amount":100}`, alice), http.StatusOK},
+		{"POST", "/withdraw", fmt.Sprintf(`{"account":%d,"amount":30}`, alice), http.StatusOK},
+		{"POST", "/transfer", fmt.Sprintf(`{"from":%d,"to":%d,"amount":20}`, alice, bob), http.StatusOK},
+		{"POST", "/withdraw", fmt.Sprintf(`{"account":%d,"amount":21}`, bob), http.StatusConflict},
+		{"POST", "/transfer", fmt.Sprintf(`{"from":%d,"to":%d,"amount":1}`, alice, alice), http.StatusBadRequest},
+		{"POST", "/deposit", fmt.Sprintf(`{"account":%d,"amount":-5}`, alice), http.StatusBadRequest},
+		{"POST", "/deposit", `{"account":99,"amount":5}`, http.StatusNotFound},
+		{"POST", "/deposit", `{"account":1,"amount":"5"}`, http.StatusBadRequest},
+		{"POST", "/deposit", `{"acount":1,"amount":5}`, http.StatusBadRequest},
+		{"GET", "/deposit", "", http.StatusMethodNotAllowed},
+		{"POST", "/balance?account=1", "", http.StatusMethodNotAllowed},
+		{"GET", "/balance?account=x", "", http.StatusBadRequest},
+		{"GET", "/balance?account=99", "", http.StatusNotFound},
+	} {
+		status, v, _ := c.do(test.method, test.path, "", test.body)
+		if status != test.want {
+			t.Errorf("%s %s %s = %d %v, want %d", test.method, test.path, test.body, status, v, test.want)
+		}
+		if status != http.StatusOK && v["error"] == nil {
+			t.Errorf("%s %s %s: no error message in %v", test.method, test.path, test.body, v)
+		}
+	}
+	if got := c.balance(alice); got != 50 {
+		t.Errorf("balance(alice) = %d, want 50", got)
+	}
+	if got := c.balance(bob); got != 20 {
+		t.Errorf("balance(bob) = %d, want 20", got)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	c, _ := newClient(t, 0)
+	id := c.open()
+	body := fmt.Sprintf(`{"account":%d,"amount":10}`, id)
+
+	status, _, h := c.do("POST", "/deposit", "k1", body)
+	if status != http.StatusOK || h.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first deposit = %d, replayed=%q", status, h.Get("Idempotent-Replayed"))
+	}
+	status, _, h = c.do("POST", "/deposit", "k1", body)
+	if status != http.StatusOK || h.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retried deposit = %d, replayed=%q", status, h.Get("Idempotent-Replayed"))
+	}
+	if got := c.balance(id); got != 10 {
+		t.Errorf("balance = %d after retry, want 10", got)
+	}
+
+	// 失败的请求也会被记住：即使之后余额足够，重试也得到相同的失败结果。
+	wbody := fmt.Sprintf(`{"account":%d,"amount":15}`, id)
+	if status, _, _ := c.do("POST", "/withdraw", "k2", wbody); status != http.StatusConflict {
+		t.Errorf("withdraw = %d, want 409", status)
+	}
+	c.do("POST", "/deposit", "", body)
+	if status, _, _ := c.do("POST", "/withdraw", "k2", wbody); status != http.StatusConflict {
+		t.Errorf("retried withdraw = %d, want the recorded 409", status)
+	}
+
+	// 用同一个键发送不同的请求。
+	if status, _, _ := c.do("POST", "/deposit", "k1", wbody); status != http.StatusUnprocessableEntity {
+		t.Errorf("reused key = %d, want 422", status)
+	}
+}
+
+func TestKeyStoreBounded(t *testing.T) {
+	c, srv := newClient(t, 3)
+	id := c.open()
+	body := fmt.Sprintf(`{"account":%d,"amount":1}`, id)
+	for i := 0; i < 10; i++ {
+		c.do("POST", "/deposit", fmt.Sprint("key", i), body)
+	}
+	if n := srv.keys.len(); n != 3 {
+		t.Errorf("key store holds %d keys, want 3", n)
+	}
+	// 最早的键已经被淘汰，重试会再执行一次。
+	c.do("POST", "/deposit", "key0", body)
+	if got := c.balance(id); got != 11 {
+		t.Errorf("balance = %d, want 11", got)
+	}
+	// 最近的键仍然有效。
+	c.do("POST", "/deposit", "key9", body)
+	if got := c.balance(id); got != 11 {
+		t.Errorf("balance = %d, want 11", got)
+	}
+}
+
+// TestConcurrentClients 让很多客户端同时操作同一个账户。
+func TestConcurrentClients(t *testing.T) {
+	c, _ := newClient(t, 0)
+	id := c.open()
+	const clients = 20
+	const requests = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				body := fmt.Sprintf(`{"account":%d,"amount":3}`, id)
+				c.do("POST", "/deposit", "", body)
+				body = fmt.Sprintf(`{"account":%d,"amount":1}`, id)
+				if status, v, _ := c.do("POST", "/withdraw", "", body); status != http.StatusOK {
+					t.Errorf("withdraw = %d %v", status, v)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got, want := c.balance(id), clients*requests*2; got != want {
+		t.Errorf("balance = %d, want %d", got, want)
+	}
+}
+
+// TestConcurrentRetries 让很多客户端同时用同一个键重试同一个请求，只能有一次生效。
+func TestConcurrentRetries(t *testing.T) {
+	c, _ := newClient(t, 0)
+	id := c.open()
+	const keys = 10
+	const retries = 10
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	replayed := 0
+	for k := 0; k < keys; k++ {
+		for r := 0; r < retries; r++ {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				body := fmt.Sprintf(`{"account":%d,"amount":5}`, id)
+				status, _, h := c.do("POST", "/deposit", fmt.Sprint("retry-", k), body)
+				if status != http.StatusOK {
+					t.Errorf("deposit = %d", status)
+				}
+				if h.Get("Idempotent-Replayed") == "true" {
+					mu.Lock()
+					replayed++
+					mu.Unlock()
+				}
+			}(k)
+		}
+	}
+	wg.Wait()
+	if got := c.balance(id); got != keys*5 {
+		t.Errorf("balance = %d, want %d", got, keys*5)
+	}
+	if want := keys * (retries - 1); replayed != want {
+		t.Errorf("%d responses were replayed, want %d", replayed, want)
+	}
+}
+
+// postRecorder 用s.post(op)处理一个POST请求，返回响应。
+func postRecorder(s *Server, op operation, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/op", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	s.post(op).ServeHTTP(rec, req)
+	return rec
+}
+
+// TestKeyMismatchDoesNotWait 检查用同一个键发送的不同请求不需要等第一个请求完成就得到422。
+func TestKeyMismatchDoesNotWait(t *testing.T) {
+	s := NewServer(bank.New(), 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := func(body []byte) (int, interface{}) {
+		close(started)
+		<-release
+		return http.StatusOK, okResponse{true}
+	}
+	done := make(chan int)
+	go func() { done <- postRecorder(s, slow, "k", "a").Code }()
+	<-started
+
+	mismatch := make(chan int)
+	go func() { mismatch <- postRecorder(s, slow, "k", "b").Code }()
+	select {
+	case code := <-mismatch:
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("reused key = %d, want 422", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the mismatching request waited for the first one")
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first request = %d", code)
+	}
+}
+
+// TestServerErrorsNotRecorded 检查5xx的响应不会被记住，重试会再执行一次。
+func TestServerErrorsNotRecorded(t *testing.T) {
+	s := NewServer(bank.New(), 0)
+	calls := 0
+	flaky := func(body []byte) (int, interface{}) {
+		calls++
+		if calls == 1 {
+			return http.StatusInternalServerError, errors.New("disk on fire")
+		}
+		return http.StatusOK, okResponse{true}
+	}
+	if code := postRecorder(s, flaky, "k", "x").Code; code != http.StatusInternalServerError {
+		t.Fatalf("first request = %d, want 500", code)
+	}
+	rec := postRecorder(s, flaky, "k", "x")
+	if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry = %d, replayed=%q; want a fresh 200", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	rec = postRecorder(s, flaky, "k", "x")
+	if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("second retry = %d, replayed=%q; want the recorded 200", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	if calls != 2 {
+		t.Errorf("operation ran %d times, want 2", calls)
+	}
+}
+
+// TestInFlightKeyNotEvicted 检查还在处理中的键不会被淘汰：
+// 即使期间有更多的键进来，重试也会等待第一个请求，而不是再执行一次。
+func TestInFlightKeyNotEvicted(t *testing.T) {
+	s := NewServer(bank.New(), 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var mu sync.Mutex
+	calls := 0
+	slow := func(body []byte) (int, interface{}) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		return http.StatusOK, okResponse{true}
+	}
+	done := make(chan int)
+	go func() { done <- postRecorder(s, slow, "slow", "x").Code }()
+	<-started
+
+	quick := func(body []byte) (int, interface{}) { return http.StatusOK, okResponse{true} }
+	for i := 0; i < 3; i++ {
+		postRecorder(s, quick, fmt.Sprint("key", i), "y")
+	}
+	retry := make(chan *httptest.ResponseRecorder)
+	go func() { retry <- postRecorder(s, slow, "slow", "x") }()
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first request = %d", code)
+	}
+	rec := <-retry
+	if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d, replayed=%q; want the recorded 200", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	if calls != 1 {
+		t.Errorf("operation ran %d times, want 1", calls)
+	}
+	// 处理完的键又可以被淘汰了。
+	postRecorder(s, quick, "last", "z")
+	if n := s.keys.len(); n != 1 {
+		t.Errorf("key store holds %d keys, want 1", n)
+	}
+}
+
+// TestPanicWakesDuplicates 检查op panic时等待同一个键的请求不会一直阻塞，而是重新执行。
+func TestPanicWakesDuplicates(t *testing.T) {
+	s := NewServer(bank.New(), 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var mu sync.Mutex
+	calls := 0
+	op := func(body []byte) (int, interface{}) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n == 1 {
+			close(started)
+			<-release
+			panic("boom")
+		}
+		return http.StatusOK, okResponse{true}
+	}
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		postRecorder(s, op, "k", "x")
+	}()
+	<-started
+
+	dup := make(chan *httptest.ResponseRecorder)
+	go func() { dup <- postRecorder(s, op, "k", "x") }()
+	// 给重复的请求一点时间开始等待第一个请求。它在panic之后才到达时键已经被删除，
+	// 结果也是重新执行一次，所以这里的时间只影响测到的路径，不影响结果。
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if p := <-panicked; p != "boom" {
+		t.Errorf("first request: recover() = %v, want the panic to propagate", p)
+	}
+	select {
+	case rec := <-dup:
+		if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("duplicate = %d, replayed=%q; want a fresh 200", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the duplicate request hung after the first one panicked")
+	}
+	if calls != 2 {
+		t.Errorf("operation ran %d times, want 2", calls)
+	}
+}
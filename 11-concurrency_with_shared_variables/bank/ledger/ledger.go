@@ -0,0 +1,344 @@
+// Package ledger 是一个持久化的银行账本。
+//
+// 银行例子中的余额都只存在于内存中，进程崩溃后就丢失了。
+// Ledger在修改内存中的余额之前，先把操作作为一条带校验和的记录追加到预写日志（write-ahead log）中，
+// 并在fsync成功之后才确认操作。启动时重放日志就可以恢复崩溃前已确认的所有操作。
+//
+// 日志会随着操作不断增长，所以Ledger每写入Options.SnapshotEvery条记录就保存一次快照并清空日志，
+// 恢复时只需要读取快照和快照之后的日志，这限制了恢复所需的时间。
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"bank"
+)
+
+const (
+	logName      = "wal.log"
+	snapshotName = "snapshot"
+)
+
+// Options 配置Ledger。
+type Options struct {
+	// SnapshotEvery 是两次快照之间写入的日志记录数，0表示不自动保存快照。
+	SnapshotEvery int
+}
+
+// Ledger 是一个持久化的账本。Ledger的所有方法都是并发安全的。
+//
+// 所有操作由一把互斥锁串行化，因此日志中记录的顺序就是操作生效的顺序。
+type Ledger struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	log      *os.File
+	seq      uint64 // 最后一条记录的序号
+	next     bank.ID
+	balances map[bank.ID]int
+	pending  int   // 上次快照之后写入的记录数
+	err      error // 写日志失败后，账本不再接受新的操作
+}
+
+// Open 打开目录dir中的账本，如果目录中没有账本则创建一个新的。
+// 它读取快照并重放之后的日志；日志末尾不完整或损坏的记录会被截掉。
+func Open(dir string, opts Options) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	l := &Ledger{dir: dir, opts: opts, balances: make(map[bank.ID]int)}
+	if err := l.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := l.replay(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// 截掉最后一条有效记录之后的内容，新的记录追加在它后面。
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.log = f
+	return l, nil
+}
+
+func (l *Ledger) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(l.dir, snapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 快照是先写入临时文件再重命名的，所以它要么完整，要么不存在；损坏的快照无法恢复。
+	payload, err := readRecord(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("ledger: corrupt snapshot: %v", err)
+	}
+	var s snapshot
+	if err := s.unmarshal(payload); err != nil {
+		return fmt.Errorf("ledger: corrupt snapshot: %v", err)
+	}
+	l.seq, l.next, l.balances = s.seq, s.next, s.balances
+	return nil
+}
+
+// replay 重放日志中快照之后的记录，返回最后一条有效记录结束的位置。
+func (l *Ledger) replay(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+	var valid int64
+	for {
+		payload, err := readRecord(r)
+		if err == io.EOF || err == errBadRecord {
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var e entry
+		if e.unmarshal(payload) != nil {
+			return valid, nil
+		}
+		switch {
+		case e.seq <= l.seq:
+			// 已经包含在快照中：保存快照之后、清空日志之前发生了崩溃。
+		case e.seq == l.seq+1:
+			if err := l.apply(&e); err != nil {
+				return 0, fmt.Errorf("ledger: replaying record %d: %v", e.seq, err)
+			}
+			l.seq = e.seq
+		default:
+			return valid, nil // 序号不连续，之后的记录不可信
+		}
+		valid += int64(headerSize + len(payload))
+	}
+}
+
+// check 检查操作e能否在当前状态上执行。调用者必须持有l.mu。
+func (l *Ledger) check(e *entry) error {
+	if e.op != opOpen && e.amount <= 0 {
+		return bank.ErrInvalidAmount
+	}
+	if e.op == opOpen {
+		return nil
+	}
+	balance, ok := l.balances[e.id]
+	if !ok {
+		return fmt.Errorf("%w: %d", bank.ErrNoAccount, e.id)
+	}
+	if e.op == opTransfer {
+		if _, ok := l.balances[e.to]; !ok {
+			return fmt.Errorf("%w: %d", bank.ErrNoAccount, e.to)
+		}
+		if e.to == e.id {
+			return bank.ErrSameAccount
+		}
+	}
+	if (e.op == opWithdraw || e.op == opTransfer) && int64(balance) < e.amount {
+		return fmt.Errorf("%w: account %d has %d, need %d",
+			bank.ErrInsufficientFunds, e.id, balance, e.amount)
+	}
+	return nil
+}
+
+// apply 把操作e作用到内存中的状态上。调用者必须持有l.mu。
+func (l *Ledger) apply(e *entry) error {
+	if err := l.check(e); err != nil {
+		return err
+	}
+	switch e.op {
+	case opOpen:
+		l.next++
+		l.balances[l.next] = 0
+		e.id = l.next // 账户的ID由操作的顺序决定，重放时会得到相同的ID
+	case opDeposit:
+		l.balances[e.id] += int(e.amount)
+	case opWithdraw:
+		l.balances[e.id] -= int(e.amount)
+	case opTransfer:
+		l.balances[e.id] -= int(e.amount)
+		l.balances[e.to] += int(e.amount)
+	}
+	return nil
+}
+
+// commit 检查操作e，把它写入日志并fsync，然后作用到内存中的状态上。
+func (l *Ledger) commit(e *entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	if err := l.check(e); err != nil {
+		return err
+	}
+
+	e.seq = l.seq + 1
+	if _, err := l.log.Write(appendRecord(nil, e.marshal())); err != nil {
+		l.err = fmt.Errorf("ledger: write log: %v", err)
+		return l.err
+	}
+	if err := l.log.Sync(); err != nil {
+		l.err = fmt.Errorf("ledger: sync log: %v", err)
+		return l.err
+	}
+	l.apply(e) // 已经检查过，不会失败
+	l.seq = e.seq
+
+	l.pending++
+	if l.opts.SnapshotEvery > 0 && l.pending >= l.opts.SnapshotEvery {
+		// 这次操作已经持久化，所以不把快照的错误返回给它；
+		// 但快照失败以后日志的状态不确定，之后的操作都会失败。
+		if err := l.snapshot(); err != nil {
+			l.err = err
+		}
+	}
+	return nil
+}
+
+// OpenAccount 开设一个余额为0的账户并返回它的ID。
+func (l *Ledger) OpenAccount() (bank.ID, error) {
+	e := entry{op: opOpen}
+	if err := l.commit(&e); err != nil {
+		return 0, err
+	}
+	return e.id, nil
+}
+
+// Deposit 向账户id存入amount。
+func (l *Ledger) Deposit(id bank.ID, amount int) error {
+	return l.commit(&entry{op: opDeposit, id: id, amount: int64(amount)})
+}
+
+// Withdraw 从账户id取出amount，余额不足时返回bank.ErrInsufficientFunds。
+func (l *Ledger) Withdraw(id bank.ID, amount int) error {
+	return l.commit(&entry{op: opWithdraw, id: id, amount: int64(amount)})
+}
+
+// Transfer 从账户from向账户to转账amount。
+func (l *Ledger) Transfer(from, to bank.ID, amount int) error {
+	return l.commit(&entry{op: opTransfer, id: from, to: to, amount: int64(amount)})
+}
+
+// Balance 返回账户id的余额。
+func (l *Ledger) Balance(id bank.ID) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	balance, ok := l.balances[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", bank.ErrNoAccount, id)
+	}
+	return balance, nil
+}
+
+// Accounts 返回所有账户的ID，按从小到大的顺序排列。
+func (l *Ledger) Accounts() []bank.ID {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ids := make([]bank.ID, 0, len(l.balances))
+	for id := range l.balances {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Snapshot 立即保存一次快照并清空日志。
+func (l *Ledger) Snapshot() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	return l.snapshot()
+}
+
+// snapshot 保存快照并清空日志。调用者必须持有l.mu。
+//
+// 快照先写入临时文件，fsync以后重命名为正式的文件名，再fsync目录，
+// 这样崩溃后看到的要么是旧的快照，要么是完整的新快照。
+// 快照持久化之后才清空日志；如果在两者之间崩溃，恢复时会跳过日志中序号不大于快照的记录。
+func (l *Ledger) snapshot() error {
+	s := snapshot{seq: l.seq, next: l.next, balances: l.balances}
+	tmp := filepath.Join(l.dir, snapshotName+".tmp")
+	if err := writeFileSync(tmp, appendRecord(nil, s.marshal())); err != nil {
+		return fmt.Errorf("ledger: write snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, snapshotName)); err != nil {
+		return fmt.Errorf("ledger: write snapshot: %v", err)
+	}
+	if err := syncDir(l.dir); err != nil {
+		return fmt.Errorf("ledger: write snapshot: %v", err)
+	}
+
+	if err := l.log.Truncate(0); err != nil {
+		return fmt.Errorf("ledger: truncate log: %v", err)
+	}
+	if _, err := l.log.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("ledger: truncate log: %v", err)
+	}
+	if err := l.log.Sync(); err != nil {
+		return fmt.Errorf("ledger: truncate log: %v", err)
+	}
+	l.pending = 0
+	return nil
+}
+
+// Close 关闭日志文件。
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.log == nil {
+		return nil
+	}
+	err := l.log.Close()
+	l.log = nil
+	if l.err == nil {
+		l.err = errors.New("ledger: closed")
+	}
+	return err
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
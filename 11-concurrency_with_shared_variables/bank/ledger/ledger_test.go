@@ -0,0 +1,264 @@
+package ledger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"bank"
+)
+
+// operation 是测试中对账本执行的一个操作，同时也能作用到一个简单的内存模型上。
+type operation struct {
+	op     op
+	id, to bank.ID
+	amount int
+}
+
+var script = []operation{
+	{op: opOpen},
+	{op: opOpen},
+	{op: opDeposit, id: 1, amount: 100},
+	{op: opDeposit, id: 2, amount: 50},
+	{op: opWithdraw, id: 1, amount: 30},
+	{op: opTransfer, id: 1, to: 2, amount: 20},
+	{op: opOpen},
+	{op: opTransfer, id: 2, to: 3, amount: 60},
+	{op: opWithdraw, id: 3, amount: 10},
+	{op: opDeposit, id: 1, amount: 5},
+}
+
+func (o operation) do(t *testing.T, l *Ledger) {
+	t.Helper()
+	var err error
+	switch o.op {
+	case opOpen:
+		_, err = l.OpenAccount()
+	case opDeposit:
+		err = l.Deposit(o.id, o.amount)
+	case opWithdraw:
+		err = l.Withdraw(o.id, o.amount)
+	case opTransfer:
+		err = l.Transfer(o.id, o.to, o.amount)
+	}
+	if err != nil {
+		t.Fatalf("%+v: %v", o, err)
+	}
+}
+
+// model 返回执行script的前n个操作之后所有账户的余额。
+func model(n int) map[bank.ID]int {
+	balances := make(map[bank.ID]int)
+	var next bank.ID
+	for _, o := range script[:n] {
+		switch o.op {
+		case opOpen:
+			next++
+			balances[next] = 0
+		case opDeposit:
+			balances[o.id] += o.amount
+		case opWithdraw:
+			balances[o.id] -= o.amount
+		case opTransfer:
+			balances[o.id] -= o.amount
+			balances[o.to] += o.amount
+		}
+	}
+	return balances
+}
+
+func balances(t *testing.T, l *Ledger) map[bank.ID]int {
+	t.Helper()
+	m := make(map[bank.ID]int)
+	for _, id := range l.Accounts() {
+		b, err := l.Balance(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[id] = b
+	}
+	return m
+}
+
+func mustOpen(t *testing.T, dir string, opts Options) *Ledger {
+	t.Helper()
+	l, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// run 在一个新的账本上执行script，返回每个操作之后日志文件的大小。
+func run(t *testing.T, dir string, opts Options) []int64 {
+	t.Helper()
+	l := mustOpen(t, dir, opts)
+	var sizes []int64
+	for _, o := range script {
+		o.do(t, l)
+		fi, err := os.Stat(filepath.Join(dir, logName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, fi.Size())
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sizes
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	run(t, dir, Options{})
+	l := mustOpen(t, dir, Options{})
+	if got, want := balances(t, l), model(len(script)); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered %v, want %v", got, want)
+	}
+	// 恢复之后新开的账户的ID接着之前的ID。
+	if id, err := l.OpenAccount(); err != nil || id != 4 {
+		t.Errorf("OpenAccount() = %d, %v, want 4", id, err)
+	}
+}
+
+func TestErrorsAreNotLogged(t *testing.T) {
+	dir := t.TempDir()
+	l := mustOpen(t, dir, Options{})
+	id, _ := l.OpenAccount()
+	l.Deposit(id, 10)
+	if err := l.Withdraw(id, 11); !errors.Is(err, bank.ErrInsufficientFunds) {
+		t.Errorf("Withdraw(11) = %v, want ErrInsufficientFunds", err)
+	}
+	if err := l.Deposit(id+1, 1); !errors.Is(err, bank.ErrNoAccount) {
+		t.Errorf("Deposit(unknown) = %v, want ErrNoAccount", err)
+	}
+	l.Close()
+
+	l = mustOpen(t, dir, Options{})
+	if b, _ := l.Balance(id); b != 10 {
+		t.Errorf("Balance = %d after recovery, want 10", b)
+	}
+}
+
+// TestTruncatedTail 模拟写入日志时崩溃：把日志截断到每一个可能的长度，
+// 恢复的状态必须等于最后一条完整记录之后的状态。
+func TestTruncatedTail(t *testing.T) {
+	src := t.TempDir()
+	sizes := run(t, src, Options{})
+	data, err := os.ReadFile(filepath.Join(src, logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cut := 0; cut <= len(data); cut++ {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, logName), data[:cut], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		n := 0 // 完整保留下来的记录数
+		for n < len(sizes) && sizes[n] <= int64(cut) {
+			n++
+		}
+		l, err := Open(dir, Options{})
+		if err != nil {
+			t.Fatalf("cut=%d: Open: %v", cut, err)
+		}
+		if got, want := balances(t, l), model(n); !reflect.DeepEqual(got, want) {
+			t.Errorf("cut=%d: recovered %v, want %v", cut, got, want)
+		}
+		l.Close()
+	}
+}
+
+// TestCorruptTail 改写最后一条记录中的一个字节，恢复应该停在它之前的那条记录。
+func TestCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	sizes := run(t, dir, Options{})
+	name := filepath.Join(dir, logName)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := sizes[len(sizes)-2] // 最后一条记录的起始位置
+	for _, off := range []int64{last + 1, last + headerSize + 3, int64(len(data)) - 1} {
+		corrupt := append([]byte(nil), data...)
+		corrupt[off] ^= 0xff
+		if err := os.WriteFile(name, corrupt, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		l := mustOpen(t, dir, Options{})
+		if got, want := balances(t, l), model(len(script)-1); !reflect.DeepEqual(got, want) {
+			t.Errorf("corrupt byte %d: recovered %v, want %v", off, got, want)
+		}
+
+		// 损坏的记录被截掉，之后追加的记录可以被正常恢复。
+		script[len(script)-1].do(t, l)
+		l.Close()
+		l = mustOpen(t, dir, Options{})
+		if got, want := balances(t, l), model(len(script)); !reflect.DeepEqual(got, want) {
+			t.Errorf("corrupt byte %d: after rewrite recovered %v, want %v", off, got, want)
+		}
+		l.Close()
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	sizes := run(t, dir, Options{SnapshotEvery: 4})
+	// 写入4条记录后清空日志，所以日志中最多只有3条记录。
+	if max := sizes[2]; sizes[len(sizes)-1] > max {
+		t.Errorf("log size = %d, want <= %d", sizes[len(sizes)-1], max)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotName)); err != nil {
+		t.Fatal(err)
+	}
+	l := mustOpen(t, dir, Options{})
+	if got, want := balances(t, l), model(len(script)); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered %v, want %v", got, want)
+	}
+}
+
+// TestCrashAfterSnapshot 模拟保存快照之后、清空日志之前崩溃：
+// 日志中的记录都已经包含在快照中，恢复时不能再重放一次。
+func TestCrashAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	run(t, dir, Options{})
+	log, err := os.ReadFile(filepath.Join(dir, logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := mustOpen(t, dir, Options{})
+	if err := l.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if err := os.WriteFile(filepath.Join(dir, logName), log, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l = mustOpen(t, dir, Options{})
+	if got, want := balances(t, l), model(len(script)); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered %v, want %v", got, want)
+	}
+}
+
+func TestCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	run(t, dir, Options{SnapshotEvery: 4})
+	name := filepath.Join(dir, snapshotName)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, Options{}); err == nil {
+		t.Error("Open with a corrupt snapshot succeeded")
+	}
+}
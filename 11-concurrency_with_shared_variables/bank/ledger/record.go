@@ -0,0 +1,155 @@
+package ledger
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"bank"
+)
+
+/*
+日志和快照都由记录（record）组成，每条记录的格式为：
+
+	+-----------+-----------+-----------------+
+	| length(4) |  crc(4)   | payload(length) |
+	+-----------+-----------+-----------------+
+
+crc是payload的CRC-32C校验和，整数都使用小端字节序。
+崩溃可能发生在写入一条记录的任意时刻，所以日志的末尾可能是一条不完整或校验和错误的记录，
+恢复时读到这样的记录就停止，之后的内容都被丢弃。
+*/
+
+const headerSize = 8
+
+// maxRecordSize 限制了一条记录的大小，防止损坏的length字段导致分配巨大的内存。
+const maxRecordSize = 1 << 26
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errBadRecord 表示读到了不完整或已损坏的记录。
+var errBadRecord = errors.New("ledger: bad record")
+
+// appendRecord 把payload编码为一条记录追加到buf之后。
+func appendRecord(buf, payload []byte) []byte {
+	var h [headerSize]byte
+	binary.LittleEndian.PutUint32(h[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(h[4:], crc32.Checksum(payload, crcTable))
+	buf = append(buf, h[:]...)
+	return append(buf, payload...)
+}
+
+// readRecord 从r读取一条记录的payload。
+// 在记录边界上读到EOF时返回io.EOF，记录不完整或校验和错误时返回errBadRecord。
+func readRecord(r io.Reader) ([]byte, error) {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, errBadRecord
+		}
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(h[0:])
+	if n > maxRecordSize {
+		return nil, errBadRecord
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errBadRecord
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(h[4:]) {
+		return nil, errBadRecord
+	}
+	return payload, nil
+}
+
+// op 是日志中记录的操作类型。
+type op byte
+
+const (
+	opOpen op = iota + 1
+	opDeposit
+	opWithdraw
+	opTransfer
+)
+
+// entry 是一条日志记录的内容。
+type entry struct {
+	seq    uint64
+	op     op
+	id     bank.ID
+	to     bank.ID // 仅用于opTransfer
+	amount int64
+}
+
+const entrySize = 8 + 1 + 8 + 8 + 8
+
+func (e *entry) marshal() []byte {
+	b := make([]byte, entrySize)
+	binary.LittleEndian.PutUint64(b[0:], e.seq)
+	b[8] = byte(e.op)
+	binary.LittleEndian.PutUint64(b[9:], uint64(e.id))
+	binary.LittleEndian.PutUint64(b[17:], uint64(e.to))
+	binary.LittleEndian.PutUint64(b[25:], uint64(e.amount))
+	return b
+}
+
+func (e *entry) unmarshal(b []byte) error {
+	if len(b) != entrySize {
+		return errBadRecord
+	}
+	e.seq = binary.LittleEndian.Uint64(b[0:])
+	e.op = op(b[8])
+	e.id = bank.ID(binary.LittleEndian.Uint64(b[9:]))
+	e.to = bank.ID(binary.LittleEndian.Uint64(b[17:]))
+	e.amount = int64(binary.LittleEndian.Uint64(b[25:]))
+	if e.op < opOpen || e.op > opTransfer {
+		return errBadRecord
+	}
+	return nil
+}
+
+// snapshot 是某一时刻所有账户的状态，seq是快照包含的最后一条日志记录的序号。
+type snapshot struct {
+	seq      uint64
+	next     bank.ID
+	balances map[bank.ID]int
+}
+
+func (s *snapshot) marshal() []byte {
+	b := make([]byte, 0, 20+16*len(s.balances))
+	b = binary.LittleEndian.AppendUint64(b, s.seq)
+	b = binary.LittleEndian.AppendUint64(b, uint64(s.next))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s.balances)))
+	for id, balance := range s.balances {
+		b = binary.LittleEndian.AppendUint64(b, uint64(id))
+		b = binary.LittleEndian.AppendUint64(b, uint64(balance))
+	}
+	return b
+}
+
+func (s *snapshot) unmarshal(b []byte) error {
+	if len(b) < 20 {
+		return errBadRecord
+	}
+	s.seq = binary.LittleEndian.Uint64(b[0:])
+	s.next = bank.ID(binary.LittleEndian.Uint64(b[8:]))
+	n := int(binary.LittleEndian.Uint32(b[16:]))
+	b = b[20:]
+	if len(b) != 16*n {
+		return errBadRecord
+	}
+	s.balances = make(map[bank.ID]int, n)
+	for i := 0; i < n; i++ {
+		id := bank.ID(binary.LittleEndian.Uint64(b[16*i:]))
+		s.balances[id] = int(int64(binary.LittleEndian.Uint64(b[16*i+8:])))
+	}
+	return nil
+}
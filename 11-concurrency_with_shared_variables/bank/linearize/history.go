@@ -0,0 +1,79 @@
+package linearize
+
+import (
+	"sync"
+	"time"
+)
+
+// Operation 是历史中的一个操作：从调用（invoke）开始，到返回（return）结束。
+// Call和Return是操作开始和结束的逻辑时间，它们来自Recorder中的同一个计数器，
+// 所以任何两个事件之间都有确定的先后顺序。
+type Operation[I, O any] struct {
+	Client int // 执行操作的客户端（通常对应一个goroutine）
+	Input  I
+	Output O
+	Call   int64
+	Return int64
+
+	CallTime, ReturnTime time.Duration // 相对于Recorder创建时刻的真实时间，只用于输出
+}
+
+// Recorder 记录并发执行的操作，它的方法可以被多个goroutine同时调用。
+//
+//	id := r.Invoke(client, input)
+//	output := doSomething(input)
+//	r.Return(id, output)
+type Recorder[I, O any] struct {
+	mu    sync.Mutex
+	start time.Time
+	clock int64
+	ops   []Operation[I, O]
+	done  []bool
+}
+
+// NewRecorder 返回一个空的Recorder。
+func NewRecorder[I, O any]() *Recorder[I, O] {
+	return &Recorder[I, O]{start: time.Now()}
+}
+
+// Invoke 记录一个操作的开始，返回用于Return的标识。
+// 必须在真正开始执行操作之前调用。
+func (r *Recorder[I, O]) Invoke(client int, input I) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock++
+	r.ops = append(r.ops, Operation[I, O]{
+		Client:   client,
+		Input:    input,
+		Call:     r.clock,
+		CallTime: time.Since(r.start),
+	})
+	r.done = append(r.done, false)
+	return len(r.ops) - 1
+}
+
+// Return 记录标识为id的操作的结束和它的输出。
+// 必须在操作真正结束之后调用。
+func (r *Recorder[I, O]) Return(id int, output O) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock++
+	op := &r.ops[id]
+	op.Output = output
+	op.Return = r.clock
+	op.ReturnTime = time.Since(r.start)
+	r.done[id] = true
+}
+
+// History 返回所有已经返回的操作。还没有返回的操作不包含在内。
+func (r *Recorder[I, O]) History() []Operation[I, O] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ops []Operation[I, O]
+	for i, op := range r.ops {
+		if r.done[i] {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
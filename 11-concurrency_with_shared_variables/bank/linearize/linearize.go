@@ -0,0 +1,160 @@
+// Package linearize 检查并发操作的历史是否是可线性化的（linearizable）。
+//
+// 01-race_conditions.go和02-mutex.go用文字解释了并发的存款、非原子的Withdraw会产生什么样的异常，
+// 这个包可以把这些异常找出来：用Recorder记录每个操作的调用和返回事件，
+// 再用Check判断是否存在一个与真实时间顺序一致的串行执行顺序，
+// 使得按这个顺序在串行模型（Model）上执行每个操作都能得到历史中记录的输出。
+// 如果一个操作在另一个操作开始之前就已经返回，那么它在串行顺序中也必须排在前面。
+//
+// 检查算法是Wing & Gong的回溯搜索，加上Lowe提出的、Porcupine也使用的缓存：
+// 如果已经尝试过“同一组操作已被线性化、模型处于同一状态”的情况，就不再重复搜索。
+package linearize
+
+import "sort"
+
+// Model 是被检查对象的串行规格说明。
+type Model[S comparable, I, O any] struct {
+	// Init 返回初始状态。
+	Init func() S
+	// Step 在状态s上执行输入为input的操作，如果串行执行时这个操作可能得到output，
+	// 返回true和操作之后的状态。
+	Step func(s S, input I, output O) (bool, S)
+}
+
+// entry 是按时间排序的事件链表中的一个节点。
+type entry struct {
+	id         int    // 操作在历史中的下标
+	call       bool   // 调用事件为true，返回事件为false
+	match      *entry // 调用事件对应的返回事件
+	prev, next *entry
+}
+
+// makeEntries 把操作转换为按时间排序的事件链表，返回链表的哑头节点。
+func makeEntries[I, O any](history []Operation[I, O]) *entry {
+	type event struct {
+		time int64
+		e    *entry
+	}
+	events := make([]event, 0, 2*len(history))
+	for i, op := range history {
+		ret := &entry{id: i}
+		call := &entry{id: i, call: true, match: ret}
+		events = append(events, event{op.Call, call}, event{op.Return, ret})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.time != b.time {
+			return a.time < b.time
+		}
+		// 时间相同时调用排在返回前面，把操作视为重叠的。
+		return a.e.call && !b.e.call
+	})
+
+	head := &entry{id: -1}
+	prev := head
+	for _, ev := range events {
+		prev.next = ev.e
+		ev.e.prev = prev
+		prev = ev.e
+	}
+	return head
+}
+
+// lift 把调用事件e和它的返回事件从链表中移除。
+func (e *entry) lift() {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+// unlift 把调用事件e和它的返回事件放回链表，是lift的逆操作。
+func (e *entry) unlift() {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// bitset 记录哪些操作已经被线性化。
+type bitset []uint64
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << (i % 64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << (i % 64) }
+
+func (b bitset) key() string {
+	buf := make([]byte, 8*len(b))
+	for i, w := range b {
+		for j := 0; j < 8; j++ {
+			buf[8*i+j] = byte(w >> (8 * j))
+		}
+	}
+	return string(buf)
+}
+
+// Check 判断history对于model是否是可线性化的。
+// 如果是，同时返回一个合法的线性化顺序（操作在history中的下标）。
+func Check[S comparable, I, O any](model Model[S, I, O], history []Operation[I, O]) (bool, []int) {
+	type frame struct {
+		e     *entry
+		state S // 执行e之前的状态
+	}
+	type cacheKey struct {
+		linearized string
+		state      S
+	}
+
+	head := makeEntries(history)
+	linearized := make(bitset, (len(history)+63)/64)
+	cache := make(map[cacheKey]bool)
+	var stack []frame
+
+	state := model.Init()
+	e := head.next
+	for head.next != nil {
+		if e.call {
+			op := history[e.id]
+			ok, next := model.Step(state, op.Input, op.Output)
+			if ok {
+				linearized.set(e.id)
+				key := cacheKey{linearized.key(), next}
+				if !cache[key] {
+					// 尝试把这个操作作为下一个线性化的操作。
+					cache[key] = true
+					stack = append(stack, frame{e, state})
+					state = next
+					e.lift()
+					e = head.next
+					continue
+				}
+				linearized.clear(e.id)
+			}
+			e = e.next
+			continue
+		}
+
+		// 遇到了一个返回事件：它对应的操作还没有被线性化，
+		// 而在它返回之后开始的操作都不能排在它前面，所以必须回溯。
+		if len(stack) == 0 {
+			return false, nil
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = top.state
+		linearized.clear(top.e.id)
+		top.e.unlift()
+		e = top.e.next
+	}
+
+	order := make([]int, len(stack))
+	for i, f := range stack {
+		order[i] = f.e.id
+	}
+	return true, order
+}
@@ -0,0 +1,226 @@
+package linearize
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// account 是02-mutex.go中的银行：一个由互斥锁保护的余额。
+// pause在非原子的Withdraw的步骤之间被调用：step为1时已经扣款、还没有检查余额；
+// step为2时已经检查余额、还没有恢复余额。测试用它来安排goroutine的交错执行。
+type account struct {
+	mu      sync.Mutex
+	balance int
+	pause   func(step int)
+}
+
+func (a *account) Deposit(amount int) {
+	a.mu.Lock()
+	a.balance += amount
+	a.mu.Unlock()
+}
+
+func (a *account) Balance() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.balance
+}
+
+// Withdraw 不是原子操作：它由三个各自加锁的步骤组成。
+func (a *account) Withdraw(amount int) bool {
+	a.Deposit(-amount)
+	a.step(1)
+	if a.Balance() < 0 {
+		a.step(2)
+		a.Deposit(amount)
+		return false // 余额不足
+	}
+	return true
+}
+
+func (a *account) step(n int) {
+	if a.pause != nil {
+		a.pause(n)
+	}
+}
+
+// Withdraw3 在同一个临界区内检查余额和扣款。
+func (a *account) Withdraw3(amount int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.balance -= amount
+	if a.balance < 0 {
+		a.balance += amount
+		return false // 余额不足
+	}
+	return true
+}
+
+type bankRecorder = Recorder[BankInput, BankOutput]
+
+// do 在账户上执行一个操作并记录它。
+func do(r *bankRecorder, client int, a *account, in BankInput, atomic bool) {
+	id := r.Invoke(client, in)
+	var out BankOutput
+	switch in.Op {
+	case Deposit:
+		a.Deposit(in.Amount)
+	case Withdraw:
+		if atomic {
+			out.OK = a.Withdraw3(in.Amount)
+		} else {
+			out.OK = a.Withdraw(in.Amount)
+		}
+	case Balance:
+		out.Balance = a.Balance()
+	}
+	r.Return(id, out)
+}
+
+func op(client int, in BankInput, out BankOutput, call, ret int64) Operation[BankInput, BankOutput] {
+	return Operation[BankInput, BankOutput]{Client: client, Input: in, Output: out, Call: call, Return: ret}
+}
+
+func TestCheck(t *testing.T) {
+	w := func(n int) BankInput { return BankInput{Op: Withdraw, Amount: n} }
+	d := func(n int) BankInput { return BankInput{Op: Deposit, Amount: n} }
+	b := BankInput{Op: Balance}
+	ok := BankOutput{OK: true}
+	fail := BankOutput{}
+	bal := func(n int) BankOutput { return BankOutput{Balance: n} }
+
+	for _, test := range []struct {
+		descr   string
+		history []Operation[BankInput, BankOutput]
+		want    bool
+	}{
+		{"empty", nil, true},
+		{"sequential", []Operation[BankInput, BankOutput]{
+			op(0, d(50), ok, 1, 2),
+			op(0, w(200), fail, 3, 4),
+			op(0, w(100), ok, 5, 6),
+			op(0, b, bal(50), 7, 8),
+		}, true},
+		{"stale read after return", []Operation[BankInput, BankOutput]{
+			op(0, d(50), ok, 1, 2),
+			op(1, b, bal(100), 3, 4),
+		}, false},
+		{"concurrent read may see either", []Operation[BankInput, BankOutput]{
+			op(0, d(50), ok, 1, 4),
+			op(1, b, bal(100), 2, 3),
+			op(2, b, bal(150), 2, 5),
+		}, true},
+		{"reads disagree on order", []Operation[BankInput, BankOutput]{
+			op(0, d(50), ok, 1, 6),
+			op(1, b, bal(150), 2, 3),
+			op(1, b, bal(100), 4, 5),
+		}, false},
+		{"both withdrawals fail", []Operation[BankInput, BankOutput]{
+			op(0, w(70), fail, 1, 4),
+			op(1, w(50), fail, 2, 3),
+		}, false},
+		{"one withdrawal succeeds", []Operation[BankInput, BankOutput]{
+			op(0, w(70), fail, 1, 4),
+			op(1, w(50), ok, 2, 3),
+		}, true},
+	} {
+		got, order := Check(BankModel(100), test.history)
+		if got != test.want {
+			t.Errorf("%s: Check = %v, want %v", test.descr, got, test.want)
+		}
+		if got && len(order) != len(test.history) {
+			t.Errorf("%s: order %v does not cover all operations", test.descr, order)
+		}
+	}
+}
+
+// twoWithdrawals 让两个goroutine同时从余额为100的账户中分别取70和50，返回记录的历史。
+// 对于非原子的Withdraw，两个goroutine都扣款之后才检查余额，都检查完之后才恢复余额：
+// 两次检查都看到余额为-20，所以两次取款都失败。这是任何串行执行都不可能得到的结果。
+func twoWithdrawals(atomic bool) []Operation[BankInput, BankOutput] {
+	a := &account{balance: 100}
+	var barriers [3]sync.WaitGroup
+	barriers[1].Add(2)
+	barriers[2].Add(2)
+	a.pause = func(step int) {
+		barriers[step].Done()
+		barriers[step].Wait()
+	}
+	r := NewRecorder[BankInput, BankOutput]()
+	var wg sync.WaitGroup
+	for client, amount := range []int{70, 50} {
+		wg.Add(1)
+		go func(client, amount int) {
+			defer wg.Done()
+			do(r, client, a, BankInput{Op: Withdraw, Amount: amount}, atomic)
+		}(client, amount)
+	}
+	wg.Wait()
+	return r.History()
+}
+
+func TestWithdrawIsNotLinearizable(t *testing.T) {
+	if ok, _ := Check(BankModel(100), twoWithdrawals(false)); ok {
+		t.Error("history of concurrent Withdraw is linearizable, want a violation")
+	}
+}
+
+func TestWithdraw3IsLinearizable(t *testing.T) {
+	if ok, _ := Check(BankModel(100), twoWithdrawals(true)); !ok {
+		t.Error("history of concurrent Withdraw3 is not linearizable")
+	}
+}
+
+// TestWithdrawTransientBalance 让查询余额的操作发生在非原子的Withdraw扣款之后、恢复余额之前，
+// 这时它会看到一个负数的余额。
+func TestWithdrawTransientBalance(t *testing.T) {
+	a := &account{balance: 100}
+	r := NewRecorder[BankInput, BankOutput]()
+	paused, resume := make(chan struct{}), make(chan struct{})
+	a.pause = func(step int) {
+		if step == 1 {
+			close(paused)
+			<-resume
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		do(r, 0, a, BankInput{Op: Withdraw, Amount: 200}, false)
+	}()
+	<-paused
+	do(r, 1, a, BankInput{Op: Balance}, false)
+	close(resume)
+	<-done
+
+	if ok, _ := Check(BankModel(100), r.History()); ok {
+		t.Errorf("history %v is linearizable, want a violation", r.History())
+	}
+}
+
+// TestRandomWithdraw3 随机地并发执行存款、Withdraw3和查询，得到的历史总是可线性化的。
+func TestRandomWithdraw3(t *testing.T) {
+	const clients = 4
+	const ops = 25
+	for seed := int64(0); seed < 20; seed++ {
+		a := &account{balance: 50}
+		r := NewRecorder[BankInput, BankOutput]()
+		var wg sync.WaitGroup
+		for c := 0; c < clients; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(seed*clients + int64(c)))
+				for i := 0; i < ops; i++ {
+					in := BankInput{Op: BankOp(rng.Intn(3)), Amount: rng.Intn(40) + 1}
+					do(r, c, a, in, true)
+				}
+			}(c)
+		}
+		wg.Wait()
+		if ok, _ := Check(BankModel(50), r.History()); !ok {
+			t.Fatalf("seed %d: history of Withdraw3 is not linearizable", seed)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package linearize
+
+import "fmt"
+
+// BankOp 是银行账户上的操作类型。
+type BankOp int
+
+const (
+	Deposit BankOp = iota
+	Withdraw
+	Balance
+)
+
+// BankInput 是账户操作的输入。
+type BankInput struct {
+	Op     BankOp
+	Amount int // Balance忽略这个字段
+}
+
+// BankOutput 是账户操作的输出。
+type BankOutput struct {
+	OK      bool // Withdraw是否成功
+	Balance int  // Balance返回的余额
+}
+
+func (in BankInput) String() string {
+	switch in.Op {
+	case Deposit:
+		return fmt.Sprintf("Deposit(%d)", in.Amount)
+	case Withdraw:
+		return fmt.Sprintf("Withdraw(%d)", in.Amount)
+	default:
+		return "Balance()"
+	}
+}
+
+// BankModel 是02-mutex.go中单个账户的串行规格说明：
+// 存款总是成功；余额足够时取款成功并扣除金额，否则失败且余额不变；
+// 查询返回当前余额，余额永远不会是负数。
+func BankModel(initial int) Model[int, BankInput, BankOutput] {
+	return Model[int, BankInput, BankOutput]{
+		Init: func() int { return initial },
+		Step: func(balance int, in BankInput, out BankOutput) (bool, int) {
+			switch in.Op {
+			case Deposit:
+				return true, balance + in.Amount
+			case Withdraw:
+				if balance >= in.Amount {
+					return out.OK, balance - in.Amount
+				}
+				return !out.OK, balance
+			default:
+				return out.Balance == balance, balance
+			}
+		},
+	}
+}
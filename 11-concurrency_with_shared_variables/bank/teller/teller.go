@@ -0,0 +1,242 @@
+// Package teller 用监控goroutine（monitor goroutine）实现一个银行账户。
+//
+// 01-race_conditions.go中的teller只支持存款和查询余额，由init启动后永远运行。
+// 这里的Teller使用请求/响应的协议：每个请求带着一个回复channel发送给监控goroutine，
+// 余额和交易历史只被这个goroutine访问，所以不需要任何锁。
+// 由于所有请求都在同一个goroutine中按顺序执行，一个包含多个步骤的事务（Transaction）也是原子的。
+package teller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"bank"
+)
+
+// ErrStopped 表示Teller已经停止。
+var ErrStopped = errors.New("teller: stopped")
+
+// Record 是交易历史中的一条记录。
+type Record struct {
+	Seq     uint64 // 从1开始的序号
+	Kind    string // "deposit" 或 "withdraw"
+	Amount  int
+	Balance int  // 操作之后的余额
+	OK      bool // 取款因余额不足失败时为false
+}
+
+func (r Record) String() string {
+	status := "ok"
+	if !r.OK {
+		status = "failed"
+	}
+	return fmt.Sprintf("#%d %s %d %s, balance=%d", r.Seq, r.Kind, r.Amount, status, r.Balance)
+}
+
+// Teller 是一个由监控goroutine管理的账户。
+type Teller struct {
+	requests chan request
+	stop     chan struct{} // Stop关闭它来通知监控goroutine退出
+	stopped  chan struct{} // 监控goroutine退出时关闭
+	stopOnce sync.Once
+}
+
+type request struct {
+	f     func(s *state) error
+	reply chan<- response
+}
+
+type response struct {
+	err   error
+	panic interface{} // f中发生的panic，转交给调用者所在的goroutine
+}
+
+// state 是被限制在监控goroutine中的变量。
+type state struct {
+	balance int
+	seq     uint64
+	history []Record // 环形缓冲区，最多保存cap(history)条记录
+	start   int      // 最早一条记录在history中的下标
+}
+
+// New 创建一个余额为0的账户，并启动它的监控goroutine。
+// 交易历史最多保存historySize条记录，更早的记录会被丢弃。
+func New(historySize int) *Teller {
+	if historySize < 0 {
+		historySize = 0
+	}
+	t := &Teller{
+		requests: make(chan request),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.monitor(&state{history: make([]Record, 0, historySize)})
+	return t
+}
+
+// monitor 是监控goroutine，它是唯一可以访问s的goroutine。
+func (t *Teller) monitor(s *state) {
+	defer close(t.stopped)
+	for {
+		select {
+		case req := <-t.requests:
+			req.reply <- s.run(req.f)
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// run 执行f，f中发生的panic不能让监控goroutine退出。
+func (s *state) run(f func(s *state) error) (resp response) {
+	defer func() {
+		if p := recover(); p != nil {
+			resp.panic = p
+		}
+	}()
+	return response{err: f(s)}
+}
+
+func (s *state) record(kind string, amount int, ok bool) {
+	s.seq++
+	r := Record{Seq: s.seq, Kind: kind, Amount: amount, Balance: s.balance, OK: ok}
+	switch {
+	case cap(s.history) == 0:
+	case len(s.history) < cap(s.history):
+		s.history = append(s.history, r)
+	default:
+		s.history[s.start] = r
+		s.start = (s.start + 1) % len(s.history)
+	}
+}
+
+// do 把f发送给监控goroutine执行并等待结果。
+func (t *Teller) do(f func(s *state) error) error {
+	reply := make(chan response, 1)
+	select {
+	case t.requests <- request{f, reply}:
+	case <-t.stop:
+		return ErrStopped
+	}
+	resp := <-reply // 被接收的请求一定会得到回复
+	if resp.panic != nil {
+		panic(resp.panic)
+	}
+	return resp.err
+}
+
+// Deposit 存入amount。
+func (t *Teller) Deposit(amount int) error {
+	if amount <= 0 {
+		return bank.ErrInvalidAmount
+	}
+	return t.do(func(s *state) error {
+		s.balance += amount
+		s.record("deposit", amount, true)
+		return nil
+	})
+}
+
+// Withdraw 取出amount，余额不足时返回bank.ErrInsufficientFunds。
+func (t *Teller) Withdraw(amount int) error {
+	if amount <= 0 {
+		return bank.ErrInvalidAmount
+	}
+	return t.do(func(s *state) error {
+		if s.balance < amount {
+			s.record("withdraw", amount, false)
+			return insufficient(s.balance, amount)
+		}
+		s.balance -= amount
+		s.record("withdraw", amount, true)
+		return nil
+	})
+}
+
+// Balance 返回余额。
+func (t *Teller) Balance() (int, error) {
+	var balance int
+	err := t.do(func(s *state) error {
+		balance = s.balance
+		return nil
+	})
+	return balance, err
+}
+
+// History 返回最近的n条交易记录，从早到晚排列；n<=0时返回保存的所有记录。
+func (t *Teller) History(n int) ([]Record, error) {
+	var records []Record
+	err := t.do(func(s *state) error {
+		total := len(s.history)
+		if n <= 0 || n > total {
+			n = total
+		}
+		records = make([]Record, n)
+		for i := range records {
+			records[i] = s.history[(s.start+total-n+i)%total]
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Tx 是事务中可以执行的操作，它只在传给Transaction的函数执行期间有效。
+type Tx struct {
+	balance int
+	ops     []Record
+}
+
+// Deposit 在事务中存入amount。
+func (tx *Tx) Deposit(amount int) error {
+	if amount <= 0 {
+		return bank.ErrInvalidAmount
+	}
+	tx.balance += amount
+	tx.ops = append(tx.ops, Record{Kind: "deposit", Amount: amount, Balance: tx.balance, OK: true})
+	return nil
+}
+
+// Withdraw 在事务中取出amount，余额不足时返回bank.ErrInsufficientFunds。
+func (tx *Tx) Withdraw(amount int) error {
+	if amount <= 0 {
+		return bank.ErrInvalidAmount
+	}
+	if tx.balance < amount {
+		return insufficient(tx.balance, amount)
+	}
+	tx.balance -= amount
+	tx.ops = append(tx.ops, Record{Kind: "withdraw", Amount: amount, Balance: tx.balance, OK: true})
+	return nil
+}
+
+// Balance 返回事务中当前的余额。
+func (tx *Tx) Balance() int { return tx.balance }
+
+// Transaction 在监控goroutine中执行f，f中的所有操作作为一个整体原子地生效：
+// 其它请求不会插入到f的执行过程中。如果f返回错误或者panic，f中的所有操作都被撤销。
+// f中不能再调用Teller的方法，否则监控goroutine会等待它自己，造成死锁。
+func (t *Teller) Transaction(f func(tx *Tx) error) error {
+	return t.do(func(s *state) error {
+		tx := &Tx{balance: s.balance}
+		if err := f(tx); err != nil {
+			return err
+		}
+		for _, op := range tx.ops {
+			s.balance = op.Balance
+			s.record(op.Kind, op.Amount, true)
+		}
+		return nil
+	})
+}
+
+// Stop 停止监控goroutine，并等待它退出。之后所有方法都返回ErrStopped。
+// Stop可以被多次调用。
+func (t *Teller) Stop() {
+	t.stopOnce.Do(func() { close(t.stop) })
+	<-t.stopped
+}
+
+func insufficient(balance, amount int) error {
+	return fmt.Errorf("%w: balance is %d, need %d", bank.ErrInsufficientFunds, balance, amount)
+}
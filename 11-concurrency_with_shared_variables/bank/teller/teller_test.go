@@ -0,0 +1,205 @@
+package teller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"bank"
+)
+
+func TestTeller(t *testing.T) {
+	teller := New(10)
+	defer teller.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			teller.Deposit(2)
+		}()
+	}
+	wg.Wait()
+	if b, err := teller.Balance(); b != 200 || err != nil {
+		t.Errorf("Balance() = %d, %v, want 200", b, err)
+	}
+
+	if err := teller.Withdraw(150); err != nil {
+		t.Errorf("Withdraw(150) = %v", err)
+	}
+	if err := teller.Withdraw(51); !errors.Is(err, bank.ErrInsufficientFunds) {
+		t.Errorf("Withdraw(51) = %v, want ErrInsufficientFunds", err)
+	}
+	if err := teller.Deposit(0); !errors.Is(err, bank.ErrInvalidAmount) {
+		t.Errorf("Deposit(0) = %v, want ErrInvalidAmount", err)
+	}
+	if b, _ := teller.Balance(); b != 50 {
+		t.Errorf("Balance() = %d, want 50", b)
+	}
+}
+
+// TestConcurrentWithdraw 检查并发的取款不会让余额变成负数。
+func TestConcurrentWithdraw(t *testing.T) {
+	teller := New(0)
+	defer teller.Stop()
+	teller.Deposit(100)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if teller.Withdraw(3) == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 33 {
+		t.Errorf("%d withdrawals succeeded, want 33", succeeded)
+	}
+	if b, _ := teller.Balance(); b != 1 {
+		t.Errorf("Balance() = %d, want 1", b)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	teller := New(3)
+	defer teller.Stop()
+	teller.Deposit(10)
+	teller.Deposit(20)
+	teller.Withdraw(100)
+	teller.Withdraw(5)
+
+	records, err := teller.History(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range records {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"#2 deposit 20 ok, balance=30",
+		"#3 withdraw 100 failed, balance=30",
+		"#4 withdraw 5 ok, balance=25",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("History(0) = %q, want %q", got, want)
+	}
+
+	records, _ = teller.History(1)
+	if len(records) != 1 || records[0].Seq != 4 {
+		t.Errorf("History(1) = %v, want the last record", records)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	teller := New(10)
+	defer teller.Stop()
+	teller.Deposit(100)
+
+	// 成功的事务：所有操作都生效。
+	err := teller.Transaction(func(tx *Tx) error {
+		if err := tx.Withdraw(60); err != nil {
+			return err
+		}
+		return tx.Deposit(10)
+	})
+	if err != nil {
+		t.Fatalf("Transaction: %v", err)
+	}
+	if b, _ := teller.Balance(); b != 50 {
+		t.Errorf("Balance() = %d after transaction, want 50", b)
+	}
+
+	// 失败的事务：第一次取款成功，第二次取款失败，整个事务被撤销。
+	err = teller.Transaction(func(tx *Tx) error {
+		if err := tx.Withdraw(30); err != nil {
+			return err
+		}
+		return tx.Withdraw(30)
+	})
+	if !errors.Is(err, bank.ErrInsufficientFunds) {
+		t.Errorf("Transaction = %v, want ErrInsufficientFunds", err)
+	}
+	if b, _ := teller.Balance(); b != 50 {
+		t.Errorf("Balance() = %d after failed transaction, want 50", b)
+	}
+	if records, _ := teller.History(0); len(records) != 3 {
+		t.Errorf("History(0) has %d records, want 3: %v", len(records), records)
+	}
+}
+
+// TestTransactionAtomic 检查其它请求看不到事务的中间状态。
+func TestTransactionAtomic(t *testing.T) {
+	teller := New(0)
+	defer teller.Stop()
+	teller.Deposit(100)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			teller.Transaction(func(tx *Tx) error {
+				tx.Withdraw(100)
+				return tx.Deposit(100)
+			})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if b, _ := teller.Balance(); b != 100 {
+			t.Fatalf("Balance() = %d during transactions, want 100", b)
+		}
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	teller := New(0)
+	defer teller.Stop()
+	teller.Deposit(100)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recover() = %v, want boom", p)
+			}
+		}()
+		teller.Transaction(func(tx *Tx) error {
+			tx.Withdraw(100)
+			panic("boom")
+		})
+	}()
+	// 监控goroutine仍在运行，并且事务被撤销。
+	if b, err := teller.Balance(); b != 100 || err != nil {
+		t.Errorf("Balance() = %d, %v after panic, want 100", b, err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	teller := New(0)
+	teller.Deposit(1)
+	teller.Stop()
+	teller.Stop() // 可以多次调用
+
+	if err := teller.Deposit(1); err != ErrStopped {
+		t.Errorf("Deposit after Stop = %v, want ErrStopped", err)
+	}
+	if _, err := teller.Balance(); err != ErrStopped {
+		t.Errorf("Balance after Stop = %v, want ErrStopped", err)
+	}
+	if _, err := teller.History(0); err != ErrStopped {
+		t.Errorf("History after Stop = %v, want ErrStopped", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Package cake 模拟一个由多个工序组成的蛋糕店流水线。
+//
+// 01-race_conditions.go 中的baker和icer演示了串行受限（serial confinement）：
+// 蛋糕的指针沿着channel从一个工序传递到下一个工序，每个工序把蛋糕交出去以后就不再访问它。
+// 这个包把它变成一个可以运行的模拟器：每个工序都有可配置的平均工作时间、标准差和输出缓冲大小，
+// 运行结束后报告吞吐量、每个工序的利用率以及输出队列的占用情况。
+//
+// 每个工序的工作时间由独立的、以Seed派生的随机数发生器预先生成，
+// 所以相同的配置每次运行时每块蛋糕在每个工序上的工作时间都是一样的。
+package cake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"text/tabwriter"
+	"time"
+)
+
+// Cake 是在流水线上传递的蛋糕，同一时刻只有一个工序会访问它。
+type Cake struct {
+	ID    int
+	State string
+}
+
+// Stage 描述流水线上的一个工序。
+type Stage struct {
+	Name   string        // 工序名称，同时也是蛋糕经过该工序后的状态
+	Mean   time.Duration // 平均工作时间
+	StdDev time.Duration // 工作时间的标准差
+	Buffer int           // 输出channel的缓冲大小，0表示无缓冲
+}
+
+// DefaultStages 返回书中的三个工序：烘焙（baker）、糖霜（icer）和题字（inscriber）。
+func DefaultStages() []Stage {
+	return []Stage{
+		{Name: "baked", Mean: 10 * time.Millisecond},
+		{Name: "iced", Mean: 10 * time.Millisecond},
+		{Name: "inscribed", Mean: 10 * time.Millisecond},
+	}
+}
+
+// Shop 是一次模拟的配置。
+type Shop struct {
+	Cakes  int     // 需要生产的蛋糕数量
+	Seed   int64   // 随机数种子
+	Stages []Stage // 工序，为空时使用DefaultStages
+}
+
+// StageStats 是一个工序的统计信息。
+type StageStats struct {
+	Name        string
+	Busy        time.Duration // 工作的总时间
+	Starved     time.Duration // 等待上游送来蛋糕的总时间
+	Blocked     time.Duration // 等待下游接收蛋糕的总时间
+	Utilization float64       // Busy占整个模拟时间的比例
+	QueueCap    int           // 输出channel的容量
+	QueueMean   float64       // 每次发送前输出channel中蛋糕的平均数量
+	QueueMax    int           // 每次发送前输出channel中蛋糕的最大数量
+}
+
+// Stats 是一次模拟的统计信息。
+type Stats struct {
+	Cakes      int
+	Elapsed    time.Duration
+	Throughput float64 // 每秒生产的蛋糕数
+	Stages     []StageStats
+}
+
+// Run 运行一次模拟，在所有蛋糕都经过最后一个工序后返回统计信息。
+func (s *Shop) Run() (*Stats, error) {
+	stages := s.Stages
+	if len(stages) == 0 {
+		stages = DefaultStages()
+	}
+	if s.Cakes <= 0 {
+		return nil, errors.New("cake: number of cakes must be positive")
+	}
+	for _, st := range stages {
+		if st.Buffer < 0 || st.Mean < 0 || st.StdDev < 0 {
+			return nil, fmt.Errorf("cake: invalid stage %q", st.Name)
+		}
+	}
+
+	// 预先生成每个工序的工作时间，避免随机数的开销计入模拟时间。
+	plans := make([][]time.Duration, len(stages))
+	for i, st := range stages {
+		plans[i] = workTimes(st, s.Cakes, s.Seed+int64(i))
+	}
+
+	stats := make([]StageStats, len(stages))
+	done := make(chan struct{}, len(stages))
+	start := time.Now()
+
+	var in chan *Cake // 第一个工序没有上游
+	for i, st := range stages {
+		out := make(chan *Cake, st.Buffer)
+		go runStage(&stats[i], st, plans[i], s.Cakes, in, out, done)
+		in = out
+	}
+	for range in {
+		// 最后一个工序之后是橱窗，直接取走蛋糕
+	}
+	elapsed := time.Since(start)
+	for range stages {
+		<-done
+	}
+
+	for i := range stats {
+		stats[i].Utilization = float64(stats[i].Busy) / float64(elapsed)
+	}
+	return &Stats{
+		Cakes:      s.Cakes,
+		Elapsed:    elapsed,
+		Throughput: float64(s.Cakes) / elapsed.Seconds(),
+		Stages:     stats,
+	}, nil
+}
+
+// runStage 处理n块蛋糕：如果in为nil，则由它生产新的蛋糕。
+func runStage(st *StageStats, stage Stage, plan []time.Duration, n int,
+	in <-chan *Cake, out chan<- *Cake, done chan<- struct{}) {
+	defer func() { done <- struct{}{} }()
+	defer close(out)
+
+	st.Name = stage.Name
+	st.QueueCap = cap(out)
+	var queued int
+	for i := 0; i < n; i++ {
+		var cake *Cake
+		if in == nil {
+			cake = &Cake{ID: i}
+		} else {
+			t := time.Now()
+			cake = <-in
+			st.Starved += time.Since(t)
+		}
+
+		t := time.Now()
+		time.Sleep(plan[i])
+		cake.State = stage.Name
+		st.Busy += time.Since(t)
+
+		if q := len(out); q > st.QueueMax {
+			st.QueueMax = q
+		}
+		queued += len(out)
+		t = time.Now()
+		out <- cake // 该工序不再访问这块蛋糕
+		st.Blocked += time.Since(t)
+	}
+	st.QueueMean = float64(queued) / float64(n)
+}
+
+// workTimes 返回一个工序处理n块蛋糕各自需要的工作时间，服从截断于0的正态分布。
+func workTimes(st Stage, n int, seed int64) []time.Duration {
+	rng := rand.New(rand.NewSource(seed))
+	times := make([]time.Duration, n)
+	for i := range times {
+		d := st.Mean + time.Duration(rng.NormFloat64()*float64(st.StdDev))
+		if d < 0 {
+			d = 0
+		}
+		times[i] = d
+	}
+	return times
+}
+
+// Report 以表格的形式输出统计信息。
+func (s *Stats) Report(w io.Writer) error {
+	fmt.Fprintf(w, "%d cakes in %v, %.1f cakes/s\n",
+		s.Cakes, s.Elapsed.Round(time.Millisecond), s.Throughput)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "stage\tbusy\tstarved\tblocked\tutil\tqueue cap\tqueue mean\tqueue max\t")
+	for _, st := range s.Stages {
+		fmt.Fprintf(tw, "%s\t%v\t%v\t%v\t%.1f%%\t%d\t%.2f\t%d\t\n",
+			st.Name,
+			st.Busy.Round(time.Millisecond),
+			st.Starved.Round(time.Millisecond),
+			st.Blocked.Round(time.Millisecond),
+			st.Utilization*100,
+			st.QueueCap, st.QueueMean, st.QueueMax)
+	}
+	return tw.Flush()
+}
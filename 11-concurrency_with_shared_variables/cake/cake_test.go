@@ -0,0 +1,126 @@
+package cake
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkTimesDeterministic(t *testing.T) {
+	st := Stage{Mean: time.Millisecond, StdDev: 500 * time.Microsecond}
+	a := workTimes(st, 100, 42)
+	b := workTimes(st, 100, 42)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("workTimes with the same seed differ")
+	}
+	if reflect.DeepEqual(a, workTimes(st, 100, 43)) {
+		t.Errorf("workTimes with different seeds are equal")
+	}
+	for i, d := range a {
+		if d < 0 {
+			t.Errorf("workTimes[%d] = %v, want >= 0", i, d)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	shop := Shop{
+		Cakes: 20,
+		Seed:  1,
+		Stages: []Stage{
+			{Name: "baked", Mean: time.Millisecond, Buffer: 2},
+			{Name: "iced", Mean: 3 * time.Millisecond, Buffer: 5},
+			{Name: "inscribed", Mean: time.Millisecond},
+		},
+	}
+	stats, err := shop.Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Cakes != 20 || stats.Throughput <= 0 {
+		t.Errorf("Run = %d cakes at %.1f/s", stats.Cakes, stats.Throughput)
+	}
+	if len(stats.Stages) != 3 {
+		t.Fatalf("got %d stage stats, want 3", len(stats.Stages))
+	}
+	for _, st := range stats.Stages {
+		if st.Utilization <= 0 || st.Utilization > 1 {
+			t.Errorf("%s utilization = %.2f, want (0, 1]", st.Name, st.Utilization)
+		}
+		if st.QueueMax > st.QueueCap {
+			t.Errorf("%s queue max = %d, exceeds capacity %d", st.Name, st.QueueMax, st.QueueCap)
+		}
+	}
+	// 糖霜是瓶颈，所以烘焙的输出队列会积压，而糖霜的输出队列几乎总是空的。
+	if baked, iced := stats.Stages[0], stats.Stages[1]; baked.QueueMean <= iced.QueueMean {
+		t.Errorf("baked queue mean %.2f <= iced queue mean %.2f", baked.QueueMean, iced.QueueMean)
+	}
+
+	var buf bytes.Buffer
+	if err := stats.Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"baked", "iced", "inscribed", "cakes/s"} {
+		if !strings.Contains(buf.String(), name) {
+			t.Errorf("report does not mention %q:\n%s", name, buf.String())
+		}
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	for _, shop := range []Shop{
+		{Cakes: 0},
+		{Cakes: 1, Stages: []Stage{{Name: "baked", Buffer: -1}}},
+		{Cakes: 1, Stages: []Stage{{Name: "baked", Mean: -time.Second}}},
+	} {
+		if _, err := shop.Run(); err == nil {
+			t.Errorf("Run(%+v) succeeded, want error", shop)
+		}
+	}
+}
+
+/*
+缓冲区的大小对吞吐量的影响：
+  - 如果各工序的速度相同且没有波动，缓冲区对吞吐量没有帮助；
+  - 如果工作时间有波动，缓冲区可以平滑各工序之间的速度差异，从而提高吞吐量；
+  - 如果某一个工序总是比它的上游慢，缓冲区只会被填满，吞吐量由最慢的工序决定。
+*/
+func benchmarkShop(b *testing.B, stddev time.Duration, icerMean time.Duration) {
+	for _, buf := range []int{0, 1, 10, 100} {
+		b.Run(fmt.Sprintf("buf=%d", buf), func(b *testing.B) {
+			shop := Shop{
+				Cakes: 50,
+				Seed:  1,
+				Stages: []Stage{
+					{Name: "baked", Mean: time.Millisecond, StdDev: stddev, Buffer: buf},
+					{Name: "iced", Mean: icerMean, StdDev: stddev, Buffer: buf},
+					{Name: "inscribed", Mean: time.Millisecond, StdDev: stddev},
+				},
+			}
+			var cakes float64
+			for i := 0; i < b.N; i++ {
+				stats, err := shop.Run()
+				if err != nil {
+					b.Fatal(err)
+				}
+				cakes += stats.Throughput
+			}
+			b.ReportMetric(cakes/float64(b.N), "cakes/s")
+		})
+	}
+}
+
+func BenchmarkBuffers(b *testing.B) {
+	benchmarkShop(b, 0, time.Millisecond)
+}
+
+func BenchmarkVariable(b *testing.B) {
+	benchmarkShop(b, time.Millisecond, time.Millisecond)
+}
+
+func BenchmarkSlowIcer(b *testing.B) {
+	benchmarkShop(b, 0, 2*time.Millisecond)
+}
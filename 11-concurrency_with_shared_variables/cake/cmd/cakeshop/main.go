@@ -0,0 +1,48 @@
+// Cakeshop 运行一次蛋糕店流水线的模拟并输出统计信息。
+//
+//	$ go run ./cmd/cakeshop -cakes 100 -ice 20ms -icebuf 10
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"cake"
+)
+
+var (
+	cakes = flag.Int("cakes", 50, "number of cakes")
+	seed  = flag.Int64("seed", 1, "random seed")
+
+	bake       = flag.Duration("bake", 10*time.Millisecond, "mean baking time")
+	bakeStdDev = flag.Duration("bakestddev", 0, "standard deviation of baking time")
+	bakeBuf    = flag.Int("bakebuf", 0, "buffer between baker and icer")
+
+	ice       = flag.Duration("ice", 10*time.Millisecond, "mean icing time")
+	iceStdDev = flag.Duration("icestddev", 0, "standard deviation of icing time")
+	iceBuf    = flag.Int("icebuf", 0, "buffer between icer and inscriber")
+
+	inscribe       = flag.Duration("inscribe", 10*time.Millisecond, "mean inscribing time")
+	inscribeStdDev = flag.Duration("inscribestddev", 0, "standard deviation of inscribing time")
+)
+
+func main() {
+	flag.Parse()
+	shop := cake.Shop{
+		Cakes: *cakes,
+		Seed:  *seed,
+		Stages: []cake.Stage{
+			{Name: "baked", Mean: *bake, StdDev: *bakeStdDev, Buffer: *bakeBuf},
+			{Name: "iced", Mean: *ice, StdDev: *iceStdDev, Buffer: *iceBuf},
+			{Name: "inscribed", Mean: *inscribe, StdDev: *inscribeStdDev},
+		},
+	}
+	stats, err := shop.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cakeshop: %v\n", err)
+		os.Exit(1)
+	}
+	stats.Report(os.Stdout)
+}
@@ -0,0 +1,3 @@
+module cake
+
+go 1.19
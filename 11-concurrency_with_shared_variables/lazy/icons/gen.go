@@ -0,0 +1,90 @@
+//go:build ignore
+
+// gen 生成png目录下的四个花色图标。运行方法：go generate ./...
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const size = 32
+
+var (
+	black = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	red   = color.RGBA{0xd0, 0x10, 0x20, 0xff}
+)
+
+// shape 报告以图标中心为原点、边长归一化为2的坐标(x, y)是否在图形内，y轴向上。
+type shape func(x, y float64) bool
+
+func circle(cx, cy, r float64) shape {
+	return func(x, y float64) bool { return math.Hypot(x-cx, y-cy) <= r }
+}
+
+func union(shapes ...shape) shape {
+	return func(x, y float64) bool {
+		for _, s := range shapes {
+			if s(x, y) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// heart 是两个圆和一个向下的三角形。
+func heart(x, y float64) bool {
+	lobes := union(circle(-0.38, 0.3, 0.4), circle(0.38, 0.3, 0.4))
+	return lobes(x, y) || (y <= 0.35 && y >= -0.85 && math.Abs(x) <= 0.76*(y+0.85)/1.2)
+}
+
+// spade 是倒过来的心形加一个底座。
+func spade(x, y float64) bool {
+	stem := y >= -0.9 && y <= -0.3 && math.Abs(x) <= 0.1+0.3*(-0.3-y)
+	return heart(x, -y*1.1+0.05) || stem
+}
+
+func diamond(x, y float64) bool {
+	return math.Abs(x)/0.7+math.Abs(y)/0.9 <= 1
+}
+
+func club(x, y float64) bool {
+	stem := y >= -0.9 && y <= -0.2 && math.Abs(x) <= 0.08+0.3*(-0.2-y)
+	return union(circle(0, 0.42, 0.33), circle(-0.38, -0.12, 0.33), circle(0.38, -0.12, 0.33), circle(0, 0.05, 0.2))(x, y) || stem
+}
+
+func draw(name string, s shape, c color.Color) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			x := (float64(px)+0.5)/size*2 - 1
+			y := 1 - (float64(py)+0.5)/size*2
+			if s(x, y) {
+				img.Set(px, py, c)
+			}
+		}
+	}
+	f, err := os.Create(filepath.Join("png", name))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	draw("spades.png", spade, black)
+	draw("hearts.png", heart, red)
+	draw("diamonds.png", diamond, red)
+	draw("clubs.png", club, black)
+}
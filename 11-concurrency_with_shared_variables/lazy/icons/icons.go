@@ -0,0 +1,72 @@
+// Package icons 是05-lazy_initialization.go中图标加载器的改写：
+// 图标是用//go:embed嵌入程序的真实PNG文件，在第一次使用时解码。
+// 解码失败时Icon返回错误，并在一段退避时间之后重试，而不是像Icon4那样永远得到nil。
+package icons
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"lazy"
+)
+
+//go:embed png/*.png
+var files embed.FS
+
+// readFile 读取嵌入的文件，测试中会替换它来注入失败。
+var readFile = func(name string) ([]byte, error) { return fs.ReadFile(files, name) }
+
+var icons = lazy.NewErr(loadIcons, lazy.Backoff(100*time.Millisecond, 10*time.Second))
+
+// loadIcons 解码所有嵌入的图标。
+func loadIcons() (map[string]image.Image, error) {
+	names, err := fs.Glob(files, "png/*.png")
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]image.Image)
+	for _, name := range names {
+		data, err := readFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("loading icons: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("loading icons: decoding %s: %v", name, err)
+		}
+		m[path.Base(name)] = img
+	}
+	return m, nil
+}
+
+// Icon 返回名为name的图标，比如"spades.png"。第一次调用时加载所有图标。
+func Icon(name string) (image.Image, error) {
+	m, err := icons.Get()
+	if err != nil {
+		return nil, err
+	}
+	img, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("icon %q not found", name)
+	}
+	return img, nil
+}
+
+// Names 返回所有图标的名字，按字母顺序排列。
+func Names() []string {
+	names, _ := fs.Glob(files, "png/*.png")
+	for i, name := range names {
+		names[i] = path.Base(name)
+	}
+	sort.Strings(names)
+	return names
+}
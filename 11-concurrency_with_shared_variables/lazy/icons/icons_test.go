@@ -0,0 +1,97 @@
+package icons
+
+import (
+	"errors"
+	"image/color"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIcon(t *testing.T) {
+	icons.Reset()
+	want := []string{"clubs.png", "diamonds.png", "hearts.png", "spades.png"}
+	if got := Names(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+	var wg sync.WaitGroup
+	for _, name := range want {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			img, err := Icon(name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+				t.Errorf("%s: bounds %v", name, b)
+			}
+			// 图标中心是花色的颜色：红心和方块是红色，黑桃和梅花是黑色。
+			r, _, _, a := img.At(16, 16).RGBA()
+			isRed := r > 0x8000
+			if a == 0 || isRed != (name == "hearts.png" || name == "diamonds.png") {
+				t.Errorf("%s: center pixel %v", name, color.RGBAModel.Convert(img.At(16, 16)))
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if _, err := Icon("joker.png"); err == nil {
+		t.Error("Icon(joker.png) succeeded")
+	}
+}
+
+// TestFailingFirstLoad 注入一次失败的加载：Icon返回错误，退避期间不会重试，之后重试成功。
+func TestFailingFirstLoad(t *testing.T) {
+	orig := readFile
+	defer func() {
+		readFile = orig
+		icons.Reset()
+	}()
+	var mu sync.Mutex
+	reads := 0
+	fail := true
+	readFile = func(name string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reads++
+		if fail {
+			fail = false
+			return nil, errors.New("disk on fire")
+		}
+		return orig(name)
+	}
+	icons.Reset()
+
+	if _, err := Icon("spades.png"); err == nil || !strings.Contains(err.Error(), "disk on fire") {
+		t.Fatalf("first Icon error = %v, want the injected failure", err)
+	}
+	if _, err := Icon("spades.png"); err == nil {
+		t.Fatal("Icon during backoff succeeded")
+	}
+	if reads != 1 {
+		t.Fatalf("%d reads during backoff, want 1", reads)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := Icon("spades.png"); err != nil {
+		t.Fatalf("Icon after backoff: %v", err)
+	}
+}
+
+// TestCorruptIcon 检查损坏的PNG会报告解码错误，而不是返回nil图标。
+func TestCorruptIcon(t *testing.T) {
+	orig := readFile
+	defer func() {
+		readFile = orig
+		icons.Reset()
+	}()
+	readFile = func(name string) ([]byte, error) { return []byte("not a png"), nil }
+	icons.Reset()
+
+	if _, err := Icon("hearts.png"); err == nil || !strings.Contains(err.Error(), "decoding") {
+		t.Errorf("Icon error = %v, want a decoding error", err)
+	}
+}
@@ -0,0 +1,199 @@
+// Package lazy 提供泛型的延迟初始化值，用来代替05-lazy_initialization.go中的sync.Once。
+//
+// Icon4用sync.Once调用loadIcons，这有两个问题：loadIcons无法报告错误；
+// 而且即使它失败了（或者panic了），Once也认为初始化已经完成，之后再也不会重试。
+// LazyErr[T]的初始化函数可以返回错误，失败之后是否以及何时重试由RetryPolicy决定。
+// 初始化函数panic时，正在等待这次初始化的每一个goroutine都会以相同的值panic，而不是得到零值。
+//
+// 与sync.Once一样，初始化成功之后Get只需要一次原子读取。
+package lazy
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy 决定初始化失败之后是否重试。failures是到目前为止连续失败的次数（从1开始），
+// err是最近一次的错误，初始化函数panic时err为ErrPanicked。
+// retry为false时错误被永久缓存；否则在wait之后的第一次Get会重试，
+// 在此之前的Get直接返回最近一次的错误（或者以最近一次的值panic）。
+type RetryPolicy func(failures int, err error) (wait time.Duration, retry bool)
+
+// ErrPanicked 是初始化函数panic时传给RetryPolicy的错误。
+var ErrPanicked = errors.New("lazy: initialization panicked")
+
+// NeverRetry 永久缓存第一次的错误，与sync.Once的行为相同。
+func NeverRetry(failures int, err error) (time.Duration, bool) { return 0, false }
+
+// RetryImmediately 在下一次Get时立即重试。
+func RetryImmediately(failures int, err error) (time.Duration, bool) { return 0, true }
+
+// Backoff 返回一个指数退避的RetryPolicy：第n次失败之后等待base*2^(n-1)，最多等待max。
+func Backoff(base, max time.Duration) RetryPolicy {
+	return func(failures int, err error) (time.Duration, bool) {
+		wait := base
+		for i := 1; i < failures && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			wait = max
+		}
+		return wait, true
+	}
+}
+
+// result 是一次初始化的结果。
+type result[T any] struct {
+	value    T
+	err      error
+	panicked bool
+	p        any // panic的值
+}
+
+func (r *result[T]) get() (T, error) {
+	if r.panicked {
+		panic(r.p)
+	}
+	return r.value, r.err
+}
+
+// call 是一次进行中的初始化。
+type call[T any] struct {
+	done chan struct{} // 初始化完成时关闭
+	res  result[T]
+}
+
+// LazyErr 是一个延迟初始化、初始化可能失败的值。
+type LazyErr[T any] struct {
+	f      func() (T, error)
+	policy RetryPolicy
+
+	// res 是最终结果：成功，或者按policy不再重试的失败。为nil时还没有最终结果。
+	res atomic.Pointer[result[T]]
+
+	mu       sync.Mutex
+	call     *call[T]  // 进行中的初始化
+	gen      int       // 每次Reset加一，用来丢弃Reset之前开始的初始化的结果
+	failures int       // 连续失败的次数
+	last     result[T] // 最近一次失败的结果
+	retryAt  time.Time // 在此之前不重试
+}
+
+// NewErr 返回一个用f初始化的LazyErr，失败时按policy重试。policy为nil时与NeverRetry相同。
+func NewErr[T any](f func() (T, error), policy RetryPolicy) *LazyErr[T] {
+	return &LazyErr[T]{f: f, policy: policy}
+}
+
+// Get 返回初始化的结果，必要时调用初始化函数。并发的Get共享同一次初始化。
+// 如果初始化函数panic，这次初始化的所有等待者都会以相同的值panic。
+func (l *LazyErr[T]) Get() (T, error) {
+	if r := l.res.Load(); r != nil {
+		return r.get()
+	}
+
+	l.mu.Lock()
+	if r := l.res.Load(); r != nil {
+		l.mu.Unlock()
+		return r.get()
+	}
+	if c := l.call; c != nil {
+		l.mu.Unlock()
+		<-c.done
+		return c.res.get()
+	}
+	if l.failures > 0 && time.Now().Before(l.retryAt) {
+		last := l.last
+		l.mu.Unlock()
+		return last.get()
+	}
+	c := &call[T]{done: make(chan struct{})}
+	l.call = c
+	gen := l.gen
+	l.mu.Unlock()
+
+	c.res = l.run()
+
+	l.mu.Lock()
+	if gen == l.gen {
+		l.finish(c.res)
+	}
+	l.mu.Unlock()
+	close(c.done)
+	return c.res.get()
+}
+
+// run 调用初始化函数，把panic记录在结果中。
+func (l *LazyErr[T]) run() (res result[T]) {
+	defer func() {
+		if p := recover(); p != nil {
+			res = result[T]{panicked: true, p: p}
+		}
+	}()
+	res.value, res.err = l.f()
+	return res
+}
+
+// finish 记录初始化的结果。调用者必须持有l.mu。
+func (l *LazyErr[T]) finish(res result[T]) {
+	l.call = nil
+	if !res.panicked && res.err == nil {
+		l.res.Store(&res)
+		return
+	}
+	l.failures++
+	l.last = res
+	err := res.err
+	if res.panicked {
+		err = ErrPanicked
+	}
+	policy := l.policy
+	if policy == nil {
+		policy = NeverRetry
+	}
+	wait, retry := policy(l.failures, err)
+	if !retry {
+		l.res.Store(&res)
+		return
+	}
+	l.retryAt = time.Now().Add(wait)
+}
+
+// Reset 丢弃初始化的结果，下一次Get会重新初始化。主要用于测试。
+// 进行中的初始化仍然会把结果交给它的等待者，但不会被记录下来。
+func (l *LazyErr[T]) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gen++
+	l.call = nil
+	l.failures = 0
+	l.last = result[T]{}
+	l.retryAt = time.Time{}
+	l.res.Store(nil)
+}
+
+// Lazy 是一个延迟初始化、初始化不会返回错误的值。
+// 初始化函数panic时，等待者都会panic，下一次Get会重试。
+type Lazy[T any] struct {
+	l LazyErr[T]
+}
+
+// New 返回一个用f初始化的Lazy。
+func New[T any](f func() T) *Lazy[T] {
+	return &Lazy[T]{LazyErr[T]{
+		f:      func() (T, error) { return f(), nil },
+		policy: RetryImmediately,
+	}}
+}
+
+// Get 返回初始化的值，必要时调用初始化函数。
+func (l *Lazy[T]) Get() T {
+	v, _ := l.l.Get()
+	return v
+}
+
+// Reset 丢弃初始化的值，下一次Get会重新初始化。主要用于测试。
+func (l *Lazy[T]) Reset() {
+	l.l.Reset()
+}
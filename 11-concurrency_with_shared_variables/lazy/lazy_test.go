@@ -0,0 +1,225 @@
+package lazy
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLazy(t *testing.T) {
+	var calls atomic.Int32
+	l := New(func() int {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return 42
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := l.Get(); v != 42 {
+				t.Errorf("Get() = %d, want 42", v)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("initialized %d times, want 1", n)
+	}
+
+	l.Reset()
+	if v := l.Get(); v != 42 || calls.Load() != 2 {
+		t.Errorf("after Reset: Get() = %d after %d calls", v, calls.Load())
+	}
+}
+
+// flaky 返回一个前fail次调用失败的初始化函数。
+func flaky(fail int32, calls *atomic.Int32) func() (string, error) {
+	return func() (string, error) {
+		if calls.Add(1) <= fail {
+			return "", errors.New("transient failure")
+		}
+		return "ok", nil
+	}
+}
+
+func TestNeverRetry(t *testing.T) {
+	for _, policy := range []RetryPolicy{NeverRetry, nil} { // nil与NeverRetry相同
+		var calls atomic.Int32
+		l := NewErr(flaky(1, &calls), policy)
+		for i := 0; i < 3; i++ {
+			if _, err := l.Get(); err == nil {
+				t.Fatal("Get succeeded; the first error should be cached")
+			}
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("initialized %d times, want 1", n)
+		}
+	}
+}
+
+func TestRetryImmediately(t *testing.T) {
+	var calls atomic.Int32
+	l := NewErr(flaky(2, &calls), RetryImmediately)
+	for i := 0; i < 2; i++ {
+		if _, err := l.Get(); err == nil {
+			t.Fatalf("Get #%d succeeded", i+1)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := l.Get(); err != nil || v != "ok" {
+			t.Fatalf("Get = %q, %v", v, err)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("initialized %d times, want 3", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Backoff(10*time.Millisecond, 50*time.Millisecond)
+	for i, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if wait, retry := p(i+1, nil); !retry || wait != want*time.Millisecond {
+			t.Errorf("failure %d: wait %v, want %v", i+1, wait, want*time.Millisecond)
+		}
+	}
+
+	var calls atomic.Int32
+	l := NewErr(flaky(1, &calls), Backoff(20*time.Millisecond, time.Second))
+	if _, err := l.Get(); err == nil {
+		t.Fatal("first Get succeeded")
+	}
+	// 退避期间返回缓存的错误，不调用初始化函数。
+	if _, err := l.Get(); err == nil || calls.Load() != 1 {
+		t.Fatalf("Get during backoff = %v after %d calls", err, calls.Load())
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, err := l.Get(); err != nil || v != "ok" || calls.Load() != 2 {
+		t.Fatalf("Get after backoff = %q, %v after %d calls", v, err, calls.Load())
+	}
+}
+
+// TestPanic 检查初始化函数的panic传递给这次初始化的每一个等待者。
+func TestPanic(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	l := New(func() int {
+		if calls.Add(1) == 1 {
+			<-release
+			panic("boom")
+		}
+		return 1
+	})
+
+	const waiters = 5
+	panics := make(chan any, waiters)
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { panics <- recover() }()
+			l.Get()
+		}()
+	}
+	// 等所有goroutine都在等待同一次初始化之后再让它panic。
+	for {
+		l.l.mu.Lock()
+		started := l.l.call != nil
+		l.l.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(panics)
+	for p := range panics {
+		if p != "boom" {
+			t.Errorf("waiter recovered %v, want boom", p)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("initialized %d times, want 1", n)
+	}
+	// Lazy在panic之后重试。
+	if v := l.Get(); v != 1 {
+		t.Errorf("Get after panic = %d, want 1", v)
+	}
+}
+
+func TestPanicNeverRetry(t *testing.T) {
+	var gotErr error
+	l := NewErr(func() (int, error) { panic("boom") }, func(failures int, err error) (time.Duration, bool) {
+		gotErr = err
+		return 0, false
+	})
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if p := recover(); p != "boom" {
+					t.Errorf("Get #%d recovered %v, want boom", i+1, p)
+				}
+			}()
+			l.Get()
+		}()
+	}
+	if gotErr != ErrPanicked {
+		t.Errorf("policy got %v, want ErrPanicked", gotErr)
+	}
+}
+
+// TestResetDuringInit 检查Reset之前开始的初始化的结果不会被记录。
+func TestResetDuringInit(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	l := NewErr(func() (int32, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			<-release
+		}
+		return n, nil
+	}, NeverRetry)
+
+	done := make(chan int32)
+	go func() {
+		v, _ := l.Get()
+		done <- v
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Reset()
+	close(release)
+	if v := <-done; v != 1 {
+		t.Errorf("waiter of the old initialization got %d, want 1", v)
+	}
+	if v, _ := l.Get(); v != 2 {
+		t.Errorf("Get after Reset = %d, want 2", v)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	l := New(func() int { return 1 })
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Get()
+		}
+	})
+}
+
+func BenchmarkOnce(b *testing.B) {
+	var once sync.Once
+	var v int
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			once.Do(func() { v = 1 })
+			_ = v
+		}
+	})
+}
@@ -0,0 +1,262 @@
+// Package deadlock 提供一个调试用的互斥锁，它在可能发生死锁时立即报告，而不是等到程序卡住。
+//
+// 02-mutex.go中的Withdraw2在持有mu时调用Deposit，而Deposit又要获取mu。
+// Go的互斥锁不是可重入的，所以Withdraw2会永远阻塞，只有程序卡住时才能发现。
+// deadlock.Mutex记录每个goroutine持有的锁，并维护一个全局的加锁顺序图：
+// 如果一个goroutine在持有A时获取B，就在图中加入边A→B。它能发现两类问题：
+//
+//   - 重入：goroutine获取一个它已经持有的锁，比如Withdraw2；
+//   - 加锁顺序相反：一个goroutine按A、B的顺序加锁，另一个按B、A的顺序加锁（ABBA），
+//     这时加入新的边会在图中形成环。即使这两次加锁没有真的同时发生，它们迟早会死锁。
+//
+// 发现问题时，报告中包含当前加锁的栈和之前相冲突的加锁的栈。
+// 获取goroutine的ID和栈都需要调用runtime.Stack，开销很大，所以只应该在调试和测试时使用。
+package deadlock
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mutex 是检测死锁的互斥锁。零值是未加锁的Mutex，使用之后不能再复制。
+type Mutex struct {
+	// Name 出现在报告中，为空时使用Mutex的地址。
+	Name string
+
+	mu sync.Mutex
+}
+
+func (m *Mutex) String() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return fmt.Sprintf("mutex %p", m)
+}
+
+// Acquisition 是一次加锁。
+type Acquisition struct {
+	Lock      string // 锁的名字
+	Goroutine int64
+	Stack     string // 加锁时的栈
+}
+
+// Report 描述一个可能的死锁。
+type Report struct {
+	Kind string // "reentrant lock"或"lock order inversion"
+	// Current 是触发报告的加锁。
+	Current Acquisition
+	// Previous 是与Current冲突的加锁。对于重入，它是之前获取同一个锁的加锁；
+	// 对于加锁顺序相反，它们是建立环上其它边的加锁，每一次都是在持有前一个锁时获取后一个锁。
+	Previous []Acquisition
+	// Held 是当前goroutine持有的、与Current形成环的锁是在哪里获取的。重入时为空。
+	Held *Acquisition
+}
+
+func (r *Report) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "deadlock: %s: goroutine %d acquiring %s", r.Kind, r.Current.Goroutine, r.Current.Lock)
+	if r.Held != nil {
+		fmt.Fprintf(&b, " while holding %s", r.Held.Lock)
+	}
+	fmt.Fprintf(&b, "\n\n%s\n", r.Current.Stack)
+	if r.Held != nil {
+		fmt.Fprintf(&b, "\n%s was acquired at:\n%s\n", r.Held.Lock, r.Held.Stack)
+	}
+	for _, a := range r.Previous {
+		fmt.Fprintf(&b, "\ngoroutine %d previously acquired %s at:\n%s\n", a.Goroutine, a.Lock, a.Stack)
+	}
+	return b.String()
+}
+
+// OnDeadlock 在发现可能的死锁时被调用，调用时没有持有任何内部的锁。
+// 为nil时（默认）以*Report为参数panic。
+// 如果OnDeadlock返回，加锁会继续进行：对于重入，这意味着永远阻塞。
+var OnDeadlock func(*Report)
+
+// edge 是加锁顺序图中的一条边from→to，acq是在持有from时获取to的加锁。
+type edge struct {
+	to  *Mutex
+	acq Acquisition
+}
+
+// heldLock 是goroutine持有的一个锁。
+type heldLock struct {
+	m   *Mutex
+	acq Acquisition
+}
+
+// state 是所有Mutex共享的检测状态。
+var state struct {
+	sync.Mutex
+	held  map[int64][]heldLock // goroutine ID -> 按加锁顺序持有的锁
+	order map[*Mutex][]edge    // 加锁顺序图
+}
+
+// Reset 丢弃加锁顺序图和所有持有记录。只应在没有锁被持有时调用，比如测试之间。
+func Reset() {
+	state.Lock()
+	defer state.Unlock()
+	state.held = nil
+	state.order = nil
+}
+
+// Lock 加锁。如果这次加锁可能导致死锁，先报告再加锁。
+func (m *Mutex) Lock() {
+	cur := acquisition(m)
+	if r := check(m, cur); r != nil {
+		if OnDeadlock == nil {
+			panic(r)
+		}
+		OnDeadlock(r)
+	}
+	m.mu.Lock()
+
+	state.Lock()
+	defer state.Unlock()
+	if state.held == nil {
+		state.held = make(map[int64][]heldLock)
+	}
+	state.held[cur.Goroutine] = append(state.held[cur.Goroutine], heldLock{m, cur})
+}
+
+// Unlock 解锁。与sync.Mutex一样，锁可以由另一个goroutine释放。
+func (m *Mutex) Unlock() {
+	state.Lock()
+	release(m, goid())
+	state.Unlock()
+	m.mu.Unlock()
+}
+
+// release 从持有者的记录中删除m，优先查找goroutine g。
+func release(m *Mutex, g int64) {
+	if removeHeld(g, m) {
+		return
+	}
+	for other := range state.held {
+		if removeHeld(other, m) {
+			return
+		}
+	}
+}
+
+func removeHeld(g int64, m *Mutex) bool {
+	held := state.held[g]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i].m != m {
+			continue
+		}
+		if len(held) == 1 {
+			delete(state.held, g)
+		} else {
+			state.held[g] = append(held[:i:i], held[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// check 检查当前goroutine获取m是否可能导致死锁，并把新的加锁顺序加入图中。
+func check(m *Mutex, cur Acquisition) *Report {
+	state.Lock()
+	defer state.Unlock()
+	held := state.held[cur.Goroutine]
+	for _, h := range held {
+		if h.m == m {
+			return &Report{Kind: "reentrant lock", Current: cur, Previous: []Acquisition{h.acq}}
+		}
+	}
+	for _, h := range held {
+		if hasEdge(h.m, m) {
+			continue
+		}
+		// 加入h→m之前，如果已经存在从m到h的路径，加入后就形成了环。
+		if path := findPath(m, h.m, map[*Mutex]bool{}); path != nil {
+			acq := h.acq
+			return &Report{Kind: "lock order inversion", Current: cur, Previous: path, Held: &acq}
+		}
+		if state.order == nil {
+			state.order = make(map[*Mutex][]edge)
+		}
+		state.order[h.m] = append(state.order[h.m], edge{to: m, acq: cur})
+	}
+	return nil
+}
+
+func hasEdge(from, to *Mutex) bool {
+	for _, e := range state.order[from] {
+		if e.to == to {
+			return true
+		}
+	}
+	return false
+}
+
+// findPath 用深度优先搜索在加锁顺序图中查找从from到to的路径，返回路径上每条边的加锁。
+func findPath(from, to *Mutex, seen map[*Mutex]bool) []Acquisition {
+	seen[from] = true
+	for _, e := range state.order[from] {
+		if e.to == to {
+			return []Acquisition{e.acq}
+		}
+		if seen[e.to] {
+			continue
+		}
+		if path := findPath(e.to, to, seen); path != nil {
+			return append([]Acquisition{e.acq}, path...)
+		}
+	}
+	return nil
+}
+
+// acquisition 记录当前goroutine获取m的栈。
+func acquisition(m *Mutex) Acquisition {
+	stack := stack()
+	return Acquisition{Lock: m.String(), Goroutine: parseGoid(stack), Stack: string(bytes.TrimRight(stack, "\n"))}
+}
+
+// stack 返回当前goroutine的栈，去掉了deadlock包自己的栈帧。
+func stack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return trimStack(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// trimStack 删除栈顶部属于deadlock包的栈帧，即从runtime.Stack到Mutex.Lock的部分。
+// 第一行是“goroutine N [running]:”，之后每个栈帧占两行：函数和文件位置。
+func trimStack(s []byte) []byte {
+	lines := bytes.Split(s, []byte("\n"))
+	for i := 1; i+1 < len(lines); i += 2 {
+		if bytes.Contains(lines[i], []byte("deadlock.(*Mutex).Lock(")) {
+			return bytes.Join(append(lines[:1:1], lines[i+2:]...), []byte("\n"))
+		}
+	}
+	return s
+}
+
+// goid 返回当前goroutine的ID。
+// Go故意不提供goroutine的ID，唯一的办法是解析runtime.Stack输出的第一行“goroutine 123 [running]:”。
+func goid() int64 {
+	var buf [64]byte
+	return parseGoid(buf[:runtime.Stack(buf[:], false)])
+}
+
+func parseGoid(stack []byte) int64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, err := strconv.ParseInt(string(stack), 10, 64)
+	if err != nil {
+		panic("deadlock: cannot parse goroutine ID: " + err.Error())
+	}
+	return id
+}
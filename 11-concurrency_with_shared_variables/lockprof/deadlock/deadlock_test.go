@@ -0,0 +1,191 @@
+package deadlock
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// expectReport 调用f，返回f中的加锁panic时给出的报告。
+func expectReport(t *testing.T, f func()) (r *Report) {
+	t.Helper()
+	defer func() {
+		v := recover()
+		if v == nil {
+			t.Fatal("no deadlock reported")
+		}
+		var ok bool
+		if r, ok = v.(*Report); !ok {
+			panic(v)
+		}
+	}()
+	f()
+	return nil
+}
+
+// 02-mutex.go中的银行，使用deadlock.Mutex。
+var (
+	mu      = Mutex{Name: "bank"}
+	balance int
+)
+
+func Deposit(amount int) {
+	mu.Lock()
+	defer mu.Unlock()
+	deposit(amount)
+}
+
+func deposit(amount int) { balance += amount }
+
+// Withdraw2 是02-mutex.go中错误的实现：持有mu时调用Deposit。
+func Withdraw2(amount int) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	Deposit(-amount)
+	if balance < 0 {
+		Deposit(amount)
+		return false
+	}
+	return true
+}
+
+func TestWithdraw2(t *testing.T) {
+	defer Reset()
+	Deposit(100)
+	r := expectReport(t, func() { Withdraw2(50) })
+	if r.Kind != "reentrant lock" || r.Current.Lock != "bank" || len(r.Previous) != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	// 当前的栈是Withdraw2调用Deposit，之前的栈是Withdraw2自己加锁。
+	if !strings.Contains(r.Current.Stack, "deadlock.Deposit(") || !strings.Contains(r.Current.Stack, "deadlock.Withdraw2(") {
+		t.Errorf("current stack:\n%s", r.Current.Stack)
+	}
+	if strings.Contains(r.Previous[0].Stack, "deadlock.Deposit(") || !strings.Contains(r.Previous[0].Stack, "deadlock.Withdraw2(") {
+		t.Errorf("previous stack:\n%s", r.Previous[0].Stack)
+	}
+	// deadlock包自己的栈帧不出现在报告中。
+	if strings.Contains(r.Current.Stack, "(*Mutex).Lock") {
+		t.Errorf("stack not trimmed:\n%s", r.Current.Stack)
+	}
+	t.Log(r)
+
+	// 报告之后Withdraw2的defer释放了mu，锁仍然可以正常使用。
+	Deposit(1)
+}
+
+func lockBoth(first, second *Mutex) {
+	first.Lock()
+	second.Lock()
+	second.Unlock()
+	first.Unlock()
+}
+
+// TestABBA 中两个goroutine以相反的顺序获取两个锁。
+// 它们先后执行，并没有真的死锁，但检测器在第二个goroutine获取a时立即报告。
+func TestABBA(t *testing.T) {
+	defer Reset()
+	a, b := &Mutex{Name: "A"}, &Mutex{Name: "B"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lockBoth(a, b)
+	}()
+	<-done
+
+	var r *Report
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		defer b.Unlock() // panic时释放已经获得的b
+		r = expectReport(t, func() { lockBoth(b, a) })
+	}()
+	<-done
+
+	if r.Kind != "lock order inversion" || r.Current.Lock != "A" || r.Held == nil || r.Held.Lock != "B" {
+		t.Fatalf("report = %+v", r)
+	}
+	if len(r.Previous) != 1 || r.Previous[0].Lock != "B" || r.Previous[0].Goroutine == r.Current.Goroutine {
+		t.Fatalf("previous = %+v, want B acquired by the other goroutine", r.Previous)
+	}
+	for _, stack := range []string{r.Current.Stack, r.Previous[0].Stack} {
+		if !strings.Contains(stack, "deadlock.lockBoth(") {
+			t.Errorf("stack:\n%s", stack)
+		}
+	}
+	if msg := r.Error(); !strings.Contains(msg, "acquiring A while holding B") {
+		t.Errorf("Error() = %s", msg)
+	}
+}
+
+// TestCycle 检查经过多个锁的环：A→B，B→C，然后C→A。
+func TestCycle(t *testing.T) {
+	defer Reset()
+	a, b, c := &Mutex{Name: "A"}, &Mutex{Name: "B"}, &Mutex{Name: "C"}
+	lockBoth(a, b)
+	lockBoth(b, c)
+
+	var reports []*Report
+	OnDeadlock = func(r *Report) { reports = append(reports, r) }
+	defer func() { OnDeadlock = nil }()
+	lockBoth(c, a) // OnDeadlock返回，加锁继续进行
+
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	r := reports[0]
+	var path []string
+	for _, p := range r.Previous {
+		path = append(path, p.Lock)
+	}
+	if got := strings.Join(path, ","); got != "B,C" {
+		t.Errorf("path = %s, want B,C (A→B, B→C)", got)
+	}
+}
+
+func TestConsistentOrder(t *testing.T) {
+	defer Reset()
+	a, b, c := &Mutex{}, &Mutex{}, &Mutex{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a.Lock()
+				b.Lock()
+				c.Lock()
+				c.Unlock()
+				b.Unlock()
+				a.Unlock()
+				lockBoth(a, c)
+				lockBoth(b, c)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestUnlockByOtherGoroutine 检查由另一个goroutine释放的锁不再被记为原持有者持有。
+func TestUnlockByOtherGoroutine(t *testing.T) {
+	defer Reset()
+	var m Mutex
+	m.Lock()
+	done := make(chan struct{})
+	go func() {
+		m.Unlock()
+		close(done)
+	}()
+	<-done
+	m.Lock() // 不是重入
+	m.Unlock()
+}
+
+func TestGoid(t *testing.T) {
+	ids := make(chan int64, 2)
+	ids <- goid()
+	go func() { ids <- goid() }()
+	if a, b := <-ids, <-ids; a == b || a <= 0 || b <= 0 {
+		t.Errorf("goroutine IDs %d and %d", a, b)
+	}
+}
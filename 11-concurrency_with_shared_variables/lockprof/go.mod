@@ -0,0 +1,3 @@
+module lockprof
+
+go 1.19
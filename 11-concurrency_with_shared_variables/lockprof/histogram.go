@@ -0,0 +1,104 @@
+package lockprof
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// buckets 是直方图的桶数。第i个桶统计[2^i, 2^(i+1))纳秒之间的时长，
+// 第0个桶还包括0，最后一个桶包括所有更长的时长（2^39纳秒约为9分钟）。
+const buckets = 40
+
+// histogram 是一个以2的幂为边界的时长直方图。
+// 所有字段都用原子操作更新，所以记录一次时长不需要加锁。
+type histogram struct {
+	count  atomic.Int64
+	total  atomic.Int64 // 纳秒
+	max    atomic.Int64 // 纳秒
+	bucket [buckets]atomic.Int64
+}
+
+func bucketOf(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	i := bits.Len64(uint64(d)) - 1
+	if i >= buckets {
+		i = buckets - 1
+	}
+	return i
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.count.Add(1)
+	h.total.Add(int64(d))
+	h.bucket[bucketOf(d)].Add(1)
+	for {
+		max := h.max.Load()
+		if int64(d) <= max || h.max.CompareAndSwap(max, int64(d)) {
+			break
+		}
+	}
+}
+
+// HistogramSnapshot 是直方图在某一时刻的状态。
+// 因为各个字段是分别读取的，并发记录时它们之间可能相差几次观测。
+type HistogramSnapshot struct {
+	Count   int64
+	Total   time.Duration
+	Max     time.Duration
+	Buckets [buckets]int64
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Count: h.count.Load(),
+		Total: time.Duration(h.total.Load()),
+		Max:   time.Duration(h.max.Load()),
+	}
+	for i := range h.bucket {
+		s.Buckets[i] = h.bucket[i].Load()
+	}
+	return s
+}
+
+// Mean 返回平均时长。
+func (s HistogramSnapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Quantile 返回p分位数的上界，即第一个累计比例达到p的桶的上边界，
+// 误差不超过一倍。结果不会超过Max。
+func (s HistogramSnapshot) Quantile(p float64) time.Duration {
+	var n int64
+	for _, c := range s.Buckets {
+		n += c
+	}
+	if n == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(p * float64(n)))
+	if target < 1 {
+		target = 1
+	}
+	var cum int64
+	for i, c := range s.Buckets {
+		cum += c
+		if cum >= target {
+			upper := time.Duration(1)<<(i+1) - 1
+			if upper > s.Max {
+				upper = s.Max
+			}
+			return upper
+		}
+	}
+	return s.Max
+}
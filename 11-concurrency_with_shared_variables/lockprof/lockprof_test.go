@@ -0,0 +1,268 @@
+package lockprof
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// findSite 返回函数名以suffix结尾、行号为line的调用点。
+func findSite(t *testing.T, p *Profile, suffix string, line int) SiteStats {
+	t.Helper()
+	for _, s := range p.Sites() {
+		if strings.HasSuffix(s.Function, suffix) && s.Line == line {
+			return s
+		}
+	}
+	t.Fatalf("no site %s:%d in %+v", suffix, line, p.Sites())
+	return SiteStats{}
+}
+
+func line() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+func TestCallSite(t *testing.T) {
+	p := NewProfile()
+	m := Mutex{Profile: p}
+	var l int
+	for i := 0; i < 3; i++ {
+		l = line() + 1
+		m.Lock()
+		m.Unlock()
+	}
+	s := findSite(t, p, ".TestCallSite", l)
+	if s.Kind != "lock" || s.Acquisitions() != 3 || s.Contended != 0 || s.Hold.Count != 3 {
+		t.Errorf("site = %+v, want 3 uncontended acquisitions", s)
+	}
+	if !strings.HasSuffix(s.File, "lockprof_test.go") {
+		t.Errorf("File = %q", s.File)
+	}
+	if len(Default.Sites()) != 0 {
+		t.Errorf("Default profile has sites %+v", Default.Sites())
+	}
+}
+
+func TestWaitAndHold(t *testing.T) {
+	const hold = 20 * time.Millisecond
+	p := NewProfile()
+	m := Mutex{Profile: p}
+
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	holderLine := line() + 2
+	go func() {
+		m.Lock()
+		close(locked)
+		time.Sleep(hold)
+		m.Unlock()
+		close(done)
+	}()
+	<-locked
+	waiterLine := line() + 1
+	m.Lock()
+	m.Unlock()
+	<-done
+
+	holder := findSite(t, p, ".TestWaitAndHold.func1", holderLine)
+	if holder.Hold.Max < hold {
+		t.Errorf("holder hold max = %v, want >= %v", holder.Hold.Max, hold)
+	}
+	waiter := findSite(t, p, ".TestWaitAndHold", waiterLine)
+	if waiter.Contended != 1 {
+		t.Errorf("waiter contended = %d, want 1", waiter.Contended)
+	}
+	if waiter.Wait.Max < hold/2 {
+		t.Errorf("waiter wait max = %v, want about %v", waiter.Wait.Max, hold)
+	}
+	// 等待时间最长的调用点排在最前面。
+	if top := p.Top(1); len(top) != 1 || top[0].Line != waiterLine {
+		t.Errorf("Top(1) = %+v, want the waiter", top)
+	}
+}
+
+func TestRWMutex(t *testing.T) {
+	p := NewProfile()
+	rw := RWMutex{Profile: p}
+
+	readLine := line() + 1
+	rw.RLock()
+	rw.RLock()
+	if rw.TryLock() {
+		t.Fatal("TryLock succeeded while readers hold the lock")
+	}
+	time.Sleep(10 * time.Millisecond)
+	rw.RUnlock()
+	rw.RUnlock()
+	writeLine := line() + 1
+	rw.Lock()
+	rw.Unlock()
+
+	r := findSite(t, p, ".TestRWMutex", readLine)
+	// 两个读者组成一段读期间，只记录一次持有时间。
+	if r.Kind != "rlock" || r.Acquisitions() != 1 || r.Hold.Count != 1 || r.Hold.Max < 10*time.Millisecond {
+		t.Errorf("read site = %+v", r)
+	}
+	w := findSite(t, p, ".TestRWMutex", writeLine)
+	if w.Kind != "lock" || w.Acquisitions() != 1 || w.Hold.Count != 1 {
+		t.Errorf("write site = %+v", w)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i) * time.Microsecond)
+	}
+	s := h.snapshot()
+	if s.Count != 100 || s.Max != 100*time.Microsecond || s.Mean() != 50500*time.Nanosecond {
+		t.Errorf("snapshot = %+v", s)
+	}
+	// 2的幂的桶，估计值是真实值的1到2倍之间。
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{{0.5, 50 * time.Microsecond}, {0.9, 90 * time.Microsecond}, {1, 100 * time.Microsecond}} {
+		if got := s.Quantile(c.p); got < c.want || got > 2*c.want {
+			t.Errorf("Quantile(%g) = %v, want in [%v, %v]", c.p, got, c.want, 2*c.want)
+		}
+	}
+	if got := (HistogramSnapshot{}).Quantile(0.5); got != 0 {
+		t.Errorf("empty Quantile = %v", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	p := NewProfile()
+	m := Mutex{Profile: p}
+	m.Lock()
+	m.Unlock()
+
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "?n=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "lock lockprof.TestReport (lockprof_test.go:") {
+		t.Errorf("report:\n%s", b)
+	}
+	resp, err = srv.Client().Get(srv.URL + "?n=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("bad n: status %d, want 400", resp.StatusCode)
+	}
+
+	// expvar不能重复发布同一个名字，用-count运行多次时换一个名字。
+	name := "lockprof_test"
+	for i := 1; expvar.Get(name) != nil; i++ {
+		name = fmt.Sprintf("lockprof_test%d", i)
+	}
+	p.Publish(name, 10)
+	var sites []jsonSite
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &sites); err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 1 || sites[0].Acquired != 1 || !strings.Contains(sites[0].Site, "TestReport") {
+		t.Errorf("expvar = %+v", sites)
+	}
+}
+
+// TestStress 用go test -race运行，检查Mutex和RWMutex仍然提供互斥。
+func TestStress(t *testing.T) {
+	p := NewProfile()
+	m := Mutex{Profile: p}
+	rw := RWMutex{Profile: p}
+	var counter, rwCounter int
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m.Lock()
+				counter++
+				m.Unlock()
+				if j%4 == 0 {
+					rw.Lock()
+					rwCounter++
+					rw.Unlock()
+				} else {
+					rw.RLock()
+					_ = rwCounter
+					rw.RUnlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if counter != 8000 || rwCounter != 2000 {
+		t.Errorf("counter = %d, rwCounter = %d", counter, rwCounter)
+	}
+	var acquired int64
+	for _, s := range p.Sites() {
+		acquired += s.Acquisitions()
+	}
+	if acquired != 8000+8000 {
+		t.Errorf("acquired = %d, want 16000", acquired)
+	}
+}
+
+/*
+基准测试，比较sync.Mutex和lockprof.Mutex的开销：
+没有竞争时，额外的开销是runtime.Callers、sync.Map的查找、两次time.Now和几次原子操作，
+大约几百纳秒；竞争激烈时，等待锁的时间远大于这些开销。
+*/
+
+func benchmarkLock(b *testing.B, lock, unlock func()) {
+	b.Run("uncontended", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lock()
+			unlock()
+		}
+	})
+	b.Run("contended", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lock()
+				unlock()
+			}
+		})
+	})
+}
+
+func BenchmarkSyncMutex(b *testing.B) {
+	var mu sync.Mutex
+	benchmarkLock(b, mu.Lock, mu.Unlock)
+}
+
+func BenchmarkMutex(b *testing.B) {
+	mu := Mutex{Profile: NewProfile()}
+	benchmarkLock(b, mu.Lock, mu.Unlock)
+}
+
+func BenchmarkSyncRWMutexRLock(b *testing.B) {
+	var mu sync.RWMutex
+	benchmarkLock(b, mu.RLock, mu.RUnlock)
+}
+
+func BenchmarkRWMutexRLock(b *testing.B) {
+	mu := RWMutex{Profile: NewProfile()}
+	benchmarkLock(b, mu.RLock, mu.RUnlock)
+}
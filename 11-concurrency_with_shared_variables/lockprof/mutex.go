@@ -0,0 +1,166 @@
+package lockprof
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mutex 是记录统计数据的互斥锁。零值是未加锁的Mutex，使用之后不能再复制。
+type Mutex struct {
+	// Profile 是记录统计数据的Profile，为nil时使用Default。必须在第一次加锁之前设置。
+	Profile *Profile
+
+	mu    sync.Mutex
+	held  *site     // 持有锁的调用点，由mu保护
+	since time.Time // 获得锁的时间，由mu保护
+}
+
+func (m *Mutex) profile() *Profile {
+	if m.Profile == nil {
+		return Default
+	}
+	return m.Profile
+}
+
+// Lock 加锁，并记录调用点和等待的时间。
+func (m *Mutex) Lock() {
+	pc := callerPC(1)
+	wait, contended := lock(m.mu.TryLock, m.mu.Lock)
+	s := m.profile().site(pc, false)
+	s.record(wait, contended)
+	m.held, m.since = s, time.Now()
+}
+
+// TryLock 尝试加锁，不会阻塞。成功的TryLock与没有竞争的Lock一样记录。
+func (m *Mutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	s := m.profile().site(callerPC(1), false)
+	s.record(0, false)
+	m.held, m.since = s, time.Now()
+	return true
+}
+
+// Unlock 解锁，并把持有锁的时间记录到加锁的调用点。
+func (m *Mutex) Unlock() {
+	s, since := m.held, m.since
+	m.held = nil
+	m.mu.Unlock()
+	if s != nil {
+		s.hold.observe(time.Since(since))
+	}
+}
+
+// lock 先用tryLock尝试加锁，失败时才计时并调用lock等待。
+// 这样没有竞争时不需要为等待时间调用time.Now。
+func lock(tryLock func() bool, lock func()) (wait time.Duration, contended bool) {
+	if tryLock() {
+		return 0, false
+	}
+	start := time.Now()
+	lock()
+	return time.Since(start), true
+}
+
+func (s *site) record(wait time.Duration, contended bool) {
+	if contended {
+		s.contended.Add(1)
+	}
+	s.wait.observe(wait)
+}
+
+// RWMutex 是记录统计数据的读写锁。零值是未加锁的RWMutex，使用之后不能再复制。
+//
+// 写锁的统计与Mutex相同。读锁可以被多个读者同时持有，而RUnlock无法知道自己对应哪一次RLock，
+// 所以读锁的持有时间按“读期间”记录：从第一个读者获得读锁到最后一个读者释放读锁，
+// 记到开始这段读期间的调用点上。这是一个近似值，它衡量的是读者挡住写者的时间。
+type RWMutex struct {
+	// Profile 是记录统计数据的Profile，为nil时使用Default。必须在第一次加锁之前设置。
+	Profile *Profile
+
+	rw    sync.RWMutex
+	held  *site     // 持有写锁的调用点，由写锁保护
+	since time.Time // 获得写锁的时间，由写锁保护
+
+	readers   atomic.Int64         // 持有读锁的读者数量
+	readSince atomic.Int64         // 当前读期间开始的时间（UnixNano）
+	readSite  atomic.Pointer[site] // 开始当前读期间的调用点
+}
+
+func (rw *RWMutex) profile() *Profile {
+	if rw.Profile == nil {
+		return Default
+	}
+	return rw.Profile
+}
+
+// Lock 获得写锁，并记录调用点和等待的时间。
+func (rw *RWMutex) Lock() {
+	pc := callerPC(1)
+	wait, contended := lock(rw.rw.TryLock, rw.rw.Lock)
+	s := rw.profile().site(pc, false)
+	s.record(wait, contended)
+	rw.held, rw.since = s, time.Now()
+}
+
+// TryLock 尝试获得写锁，不会阻塞。
+func (rw *RWMutex) TryLock() bool {
+	if !rw.rw.TryLock() {
+		return false
+	}
+	s := rw.profile().site(callerPC(1), false)
+	s.record(0, false)
+	rw.held, rw.since = s, time.Now()
+	return true
+}
+
+// Unlock 释放写锁，并把持有锁的时间记录到加锁的调用点。
+func (rw *RWMutex) Unlock() {
+	s, since := rw.held, rw.since
+	rw.held = nil
+	rw.rw.Unlock()
+	if s != nil {
+		s.hold.observe(time.Since(since))
+	}
+}
+
+// RLock 获得读锁，并记录调用点和等待的时间。
+func (rw *RWMutex) RLock() {
+	pc := callerPC(1)
+	wait, contended := lock(rw.rw.TryRLock, rw.rw.RLock)
+	s := rw.profile().site(pc, true)
+	s.record(wait, contended)
+	rw.beginRead(s)
+}
+
+// TryRLock 尝试获得读锁，不会阻塞。
+func (rw *RWMutex) TryRLock() bool {
+	if !rw.rw.TryRLock() {
+		return false
+	}
+	s := rw.profile().site(callerPC(1), true)
+	s.record(0, false)
+	rw.beginRead(s)
+	return true
+}
+
+// RUnlock 释放读锁。最后一个读者释放读锁时记录这段读期间的长度。
+func (rw *RWMutex) RUnlock() {
+	if rw.readers.Add(-1) == 0 {
+		// 在读取readSince和readSite之前，新的读者可能已经开始了下一段读期间，
+		// 这时记录的读期间会偏短。统计只需要近似值，这里不再加锁。
+		if s := rw.readSite.Load(); s != nil {
+			s.hold.observe(time.Duration(time.Now().UnixNano() - rw.readSince.Load()))
+		}
+	}
+	rw.rw.RUnlock()
+}
+
+func (rw *RWMutex) beginRead(s *site) {
+	if rw.readers.Add(1) == 1 {
+		rw.readSince.Store(time.Now().UnixNano())
+		rw.readSite.Store(s)
+	}
+}
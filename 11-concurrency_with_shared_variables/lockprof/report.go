@@ -0,0 +1,134 @@
+package lockprof
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// SiteStats 是一个调用点的统计数据。
+type SiteStats struct {
+	Function  string
+	File      string
+	Line      int
+	Kind      string // "lock"或"rlock"
+	Contended int64  // 加锁时锁已被持有的次数
+	Wait      HistogramSnapshot
+	Hold      HistogramSnapshot
+}
+
+// Acquisitions 返回在这个调用点加锁的次数。
+func (s SiteStats) Acquisitions() int64 {
+	return s.Wait.Count
+}
+
+// Sites 返回所有调用点的统计数据，按等待锁的总时间从大到小排列。
+func (p *Profile) Sites() []SiteStats {
+	var stats []SiteStats
+	p.sites.Range(func(_, v any) bool {
+		s := v.(*site)
+		frame, _ := runtime.CallersFrames([]uintptr{s.pc}).Next()
+		stats = append(stats, SiteStats{
+			Function:  frame.Function,
+			File:      frame.File,
+			Line:      frame.Line,
+			Kind:      s.kind,
+			Contended: s.contended.Load(),
+			Wait:      s.wait.snapshot(),
+			Hold:      s.hold.snapshot(),
+		})
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Wait.Total != stats[j].Wait.Total {
+			return stats[i].Wait.Total > stats[j].Wait.Total
+		}
+		return stats[i].Contended > stats[j].Contended
+	})
+	return stats
+}
+
+// Top 返回竞争最激烈的n个调用点。n <= 0时返回全部。
+func (p *Profile) Top(n int) []SiteStats {
+	stats := p.Sites()
+	if n > 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// WriteReport 以表格的形式输出竞争最激烈的n个调用点。n <= 0时输出全部。
+func (p *Profile) WriteReport(w io.Writer, n int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "acquired\tcontended\twait total\twait p50\twait p99\twait max\thold total\thold p50\thold p99\thold max\t\tsite")
+	for _, s := range p.Top(n) {
+		fmt.Fprintf(tw, "%d\t%d\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t\t%s %s (%s:%d)\n",
+			s.Acquisitions(), s.Contended,
+			s.Wait.Total, s.Wait.Quantile(0.5), s.Wait.Quantile(0.99), s.Wait.Max,
+			s.Hold.Total, s.Hold.Quantile(0.5), s.Hold.Quantile(0.99), s.Hold.Max,
+			s.Kind, s.Function, filepath.Base(s.File), s.Line)
+	}
+	return tw.Flush()
+}
+
+// Handler 返回一个输出文本报告的http.Handler。
+// 查询参数n指定输出的调用点个数，默认为20。
+func (p *Profile) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 20
+		if s := r.URL.Query().Get("n"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				http.Error(w, "bad n: "+s, http.StatusBadRequest)
+				return
+			}
+			n = v
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		p.WriteReport(w, n)
+	})
+}
+
+// jsonSite 是调用点在expvar中的JSON表示，时长的单位是纳秒。
+type jsonSite struct {
+	Site      string
+	Kind      string
+	Acquired  int64
+	Contended int64
+	WaitTotal time.Duration
+	WaitP99   time.Duration
+	WaitMax   time.Duration
+	HoldTotal time.Duration
+	HoldP99   time.Duration
+	HoldMax   time.Duration
+}
+
+// Publish 把竞争最激烈的n个调用点以name为名字发布到expvar，
+// 通过/debug/vars可以看到。与expvar.Publish一样，name重复时会panic。
+func (p *Profile) Publish(name string, n int) {
+	expvar.Publish(name, expvar.Func(func() any {
+		var sites []jsonSite
+		for _, s := range p.Top(n) {
+			sites = append(sites, jsonSite{
+				Site:      fmt.Sprintf("%s %s:%d", s.Function, s.File, s.Line),
+				Kind:      s.Kind,
+				Acquired:  s.Acquisitions(),
+				Contended: s.Contended,
+				WaitTotal: s.Wait.Total,
+				WaitP99:   s.Wait.Quantile(0.99),
+				WaitMax:   s.Wait.Max,
+				HoldTotal: s.Hold.Total,
+				HoldP99:   s.Hold.Quantile(0.99),
+				HoldMax:   s.Hold.Max,
+			})
+		}
+		return sites
+	}))
+}
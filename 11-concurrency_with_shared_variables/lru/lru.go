@@ -0,0 +1,284 @@
+// Package lru 提供一个并发安全、有大小上限和过期时间的缓存。
+//
+// 05-lazy_initialization.go中的icons是一个没有上限、从不淘汰的map。
+// 如果图标（或者任何被缓存的东西）的数量没有上限，程序的内存也就没有上限。
+// Cache在超出容量时淘汰最久没有使用的条目（LRU），容量可以按条目个数计算，
+// 也可以通过Sizer按字节数计算；每个条目还可以有过期时间（TTL），
+// 过期的条目在被访问时删除，或者由后台的清理goroutine定期删除。
+//
+// LRU的每一次Get都要修改链表，所以即使是只读的访问也需要互斥锁。
+// 为了让读多写少的场景不在一把锁上排队，Cache可以被分成多个分片，
+// 每个分片有自己的锁、链表和容量，键通过哈希函数分配到分片上。
+package lru
+
+import (
+	"container/list"
+	"errors"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// Options 是Cache的选项。
+type Options[K comparable, V any] struct {
+	// Capacity 是缓存的容量，单位由Sizer决定。必须大于0。
+	// 分片时Capacity平均分给各个分片，除不尽的部分由前面的分片各多分一个单位，
+	// 所以各分片的容量之和正好是Capacity。
+	Capacity int64
+	// Sizer 返回一个条目的大小。为nil时每个条目的大小是1，即Capacity是条目个数的上限。
+	Sizer func(key K, value V) int64
+	// TTL 是Set添加的条目的过期时间。为0时条目不会过期。
+	TTL time.Duration
+	// Shards 是分片的数量，为0时是1。不能大于Capacity，大于1时必须设置Hash。
+	Shards int
+	// Hash 把键映射到分片。
+	Hash func(key K) uint64
+	// JanitorInterval 是后台清理过期条目的间隔。为0时不启动清理goroutine，
+	// 过期的条目只在被访问或者被淘汰时删除。
+	JanitorInterval time.Duration
+}
+
+// StringHash 返回一个适用于字符串键的Hash函数。
+func StringHash() func(string) uint64 {
+	seed := maphash.MakeSeed()
+	return func(s string) uint64 { return maphash.String(seed, s) }
+}
+
+// Stats 是缓存的统计数据。
+type Stats struct {
+	Hits        int64
+	Misses      int64 // 包括找到了但已经过期的条目
+	Evictions   int64 // 因为超出容量而被淘汰的条目
+	Expirations int64 // 因为过期而被删除的条目
+}
+
+// Cache 是一个LRU缓存。
+type Cache[K comparable, V any] struct {
+	opts   Options[K, V]
+	shards []shard[K, V]
+	now    func() time.Time // 测试中可以替换
+
+	stop      chan struct{}
+	stopOnce  sync.Once
+	janitorWG sync.WaitGroup
+}
+
+type shard[K comparable, V any] struct {
+	mu       sync.Mutex
+	items    map[K]*list.Element // 值是*item
+	ll       *list.List          // 最近使用的在前面
+	size     int64
+	capacity int64
+	stats    Stats
+	_        [64]byte // 避免相邻分片的锁落在同一个缓存行上（伪共享）
+}
+
+type item[K comparable, V any] struct {
+	key     K
+	value   V
+	size    int64
+	expires time.Time // 零值表示不过期
+}
+
+// New 返回一个新的Cache。如果设置了JanitorInterval，不再使用Cache时必须调用Close。
+func New[K comparable, V any](opts Options[K, V]) (*Cache[K, V], error) {
+	if opts.Capacity <= 0 {
+		return nil, errors.New("lru: capacity must be positive")
+	}
+	if opts.Shards <= 0 {
+		opts.Shards = 1
+	}
+	if int64(opts.Shards) > opts.Capacity {
+		return nil, errors.New("lru: more shards than capacity")
+	}
+	if opts.Shards > 1 && opts.Hash == nil {
+		return nil, errors.New("lru: Hash is required when Shards > 1")
+	}
+	c := &Cache[K, V]{
+		opts:   opts,
+		shards: make([]shard[K, V], opts.Shards),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+	n := int64(opts.Shards)
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.items = make(map[K]*list.Element)
+		s.ll = list.New()
+		s.capacity = opts.Capacity / n
+		if int64(i) < opts.Capacity%n {
+			s.capacity++
+		}
+	}
+	if opts.JanitorInterval > 0 {
+		c.janitorWG.Add(1)
+		go c.janitor(opts.JanitorInterval)
+	}
+	return c, nil
+}
+
+func (c *Cache[K, V]) shard(key K) *shard[K, V] {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+	return &c.shards[c.opts.Hash(key)%uint64(len(c.shards))]
+}
+
+func (c *Cache[K, V]) size(key K, value V) int64 {
+	if c.opts.Sizer == nil {
+		return 1
+	}
+	return c.opts.Sizer(key, value)
+}
+
+// Get 返回key对应的值，并把它标记为最近使用的。过期的条目被当作不存在。
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		it := e.Value.(*item[K, V])
+		if it.expires.IsZero() || c.now().Before(it.expires) {
+			s.ll.MoveToFront(e)
+			s.stats.Hits++
+			return it.value, true
+		}
+		s.remove(e)
+		s.stats.Expirations++
+	}
+	s.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+// Set 添加或者替换key对应的值，过期时间是Options.TTL。
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.opts.TTL)
+}
+
+// SetWithTTL 添加或者替换key对应的值，它在ttl之后过期；ttl为0时不过期。
+// 如果超出了容量，淘汰最久没有使用的条目。比一个分片的容量还大的值不会被缓存。
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	it := &item[K, V]{key: key, value: value, size: c.size(key, value)}
+	if ttl > 0 {
+		it.expires = c.now().Add(ttl)
+	}
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	if it.size > s.capacity {
+		s.stats.Evictions++
+		return
+	}
+	s.items[key] = s.ll.PushFront(it)
+	s.size += it.size
+	for s.size > s.capacity {
+		s.remove(s.ll.Back())
+		s.stats.Evictions++
+	}
+}
+
+// Delete 删除key对应的值。
+func (c *Cache[K, V]) Delete(key K) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+}
+
+// remove 删除一个条目。调用者必须持有s.mu。
+func (s *shard[K, V]) remove(e *list.Element) {
+	it := s.ll.Remove(e).(*item[K, V])
+	delete(s.items, it.key)
+	s.size -= it.size
+}
+
+// Len 返回缓存中的条目数量，包括已经过期但还没有被删除的条目。
+func (c *Cache[K, V]) Len() int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += len(s.items)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Size 返回缓存中所有条目的大小之和。
+func (c *Cache[K, V]) Size() int64 {
+	var n int64
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += s.size
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats 返回统计数据。各个分片的数据是分别读取的，并不是同一时刻的快照。
+func (c *Cache[K, V]) Stats() Stats {
+	var st Stats
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		st.Hits += s.stats.Hits
+		st.Misses += s.stats.Misses
+		st.Evictions += s.stats.Evictions
+		st.Expirations += s.stats.Expirations
+		s.mu.Unlock()
+	}
+	return st
+}
+
+// RemoveExpired 删除所有过期的条目，返回删除的数量。清理goroutine定期调用它。
+func (c *Cache[K, V]) RemoveExpired() int {
+	n := 0
+	for i := range c.shards {
+		n += c.shards[i].removeExpired(c.now())
+	}
+	return n
+}
+
+func (s *shard[K, V]) removeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for e := s.ll.Back(); e != nil; {
+		prev := e.Prev()
+		if it := e.Value.(*item[K, V]); !it.expires.IsZero() && !now.Before(it.expires) {
+			s.remove(e)
+			s.stats.Expirations++
+			n++
+		}
+		e = prev
+	}
+	return n
+}
+
+func (c *Cache[K, V]) janitor(interval time.Duration) {
+	defer c.janitorWG.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.RemoveExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close 停止清理goroutine，并等待它退出。Close之后Cache仍然可以使用，只是不再定期清理。
+// 多次调用Close是安全的。
+func (c *Cache[K, V]) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.janitorWG.Wait()
+}
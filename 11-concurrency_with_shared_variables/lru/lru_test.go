@@ -0,0 +1,243 @@
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 是可以手动拨动的时钟。
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func newCache[K comparable, V any](t testing.TB, opts Options[K, V]) *Cache[K, V] {
+	t.Helper()
+	c, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLRU(t *testing.T) {
+	c := newCache(t, Options[string, int]{Capacity: 3})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a") // a成为最近使用的，b是最久没有使用的
+	c.Set("d", 4)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	for k, want := range map[string]int{"a": 1, "c": 3, "d": 4} {
+		if v, ok := c.Get(k); !ok || v != want {
+			t.Errorf("Get(%s) = %d, %v; want %d", k, v, ok, want)
+		}
+	}
+	c.Set("a", 10) // 替换不会淘汰
+	if v, _ := c.Get("a"); v != 10 || c.Len() != 3 {
+		t.Errorf("after replace: a = %d, Len = %d", v, c.Len())
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 2 {
+		t.Errorf("Delete did not remove a")
+	}
+	st := c.Stats()
+	if st.Evictions != 1 || st.Hits != 5 || st.Misses != 2 {
+		t.Errorf("Stats() = %+v", st)
+	}
+}
+
+func TestSizer(t *testing.T) {
+	c := newCache(t, Options[string, []byte]{
+		Capacity: 100,
+		Sizer:    func(k string, v []byte) int64 { return int64(len(v)) },
+	})
+	c.Set("a", make([]byte, 40))
+	c.Set("b", make([]byte, 40))
+	c.Set("c", make([]byte, 40)) // 超出100字节，淘汰a
+	if _, ok := c.Get("a"); ok {
+		t.Error("a was not evicted")
+	}
+	if c.Size() != 80 {
+		t.Errorf("Size() = %d, want 80", c.Size())
+	}
+	c.Set("huge", make([]byte, 101)) // 比容量还大，不缓存，也不淘汰其它条目
+	if _, ok := c.Get("huge"); ok || c.Len() != 2 {
+		t.Errorf("oversized value: Len = %d", c.Len())
+	}
+	c.Set("b", make([]byte, 10)) // 替换时大小也随之更新
+	if c.Size() != 50 {
+		t.Errorf("Size() after replace = %d, want 50", c.Size())
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	c := newCache(t, Options[string, int]{Capacity: 10, TTL: time.Minute})
+	c.now = clock.now
+
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+	c.SetWithTTL("forever", 3, 0)
+	clock.advance(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a expired early")
+	}
+	clock.advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a did not expire")
+	}
+	clock.advance(2 * time.Hour)
+	if n := c.RemoveExpired(); n != 1 {
+		t.Errorf("RemoveExpired() = %d, want 1 (b)", n)
+	}
+	if _, ok := c.Get("forever"); !ok || c.Len() != 1 {
+		t.Errorf("entry without TTL expired")
+	}
+	if st := c.Stats(); st.Expirations != 2 {
+		t.Errorf("Expirations = %d, want 2", st.Expirations)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	before := runtime.NumGoroutine()
+	c := newCache(t, Options[int, int]{Capacity: 100, TTL: 5 * time.Millisecond, JanitorInterval: time.Millisecond})
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not remove expired entries, Len = %d", c.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.Close()
+	c.Close()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines after Close, %d before", n, before)
+	}
+}
+
+func TestShards(t *testing.T) {
+	if _, err := New(Options[string, int]{Capacity: 10, Shards: 4}); err == nil {
+		t.Error("New without Hash succeeded")
+	}
+	if _, err := New(Options[string, int]{}); err == nil {
+		t.Error("New with zero capacity succeeded")
+	}
+	if _, err := New(Options[string, int]{Capacity: 3, Shards: 4, Hash: StringHash()}); err == nil {
+		t.Error("New with more shards than capacity succeeded")
+	}
+
+	// 10个单位分给4个分片：前两个分片各多分一个。
+	c := newCache(t, Options[string, int]{Capacity: 10, Shards: 4, Hash: StringHash()})
+	var total int64
+	for i := range c.shards {
+		want := int64(2)
+		if i < 2 {
+			want = 3
+		}
+		if got := c.shards[i].capacity; got != want {
+			t.Errorf("shard %d has capacity %d, want %d", i, got, want)
+		}
+		total += c.shards[i].capacity
+	}
+	if total != 10 {
+		t.Errorf("total shard capacity = %d, want 10", total)
+	}
+
+	c = newCache(t, Options[string, int]{Capacity: 1000, Shards: 8, Hash: StringHash()})
+	for i := 0; i < 2000; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+	// 每个分片最多125个条目，总数不超过1000。
+	if n := c.Len(); n > 1000 || n < 900 {
+		t.Errorf("Len() = %d, want about 1000", n)
+	}
+	for i := range c.shards {
+		if n := len(c.shards[i].items); n != 125 {
+			t.Errorf("shard %d has %d items, want 125", i, n)
+		}
+	}
+}
+
+// TestConcurrent 用go test -race运行。
+func TestConcurrent(t *testing.T) {
+	c := newCache(t, Options[int, int]{Capacity: 64, Shards: 4, Hash: func(k int) uint64 { return uint64(k) }, TTL: time.Millisecond, JanitorInterval: time.Millisecond})
+	defer c.Close()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 2000; i++ {
+				k := rng.Intn(128)
+				if v, ok := c.Get(k); ok && v != k*k {
+					t.Errorf("Get(%d) = %d", k, v)
+					return
+				}
+				c.Set(k, k*k)
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+	if n := c.Len(); n > 64 {
+		t.Errorf("Len() = %d exceeds capacity", n)
+	}
+	st := c.Stats()
+	if st.Hits+st.Misses != 8*2000 {
+		t.Errorf("Stats() = %+v, want %d lookups", st, 8*2000)
+	}
+}
+
+/*
+基准测试：读多写少（90%的Get命中，10%的Set），比较只有一把锁的缓存和分片的缓存。
+GOMAXPROCS越大，单锁版本在锁上排队的时间越多，分片的优势越明显。
+*/
+
+func benchmarkCache(b *testing.B, shards int) {
+	const keys = 1024
+	c := newCache(b, Options[string, int]{Capacity: 2 * keys, Shards: shards, Hash: StringHash()})
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = fmt.Sprintf("icon-%d.png", i)
+		c.Set(names[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			k := names[rng.Intn(keys)]
+			if rng.Intn(10) == 0 {
+				c.Set(k, 0)
+			} else {
+				c.Get(k)
+			}
+		}
+	})
+}
+
+func BenchmarkSingleMutex(b *testing.B) { benchmarkCache(b, 1) }
+func BenchmarkSharded16(b *testing.B)   { benchmarkCache(b, 16) }
+func BenchmarkSharded64(b *testing.B)   { benchmarkCache(b, 64) }
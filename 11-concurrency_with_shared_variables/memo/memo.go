@@ -0,0 +1,141 @@
+// Package memo 提供一个并发安全、不阻塞的记忆化缓存。
+//
+// 05-lazy_initialization.go中的Icon到Icon4只能处理一个固定的、包含四个图标的map。
+// Memo可以缓存任意函数的结果：第一次请求某个键时调用函数，之后的请求直接返回缓存的结果。
+// 与《Go语言圣经》9.7节中的memo4一样，对同一个键的并发请求会共享同一次进行中的调用（重复抑制），
+// 而对不同键的请求互不阻塞，因为调用函数时没有持有锁。
+//
+// 在此基础上，Get还接受一个context：调用者可以放弃等待而不影响等待同一个键的其它调用者。
+// 函数本身在一个独立的context中运行，只有当所有等待它的调用者都放弃之后，这个context才会被取消。
+package memo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Func 是被记忆化的函数。ctx在所有等待结果的调用者都放弃时被取消。
+type Func[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Options 是Memo的选项。
+type Options struct {
+	// EvictErrors 为true时，返回错误的调用结果不被缓存，下一次Get会重新调用函数。
+	// 默认情况下错误与成功的结果一样被缓存，就像memo4那样。
+	EvictErrors bool
+}
+
+// Memo 缓存调用Func的结果。
+type Memo[K comparable, V any] struct {
+	f     Func[K, V]
+	opts  Options
+	mu    sync.Mutex // 保护cache和每个entry的done、waiters
+	cache map[K]*entry[V]
+}
+
+type entry[V any] struct {
+	ready   chan struct{} // 结果就绪时关闭
+	value   V             // ready关闭之后才可以读取
+	err     error
+	done    bool               // 调用已经完成
+	waiters int                // 还在等待结果的调用者数量，调用完成后不再维护
+	cancel  context.CancelFunc // 取消调用的context
+}
+
+// New 返回一个记忆化f的Memo。
+func New[K comparable, V any](f Func[K, V], opts Options) *Memo[K, V] {
+	return &Memo[K, V]{f: f, opts: opts, cache: make(map[K]*entry[V])}
+}
+
+// Get 返回f(key)的结果。如果结果已经缓存则直接返回；如果另一个调用者正在计算，则等待它的结果；
+// 否则开始一次新的调用。如果在结果就绪之前ctx被取消，Get返回ctx.Err()，
+// 进行中的调用会继续为其它调用者服务。
+func (m *Memo[K, V]) Get(ctx context.Context, key K) (V, error) {
+	m.mu.Lock()
+	e := m.cache[key]
+	if e == nil {
+		// 对key的第一次请求。启动调用，然后与其它调用者一样等待结果。
+		callCtx, cancel := context.WithCancel(context.Background())
+		e = &entry[V]{ready: make(chan struct{}), cancel: cancel}
+		m.cache[key] = e
+		go m.call(callCtx, key, e)
+	}
+	if !e.done {
+		e.waiters++
+	}
+	m.mu.Unlock()
+
+	// 已经缓存的结果优先于已取消的ctx。
+	select {
+	case <-e.ready:
+		return e.value, e.err
+	default:
+	}
+	select {
+	case <-e.ready:
+		return e.value, e.err
+	case <-ctx.Done():
+		m.leave(key, e)
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// call 调用f并广播结果。
+func (m *Memo[K, V]) call(ctx context.Context, key K, e *entry[V]) {
+	value, err := m.safeCall(ctx, key)
+
+	m.mu.Lock()
+	e.value, e.err = value, err
+	e.done = true
+	// 被所有调用者放弃的调用已经从缓存中删除，不会再被使用；
+	// 如果设置了EvictErrors，失败的结果也从缓存中删除，以便重试。
+	if err != nil && m.opts.EvictErrors && m.cache[key] == e {
+		delete(m.cache, key)
+	}
+	m.mu.Unlock()
+	e.cancel()
+	close(e.ready)
+}
+
+// safeCall 调用f，把f中的panic转换为错误，否则后台goroutine中的panic会让整个程序崩溃。
+func (m *Memo[K, V]) safeCall(ctx context.Context, key K) (value V, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("memo: panic calling function for key %v: %v", key, p)
+		}
+	}()
+	return m.f(ctx, key)
+}
+
+// leave 记录一个调用者放弃了等待。如果它是最后一个，则取消进行中的调用并把它从缓存中删除，
+// 这样之后的Get会开始一次新的调用，而不会得到一个被取消的结果。
+func (m *Memo[K, V]) leave(key K, e *entry[V]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e.done {
+		return
+	}
+	e.waiters--
+	if e.waiters == 0 {
+		e.cancel()
+		if m.cache[key] == e {
+			delete(m.cache, key)
+		}
+	}
+}
+
+// Forget 从缓存中删除key的结果，之后的Get会重新调用函数。
+// 正在等待进行中的调用的调用者仍然会得到那次调用的结果。
+func (m *Memo[K, V]) Forget(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cache, key)
+}
+
+// Len 返回缓存中的键的数量，包括正在计算的键。
+func (m *Memo[K, V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.cache)
+}
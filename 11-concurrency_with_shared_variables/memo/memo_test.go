@@ -0,0 +1,274 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowServer 模拟《Go语言圣经》9.7节中的慢速网站：每个请求都要等待delay才返回，
+// 并统计每个路径被请求的次数。如果请求在返回之前被取消，把路径记录到cancelled中。
+type slowServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	hits      map[string]int
+	cancelled map[string]int
+}
+
+func newSlowServer(delay time.Duration) *slowServer {
+	s := &slowServer{hits: make(map[string]int), cancelled: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[r.URL.Path]++
+		s.mu.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			s.mu.Lock()
+			s.cancelled[r.URL.Path]++
+			s.mu.Unlock()
+			return
+		}
+		if r.URL.Path == "/404" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "body of %s", r.URL.Path)
+	}))
+	return s
+}
+
+func (s *slowServer) count(m map[string]int, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m[path]
+}
+
+// httpGetBody 是被记忆化的函数，与书中的httpGetBody相同，但请求可以被ctx取消。
+func httpGetBody(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func TestDuplicateSuppression(t *testing.T) {
+	srv := newSlowServer(50 * time.Millisecond)
+	defer srv.Close()
+	m := New(httpGetBody, Options{})
+
+	paths := []string{"/a", "/b", "/c"}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, path := range paths {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				body, err := m.Get(context.Background(), srv.URL+path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want := "body of " + path; string(body) != want {
+					t.Errorf("Get(%s) = %q, want %q", path, body, want)
+				}
+			}(path)
+		}
+	}
+	wg.Wait()
+	// 30个并发请求，每个路径只访问一次服务器；不同的路径互不阻塞，所以总时间接近一次请求。
+	for _, path := range paths {
+		if n := srv.count(srv.hits, path); n != 1 {
+			t.Errorf("%s fetched %d times, want 1", path, n)
+		}
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("took %v; requests for different keys were serialized", d)
+	}
+
+	// 之后的请求直接从缓存返回。
+	start = time.Now()
+	if _, err := m.Get(context.Background(), srv.URL+"/a"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 10*time.Millisecond || srv.count(srv.hits, "/a") != 1 {
+		t.Errorf("cached Get took %v and fetched again", d)
+	}
+}
+
+func TestCancelOneWaiter(t *testing.T) {
+	srv := newSlowServer(100 * time.Millisecond)
+	defer srv.Close()
+	m := New(httpGetBody, Options{})
+	url := srv.URL + "/x"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	errc := make(chan error)
+	go func() {
+		_, err := m.Get(ctx, url)
+		errc <- err
+	}()
+	body, err := m.Get(context.Background(), url)
+	if err != nil || string(body) != "body of /x" {
+		t.Errorf("patient Get = %q, %v", body, err)
+	}
+	if err := <-errc; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("impatient Get error = %v, want DeadlineExceeded", err)
+	}
+	if n := srv.count(srv.hits, "/x"); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+	if n := srv.count(srv.cancelled, "/x"); n != 0 {
+		t.Errorf("fetch was cancelled although a caller was still waiting")
+	}
+}
+
+func TestCancelAllWaiters(t *testing.T) {
+	srv := newSlowServer(time.Second)
+	defer srv.Close()
+	m := New(httpGetBody, Options{})
+	url := srv.URL + "/y"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Get(ctx, url); err != context.Canceled {
+				t.Errorf("Get error = %v, want Canceled", err)
+			}
+		}()
+	}
+	for srv.count(srv.hits, "/y") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	// 所有调用者都放弃之后，请求被取消，结果也不会被缓存。
+	deadline := time.Now().Add(time.Second)
+	for srv.count(srv.cancelled, "/y") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned fetch was not cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() = %d after abandoned call, want 0", n)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var calls atomic.Int32
+	flaky := func(ctx context.Context, key string) (string, error) {
+		if calls.Add(1) == 1 {
+			return "", errors.New("transient failure")
+		}
+		return "ok " + key, nil
+	}
+	for _, c := range []struct {
+		evict bool
+		want  string // 第二次Get的结果
+	}{
+		{false, ""},  // 错误被缓存，就像memo4
+		{true, "ok"}, // 错误被丢弃，第二次Get重试
+	} {
+		calls.Store(0)
+		m := New(flaky, Options{EvictErrors: c.evict})
+		if _, err := m.Get(context.Background(), "k"); err == nil {
+			t.Fatalf("EvictErrors=%v: first Get succeeded", c.evict)
+		}
+		v, err := m.Get(context.Background(), "k")
+		if c.want == "" && (err == nil || calls.Load() != 1) {
+			t.Errorf("EvictErrors=false: second Get = %q, %v after %d calls", v, err, calls.Load())
+		}
+		if c.want != "" && (err != nil || v != "ok k" || calls.Load() != 2) {
+			t.Errorf("EvictErrors=true: second Get = %q, %v after %d calls", v, err, calls.Load())
+		}
+	}
+}
+
+func TestPanic(t *testing.T) {
+	m := New(func(ctx context.Context, key int) (int, error) { panic("boom") }, Options{})
+	if _, err := m.Get(context.Background(), 1); err == nil {
+		t.Fatal("Get of panicking function succeeded")
+	} else {
+		t.Log(err)
+	}
+}
+
+func TestForget(t *testing.T) {
+	var calls atomic.Int32
+	m := New(func(ctx context.Context, key string) (int32, error) { return calls.Add(1), nil }, Options{})
+	a, _ := m.Get(context.Background(), "k")
+	m.Forget("k")
+	b, _ := m.Get(context.Background(), "k")
+	if a != 1 || b != 2 {
+		t.Errorf("Get before and after Forget = %d, %d; want 1, 2", a, b)
+	}
+}
+
+/*
+基准测试对应书中的TestSequential和TestConcurrent：重复请求一组URL。
+第一次请求每个URL需要等待服务器的延迟，之后都从缓存返回。
+*/
+
+var incomingPaths = []string{"/golang", "/godoc", "/play", "/gopl", "/golang", "/godoc", "/play", "/gopl"}
+
+func benchmarkMemo(b *testing.B, concurrent bool) {
+	srv := newSlowServer(5 * time.Millisecond)
+	defer srv.Close()
+	for i := 0; i < b.N; i++ {
+		m := New(httpGetBody, Options{})
+		var wg sync.WaitGroup
+		for _, path := range incomingPaths {
+			if !concurrent {
+				if _, err := m.Get(context.Background(), srv.URL+path); err != nil {
+					b.Fatal(err)
+				}
+				continue
+			}
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				if _, err := m.Get(context.Background(), srv.URL+path); err != nil {
+					b.Error(err)
+				}
+			}(path)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkSequential(b *testing.B) { benchmarkMemo(b, false) }
+func BenchmarkConcurrent(b *testing.B) { benchmarkMemo(b, true) }
+
+// BenchmarkCached 测量缓存命中的开销。
+func BenchmarkCached(b *testing.B) {
+	m := New(func(ctx context.Context, key int) (int, error) { return key, nil }, Options{})
+	m.Get(context.Background(), 1)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.Get(context.Background(), 1)
+		}
+	})
+}
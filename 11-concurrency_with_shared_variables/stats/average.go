@@ -0,0 +1,59 @@
+// Package stats 提供并发安全的统计量累加器。
+//
+// 02-mutex.go中引用了Peter在golang-nuts上给出的Average例子，用来说明互斥锁保护的是不变量：
+// Average的不变量是“平均值 = sum / count”，Add先更新sum、再更新count，
+// 在两次更新之间不变量被暂时破坏，但持有锁的期间没有其它goroutine能看到这个中间状态。
+//
+// 这个包把这个例子扩展为一组累加器：平均值（Average）、用Welford算法计算的方差（Variance）、
+// 最小值和最大值（MinMax）、用P²算法估计的分位数（Quantile），以及把它们组合在一起的Summary。
+// 每个累加器都用一把互斥锁保护自己的不变量，Snapshot在持有锁的情况下复制所有字段，
+// 所以返回的快照总是满足不变量的。
+package stats
+
+import (
+	"math"
+	"sync"
+)
+
+// Average 计算平均值。零值可以直接使用。
+type Average struct {
+	mu    sync.Mutex
+	sum   float64 // 不变量：平均值 = sum / count
+	count int64
+}
+
+// AverageSnapshot 是Average在某一时刻的状态。
+type AverageSnapshot struct {
+	Count int64
+	Sum   float64
+	Mean  float64 // Count为0时为NaN
+}
+
+// Add 添加一个观测值。
+func (a *Average) Add(value float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sum += value // 从这里开始不变量被暂时破坏
+	a.count++      // 不变量恢复
+}
+
+// Value 返回平均值，没有观测值时返回NaN。
+func (a *Average) Value() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return mean(a.sum, a.count)
+}
+
+// Snapshot 返回一致的快照。
+func (a *Average) Snapshot() AverageSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AverageSnapshot{Count: a.count, Sum: a.sum, Mean: mean(a.sum, a.count)}
+}
+
+func mean(sum float64, count int64) float64 {
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"math"
+	"sync"
+)
+
+// MinMax 记录最小值和最大值。零值可以直接使用。
+type MinMax struct {
+	mu    sync.Mutex
+	count int64
+	min   float64 // 不变量：count > 0 时 min <= max
+	max   float64
+}
+
+// Range 是MinMax在某一时刻的状态。
+type Range struct {
+	Count    int64
+	Min, Max float64 // Count为0时为NaN
+}
+
+// Add 添加一个观测值。
+func (m *MinMax) Add(x float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.count == 0 || x < m.min {
+		m.min = x
+	}
+	if m.count == 0 || x > m.max {
+		m.max = x
+	}
+	m.count++
+}
+
+// Snapshot 返回一致的快照。
+func (m *MinMax) Snapshot() Range {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.count == 0 {
+		return Range{Min: math.NaN(), Max: math.NaN()}
+	}
+	return Range{Count: m.count, Min: m.min, Max: m.max}
+}
@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// p2 用Jain和Chlamtac的P²算法估计p分位数，它只保存5个标记（marker），不需要保存所有的观测值。
+// 它本身不是并发安全的。
+//
+// 标记的高度q[0..4]分别估计最小值、p/2分位数、p分位数、(1+p)/2分位数和最大值，
+// n[i]是标记i的实际位置，want[i]是它的理想位置。每来一个观测值，理想位置按incr前进，
+// 偏离超过1的中间标记用分段抛物线（必要时用线性）插值调整高度。
+// 不变量：q[0] <= q[1] <= ... <= q[4]，并且q[0]和q[4]是观测到的最小值和最大值。
+type p2 struct {
+	p     float64
+	count int64
+	q     [5]float64
+	n     [5]float64
+	want  [5]float64
+	incr  [5]float64
+}
+
+func newP2(p float64) p2 {
+	if !(p >= 0 && p <= 1) {
+		panic(fmt.Sprintf("stats: quantile %v out of range [0, 1]", p))
+	}
+	return p2{
+		p:    p,
+		n:    [5]float64{1, 2, 3, 4, 5},
+		want: [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5},
+		incr: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+func (e *p2) add(x float64) {
+	if e.count < 5 {
+		// 前5个观测值直接作为标记的初始高度。
+		e.q[e.count] = x
+		e.count++
+		if e.count == 5 {
+			sort.Float64s(e.q[:])
+		}
+		return
+	}
+	e.count++
+
+	// 找到x所在的区间k，q[k] <= x < q[k+1]
+	var k int
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+		k = 0
+	case x >= e.q[4]:
+		e.q[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3 && x >= e.q[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		e.n[i]++
+	}
+	for i := range e.want {
+		e.want[i] += e.incr[i]
+	}
+
+	for i := 1; i <= 3; i++ {
+		d := e.want[i] - e.n[i]
+		if (d >= 1 && e.n[i+1]-e.n[i] > 1) || (d <= -1 && e.n[i-1]-e.n[i] < -1) {
+			s := math.Copysign(1, d)
+			q := e.parabolic(i, s)
+			if !(e.q[i-1] < q && q < e.q[i+1]) {
+				q = e.linear(i, s)
+			}
+			e.q[i] = q
+			e.n[i] += s
+		}
+	}
+}
+
+func (e *p2) parabolic(i int, s float64) float64 {
+	q, n := &e.q, &e.n
+	return q[i] + s/(n[i+1]-n[i-1])*
+		((n[i]-n[i-1]+s)*(q[i+1]-q[i])/(n[i+1]-n[i])+
+			(n[i+1]-n[i]-s)*(q[i]-q[i-1])/(n[i]-n[i-1]))
+}
+
+func (e *p2) linear(i int, s float64) float64 {
+	j := i + int(s)
+	return e.q[i] + s*(e.q[j]-e.q[i])/(e.n[j]-e.n[i])
+}
+
+// value 返回p分位数的估计值，没有观测值时返回NaN。
+func (e *p2) value() float64 {
+	switch {
+	case e.count == 0:
+		return math.NaN()
+	case e.count < 5:
+		// 观测值太少，直接在排好序的观测值上取最近秩。
+		s := append([]float64(nil), e.q[:e.count]...)
+		sort.Float64s(s)
+		return s[int(math.Round(e.p*float64(e.count-1)))]
+	default:
+		return e.q[2]
+	}
+}
+
+// Quantile 估计观测值的p分位数，例如p为0.5时估计中位数，0.99时估计第99百分位数。
+type Quantile struct {
+	mu sync.Mutex
+	e  p2
+}
+
+// QuantileSnapshot 是Quantile在某一时刻的状态。
+type QuantileSnapshot struct {
+	P     float64
+	Count int64
+	Value float64 // Count为0时为NaN
+}
+
+// NewQuantile 返回一个估计p分位数的Quantile，p必须在[0, 1]之间。
+func NewQuantile(p float64) *Quantile {
+	return &Quantile{e: newP2(p)}
+}
+
+// Add 添加一个观测值。
+func (q *Quantile) Add(x float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.e.add(x)
+}
+
+// Value 返回p分位数的估计值。
+func (q *Quantile) Value() float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.e.value()
+}
+
+// Snapshot 返回一致的快照。
+func (q *Quantile) Snapshot() QuantileSnapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QuantileSnapshot{P: q.e.p, Count: q.e.count, Value: q.e.value()}
+}
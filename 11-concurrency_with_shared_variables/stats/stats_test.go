@@ -0,0 +1,249 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestAverage(t *testing.T) {
+	var a Average
+	if v := a.Value(); !math.IsNaN(v) {
+		t.Errorf("empty Average = %v, want NaN", v)
+	}
+	for _, x := range []float64{3.14159, 2.71828, 1.41421} {
+		a.Add(x)
+	}
+	if got, want := a.Value(), (3.14159+2.71828+1.41421)/3; got != want {
+		t.Errorf("Value() = %v, want %v", got, want)
+	}
+	if s := a.Snapshot(); s.Count != 3 || s.Mean != s.Sum/3 {
+		t.Errorf("Snapshot() = %+v", s)
+	}
+}
+
+func TestVariance(t *testing.T) {
+	var v Variance
+	xs := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	for _, x := range xs {
+		v.Add(x)
+	}
+	m := v.Snapshot()
+	if m.Count != 8 || m.Mean != 5 || m.Variance() != 4 || m.StdDev() != 2 {
+		t.Errorf("Snapshot() = %+v, variance %v", m, m.Variance())
+	}
+	if got, want := m.SampleVariance(), 32.0/7; math.Abs(got-want) > 1e-12 {
+		t.Errorf("SampleVariance() = %v, want %v", got, want)
+	}
+}
+
+// TestVarianceStable 比较Welford算法与“平方的均值减去均值的平方”：
+// 当数据的均值远大于方差时，后者会因为相减而损失几乎所有的有效数字。
+func TestVarianceStable(t *testing.T) {
+	var v Variance
+	var sum, sumsq float64
+	for _, x := range []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16} {
+		v.Add(x)
+		sum += x
+		sumsq += x * x
+	}
+	if got := v.Snapshot().Variance(); got != 22.5 {
+		t.Errorf("Variance() = %v, want 22.5", got)
+	}
+	naive := sumsq/4 - (sum/4)*(sum/4)
+	t.Logf("naive variance = %v", naive)
+}
+
+func TestMinMax(t *testing.T) {
+	var m MinMax
+	if r := m.Snapshot(); !math.IsNaN(r.Min) || !math.IsNaN(r.Max) {
+		t.Errorf("empty MinMax = %+v, want NaN", r)
+	}
+	for _, x := range []float64{3, -1, 4, 1, -5, 9, 2, 6} {
+		m.Add(x)
+	}
+	if r := m.Snapshot(); r.Min != -5 || r.Max != 9 || r.Count != 8 {
+		t.Errorf("Snapshot() = %+v, want min -5, max 9", r)
+	}
+}
+
+// exact 返回已排序的xs的p分位数。
+func exact(xs []float64, p float64) float64 {
+	return xs[int(math.Round(p*float64(len(xs)-1)))]
+}
+
+func TestQuantile(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, dist := range []struct {
+		name string
+		gen  func() float64
+	}{
+		{"uniform", rng.Float64},
+		{"normal", rng.NormFloat64},
+		{"exponential", rng.ExpFloat64},
+	} {
+		var xs []float64
+		qs := map[float64]*Quantile{}
+		for _, p := range []float64{0.1, 0.5, 0.9, 0.99} {
+			qs[p] = NewQuantile(p)
+		}
+		for i := 0; i < 20000; i++ {
+			x := dist.gen()
+			xs = append(xs, x)
+			for _, q := range qs {
+				q.Add(x)
+			}
+		}
+		sort.Float64s(xs)
+		for p, q := range qs {
+			// 用秩误差衡量：估计值在真实分布中所处的位置与p相差不超过1%。
+			got := q.Value()
+			rank := float64(sort.SearchFloat64s(xs, got)) / float64(len(xs))
+			if math.Abs(rank-p) > 0.01 {
+				t.Errorf("%s: p%g = %.4f (rank %.4f), exact %.4f", dist.name, p*100, got, rank, exact(xs, p))
+			}
+		}
+	}
+}
+
+func TestQuantileFewObservations(t *testing.T) {
+	q := NewQuantile(0.5)
+	if v := q.Value(); !math.IsNaN(v) {
+		t.Errorf("empty Quantile = %v, want NaN", v)
+	}
+	for _, x := range []float64{5, 1, 3} {
+		q.Add(x)
+	}
+	if v := q.Value(); v != 3 {
+		t.Errorf("median of {5 1 3} = %v, want 3", v)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	s := NewSummary(0.5, 0.9)
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+	snap := s.Snapshot()
+	if snap.Count != 1000 || snap.Sum != 500500 || snap.Mean != 500.5 || snap.Min != 1 || snap.Max != 1000 {
+		t.Errorf("Snapshot() = %v", snap)
+	}
+	if got, want := snap.Variance, (1000.0*1000-1)/12; math.Abs(got-want) > 1e-6 {
+		t.Errorf("Variance = %v, want %v", got, want)
+	}
+	for i, want := range []float64{500, 900} {
+		if got := snap.Quantiles[i].Value; math.Abs(got-want) > 10 {
+			t.Errorf("p%g = %v, want about %v", snap.Quantiles[i].P*100, got, want)
+		}
+	}
+	t.Log(snap)
+}
+
+// checkSummary 检查快照满足的不变量。
+// 所有观测值都是value，或者是lo到hi之间的整数。
+func checkSummary(t *testing.T, s SummarySnapshot, lo, hi float64) {
+	t.Helper()
+	if s.Count == 0 {
+		return
+	}
+	if s.Min > s.Max || s.Min < lo || s.Max > hi {
+		t.Fatalf("min %v, max %v outside [%v, %v]", s.Min, s.Max, lo, hi)
+	}
+	if s.Mean < s.Min || s.Mean > s.Max {
+		t.Fatalf("mean %v outside [min %v, max %v]", s.Mean, s.Min, s.Max)
+	}
+	// 观测值都是小整数，所以总和是精确的，均值必须等于总和除以个数。
+	if math.Abs(s.Mean-s.Sum/float64(s.Count)) > 1e-9*math.Abs(s.Mean) {
+		t.Fatalf("mean %v != sum %v / count %d", s.Mean, s.Sum, s.Count)
+	}
+	if s.Variance < 0 {
+		t.Fatalf("variance %v < 0", s.Variance)
+	}
+	for _, q := range s.Quantiles {
+		if q.Count != s.Count {
+			t.Fatalf("p%g has %d observations, summary has %d", q.P*100, q.Count, s.Count)
+		}
+		if q.Value < s.Min || q.Value > s.Max {
+			t.Fatalf("p%g = %v outside [min %v, max %v]", q.P*100, q.Value, s.Min, s.Max)
+		}
+	}
+}
+
+// TestStress 用go test -race运行：多个goroutine并发地添加观测值和读取快照，
+// 读到的快照必须总是满足不变量。
+func TestStress(t *testing.T) {
+	const (
+		writers = 8
+		adds    = 2000
+	)
+	var (
+		avg  Average
+		mm   MinMax
+		vari Variance
+		q    = NewQuantile(0.5)
+		sum  = NewSummary(0.5, 0.9, 0.99)
+	)
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			// 每个写者都写入相同的值7，所以平均值必须恰好是7。
+			if s := avg.Snapshot(); s.Count > 0 && s.Mean != 7 {
+				t.Errorf("Average snapshot %+v, mean != 7", s)
+				return
+			}
+			if r := mm.Snapshot(); r.Count > 0 && (r.Min > r.Max || r.Min < 0 || r.Max > 100) {
+				t.Errorf("MinMax snapshot %+v", r)
+				return
+			}
+			if m := vari.Snapshot(); m.Count > 0 && (m.M2 < 0 || m.Mean < 0 || m.Mean > 100) {
+				t.Errorf("Variance snapshot %+v", m)
+				return
+			}
+			if s := q.Snapshot(); s.Count > 0 && (s.Value < 0 || s.Value > 100) {
+				t.Errorf("Quantile snapshot %+v", s)
+				return
+			}
+			checkSummary(t, sum.Snapshot(), 0, 100)
+		}
+	}()
+
+	var writersWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(seed int64) {
+			defer writersWG.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < adds; i++ {
+				x := float64(rng.Intn(101))
+				avg.Add(7)
+				mm.Add(x)
+				vari.Add(x)
+				q.Add(x)
+				sum.Add(x)
+			}
+		}(int64(w))
+	}
+	writersWG.Wait()
+	close(done)
+	readers.Wait()
+
+	const total = writers * adds
+	if s := avg.Snapshot(); s.Count != total || s.Sum != 7*total {
+		t.Errorf("Average snapshot %+v, want %d observations", s, total)
+	}
+	if s := sum.Snapshot(); s.Count != total {
+		t.Errorf("Summary has %d observations, want %d", s.Count, total)
+	}
+	checkSummary(t, sum.Snapshot(), 0, 100)
+}
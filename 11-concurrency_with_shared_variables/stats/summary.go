@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Summary 同时计算个数、总和、均值、方差、最小值、最大值和若干个分位数。
+// 所有统计量由同一把锁保护，所以快照中的各个统计量描述的是同一组观测值。
+type Summary struct {
+	mu        sync.Mutex
+	sum       float64
+	w         welford
+	min, max  float64
+	quantiles []p2
+}
+
+// SummarySnapshot 是Summary在某一时刻的状态。
+type SummarySnapshot struct {
+	Count     int64
+	Sum       float64
+	Mean      float64
+	Variance  float64 // 总体方差
+	Min, Max  float64
+	Quantiles []QuantileSnapshot // 与NewSummary的参数顺序相同
+}
+
+// NewSummary 返回一个Summary，它估计参数中给出的每一个分位数。
+func NewSummary(quantiles ...float64) *Summary {
+	s := &Summary{}
+	for _, p := range quantiles {
+		s.quantiles = append(s.quantiles, newP2(p))
+	}
+	return s
+}
+
+// Add 添加一个观测值。
+func (s *Summary) Add(x float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w.count == 0 || x < s.min {
+		s.min = x
+	}
+	if s.w.count == 0 || x > s.max {
+		s.max = x
+	}
+	s.sum += x
+	s.w.add(x)
+	for i := range s.quantiles {
+		s.quantiles[i].add(x)
+	}
+}
+
+// Snapshot 返回一致的快照。
+func (s *Summary) Snapshot() SummarySnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.w.moments()
+	snap := SummarySnapshot{
+		Count:    m.Count,
+		Sum:      s.sum,
+		Mean:     m.Mean,
+		Variance: m.Variance(),
+		Min:      s.min,
+		Max:      s.max,
+	}
+	if m.Count == 0 {
+		snap.Min, snap.Max = math.NaN(), math.NaN()
+	}
+	for i := range s.quantiles {
+		e := &s.quantiles[i]
+		snap.Quantiles = append(snap.Quantiles, QuantileSnapshot{P: e.p, Count: e.count, Value: e.value()})
+	}
+	return snap
+}
+
+func (s SummarySnapshot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "count=%d mean=%.4g stddev=%.4g min=%.4g max=%.4g",
+		s.Count, s.Mean, math.Sqrt(s.Variance), s.Min, s.Max)
+	for _, q := range s.Quantiles {
+		fmt.Fprintf(&b, " p%g=%.4g", q.P*100, q.Value)
+	}
+	return b.String()
+}
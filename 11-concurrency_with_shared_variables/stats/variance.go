@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"math"
+	"sync"
+)
+
+// welford 用Welford的在线算法计算均值和方差，它比先累加x和x²再相减的方法在数值上更稳定。
+// 它本身不是并发安全的。
+type welford struct {
+	count int64
+	mean  float64
+	m2    float64 // 与均值之差的平方和
+}
+
+func (w *welford) add(x float64) {
+	w.count++
+	delta := x - w.mean
+	w.mean += delta / float64(w.count)
+	w.m2 += delta * (x - w.mean)
+}
+
+func (w *welford) moments() Moments {
+	m := Moments{Count: w.count, Mean: w.mean, M2: w.m2}
+	if w.count == 0 {
+		m.Mean = math.NaN()
+	}
+	return m
+}
+
+// Variance 计算均值和方差。零值可以直接使用。
+type Variance struct {
+	mu sync.Mutex
+	w  welford
+}
+
+// Moments 是Variance在某一时刻的状态。
+type Moments struct {
+	Count int64
+	Mean  float64 // Count为0时为NaN
+	M2    float64 // 与均值之差的平方和
+}
+
+// Add 添加一个观测值。
+func (v *Variance) Add(x float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.w.add(x)
+}
+
+// Snapshot 返回一致的快照。
+func (v *Variance) Snapshot() Moments {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.w.moments()
+}
+
+// Variance 返回总体方差，Count为0时返回NaN。
+func (m Moments) Variance() float64 {
+	if m.Count == 0 {
+		return math.NaN()
+	}
+	return m.M2 / float64(m.Count)
+}
+
+// SampleVariance 返回样本方差，Count小于2时返回NaN。
+func (m Moments) SampleVariance() float64 {
+	if m.Count < 2 {
+		return math.NaN()
+	}
+	return m.M2 / float64(m.Count-1)
+}
+
+// StdDev 返回总体标准差。
+func (m Moments) StdDev() float64 {
+	return math.Sqrt(m.Variance())
+}
@@ -0,0 +1,3 @@
+module trylock
+
+go 1.19
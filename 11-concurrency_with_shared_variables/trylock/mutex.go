@@ -0,0 +1,78 @@
+// Package trylock 提供基于channel实现的互斥锁和读写锁，
+// 除了Lock以外，还支持TryLock、可以被context取消的LockContext和带超时的LockTimeout。
+//
+// 02-mutex.go中引用了Russ Cox的回复：“I am sure that we'll need TryLock eventually...
+// Lock with timeout seems less essential but if there were a clean implementation...”。
+// 用容量为1的channel作为锁，加锁就是向channel发送，解锁就是从channel接收，
+// 这样加锁就可以和其它channel操作一起放在select中，TryLock和超时都是很自然的写法。
+//
+// 与sync.Mutex一样，锁不与goroutine绑定：一个goroutine加的锁可以由另一个goroutine解锁。
+// 零值的Mutex和RWMutex可以直接使用，使用之后不能再复制。
+package trylock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Mutex 是一个互斥锁。等待的goroutine按照先来先服务的顺序获得锁。
+type Mutex struct {
+	once sync.Once
+	ch   chan struct{} // 锁被持有时channel中有一个元素
+}
+
+func (m *Mutex) init() {
+	m.once.Do(func() { m.ch = make(chan struct{}, 1) })
+}
+
+// Lock 加锁。如果锁已经被持有，Lock会阻塞直到锁可用。
+func (m *Mutex) Lock() {
+	m.init()
+	m.ch <- struct{}{}
+}
+
+// TryLock 尝试加锁，不会阻塞，返回是否加锁成功。
+func (m *Mutex) TryLock() bool {
+	m.init()
+	select {
+	case m.ch <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// LockContext 加锁，如果在获得锁之前ctx被取消，则放弃加锁并返回ctx.Err()。
+func (m *Mutex) LockContext(ctx context.Context) error {
+	m.init()
+	select {
+	case m.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LockTimeout 加锁，最多等待d，返回是否加锁成功。
+func (m *Mutex) LockTimeout(d time.Duration) bool {
+	m.init()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case m.ch <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Unlock 解锁。对没有加锁的Mutex调用Unlock会panic。
+func (m *Mutex) Unlock() {
+	m.init()
+	select {
+	case <-m.ch:
+	default:
+		panic("trylock: unlock of unlocked mutex")
+	}
+}
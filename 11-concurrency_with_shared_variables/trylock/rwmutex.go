@@ -0,0 +1,177 @@
+package trylock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errWouldBlock 表示TryLock和TryRLock无法立即获得锁。
+var errWouldBlock = errors.New("trylock: would block")
+
+// RWMutex 是一个读写锁，它可以被任意数量的读者或者一个写者持有。
+//
+// 它是写者优先的：只要有写者在等待，新的读者就不能获得读锁，
+// 这样源源不断的读者不会让写者饿死（代价是源源不断的写者会让读者一直等待）。
+type RWMutex struct {
+	once sync.Once
+	// state 是一个容量为1的channel，其中保存着锁的状态。
+	// 从channel接收状态就获得了修改它的权利，修改完成后再发送回去。
+	state chan rwState
+}
+
+type rwState struct {
+	readers int  // 持有读锁的读者数量
+	writer  bool // 是否有写者持有锁
+	waiting int  // 正在等待的写者数量
+	// changed 在锁被释放时关闭并替换为一个新的channel，用来唤醒所有等待的goroutine。
+	changed chan struct{}
+}
+
+// broadcast 唤醒所有等待的goroutine，让它们重新检查状态。
+func (s *rwState) broadcast() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (rw *RWMutex) init() {
+	rw.once.Do(func() {
+		rw.state = make(chan rwState, 1)
+		rw.state <- rwState{changed: make(chan struct{})}
+	})
+}
+
+// acquire 获得读锁（write为false）或写锁（write为true）。
+// 如果try为true，无法立即获得锁时返回errWouldBlock；否则等待直到获得锁或ctx被取消。
+func (rw *RWMutex) acquire(ctx context.Context, write, try bool) error {
+	rw.init()
+	s := <-rw.state
+	if rw.available(&s, write) {
+		rw.state <- s
+		return nil
+	}
+	if try {
+		rw.state <- s
+		return errWouldBlock
+	}
+
+	if write {
+		s.waiting++
+	}
+	for {
+		changed := s.changed
+		rw.state <- s
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			s = <-rw.state
+			if write {
+				// 放弃等待的写者可能正挡着一些读者，需要唤醒它们。
+				s.waiting--
+				s.broadcast()
+			}
+			rw.state <- s
+			return ctx.Err()
+		}
+		s = <-rw.state
+		if write {
+			s.waiting--
+		}
+		if rw.available(&s, write) {
+			rw.state <- s
+			return nil
+		}
+		if write {
+			s.waiting++
+		}
+	}
+}
+
+// available 检查锁是否可以被获得，如果可以则修改状态为已获得。
+func (rw *RWMutex) available(s *rwState, write bool) bool {
+	if write {
+		if s.writer || s.readers > 0 {
+			return false
+		}
+		s.writer = true
+		return true
+	}
+	if s.writer || s.waiting > 0 {
+		return false
+	}
+	s.readers++
+	return true
+}
+
+// Lock 获得写锁。
+func (rw *RWMutex) Lock() {
+	rw.acquire(context.Background(), true, false)
+}
+
+// TryLock 尝试获得写锁，不会阻塞，返回是否成功。
+func (rw *RWMutex) TryLock() bool {
+	return rw.acquire(context.Background(), true, true) == nil
+}
+
+// LockContext 获得写锁，如果在获得锁之前ctx被取消，则放弃并返回ctx.Err()。
+func (rw *RWMutex) LockContext(ctx context.Context) error {
+	return rw.acquire(ctx, true, false)
+}
+
+// LockTimeout 获得写锁，最多等待d，返回是否成功。
+func (rw *RWMutex) LockTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.acquire(ctx, true, false) == nil
+}
+
+// Unlock 释放写锁。如果没有持有写锁，Unlock会panic。
+func (rw *RWMutex) Unlock() {
+	rw.init()
+	s := <-rw.state
+	if !s.writer {
+		rw.state <- s
+		panic("trylock: unlock of unlocked RWMutex")
+	}
+	s.writer = false
+	s.broadcast()
+	rw.state <- s
+}
+
+// RLock 获得读锁。
+func (rw *RWMutex) RLock() {
+	rw.acquire(context.Background(), false, false)
+}
+
+// TryRLock 尝试获得读锁，不会阻塞，返回是否成功。
+func (rw *RWMutex) TryRLock() bool {
+	return rw.acquire(context.Background(), false, true) == nil
+}
+
+// RLockContext 获得读锁，如果在获得锁之前ctx被取消，则放弃并返回ctx.Err()。
+func (rw *RWMutex) RLockContext(ctx context.Context) error {
+	return rw.acquire(ctx, false, false)
+}
+
+// RLockTimeout 获得读锁，最多等待d，返回是否成功。
+func (rw *RWMutex) RLockTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.acquire(ctx, false, false) == nil
+}
+
+// RUnlock 释放读锁。如果没有持有读锁，RUnlock会panic。
+func (rw *RWMutex) RUnlock() {
+	rw.init()
+	s := <-rw.state
+	if s.readers == 0 {
+		rw.state <- s
+		panic("trylock: RUnlock of unlocked RWMutex")
+	}
+	s.readers--
+	if s.readers == 0 {
+		s.broadcast() // 唤醒等待的写者
+	}
+	rw.state <- s
+}
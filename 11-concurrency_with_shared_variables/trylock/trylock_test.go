@@ -0,0 +1,318 @@
+package trylock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// locker 是Mutex和RWMutex的写锁共同的方法。
+type locker interface {
+	Lock()
+	Unlock()
+	TryLock() bool
+	LockContext(ctx context.Context) error
+	LockTimeout(d time.Duration) bool
+}
+
+func lockers() map[string]func() locker {
+	return map[string]func() locker{
+		"Mutex":   func() locker { return new(Mutex) },
+		"RWMutex": func() locker { return new(RWMutex) },
+	}
+}
+
+func TestMutualExclusion(t *testing.T) {
+	for name, newLocker := range lockers() {
+		mu := newLocker()
+		var counter int
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 500; j++ {
+					switch j % 3 {
+					case 0:
+						mu.Lock()
+					case 1:
+						for !mu.TryLock() {
+						}
+					case 2:
+						if err := mu.LockContext(context.Background()); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+					counter++
+					mu.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+		if counter != 5000 {
+			t.Errorf("%s: counter = %d, want 5000", name, counter)
+		}
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	for name, newLocker := range lockers() {
+		mu := newLocker()
+		if !mu.TryLock() {
+			t.Errorf("%s: TryLock on unlocked mutex failed", name)
+		}
+		if mu.TryLock() {
+			t.Errorf("%s: TryLock on locked mutex succeeded", name)
+		}
+		mu.Unlock()
+		if !mu.TryLock() {
+			t.Errorf("%s: TryLock after Unlock failed", name)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestLockContext(t *testing.T) {
+	for name, newLocker := range lockers() {
+		mu := newLocker()
+		mu.Lock()
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error)
+		go func() { errc <- mu.LockContext(ctx) }()
+		cancel()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("%s: LockContext = %v, want context.Canceled", name, err)
+		}
+		// 放弃等待的goroutine没有拿走锁。
+		mu.Unlock()
+		if !mu.TryLock() {
+			t.Errorf("%s: TryLock after cancelled LockContext failed", name)
+		}
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	for name, newLocker := range lockers() {
+		mu := newLocker()
+		if !mu.LockTimeout(time.Second) {
+			t.Errorf("%s: LockTimeout on unlocked mutex failed", name)
+		}
+		start := time.Now()
+		if mu.LockTimeout(20 * time.Millisecond) {
+			t.Errorf("%s: LockTimeout on locked mutex succeeded", name)
+		}
+		if d := time.Since(start); d < 20*time.Millisecond {
+			t.Errorf("%s: LockTimeout returned after %v, want >= 20ms", name, d)
+		}
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			mu.Unlock()
+		}()
+		if !mu.LockTimeout(time.Second) {
+			t.Errorf("%s: LockTimeout did not get the released mutex", name)
+		}
+	}
+}
+
+func TestUnlockOfUnlocked(t *testing.T) {
+	for name, unlock := range map[string]func(){
+		"Mutex.Unlock":    new(Mutex).Unlock,
+		"RWMutex.Unlock":  new(RWMutex).Unlock,
+		"RWMutex.RUnlock": new(RWMutex).RUnlock,
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s of unlocked mutex did not panic", name)
+				}
+			}()
+			unlock()
+		}()
+	}
+}
+
+func TestReaders(t *testing.T) {
+	var rw RWMutex
+	rw.RLock()
+	if !rw.TryRLock() {
+		t.Error("second reader was blocked")
+	}
+	if rw.TryLock() {
+		t.Error("writer got the lock while readers hold it")
+	}
+	rw.RUnlock()
+	rw.RUnlock()
+	if !rw.TryLock() {
+		t.Error("writer blocked after all readers left")
+	}
+	if rw.TryRLock() {
+		t.Error("reader got the lock while a writer holds it")
+	}
+	rw.Unlock()
+}
+
+// TestWriterPreference 检查在写者等待时，新的读者不能获得读锁。
+func TestWriterPreference(t *testing.T) {
+	var rw RWMutex
+	rw.RLock()
+
+	locked := make(chan struct{})
+	go func() {
+		rw.Lock()
+		close(locked)
+	}()
+	waitFor(t, func() bool {
+		s := <-rw.state
+		rw.state <- s
+		return s.waiting == 1
+	})
+	if rw.TryRLock() {
+		t.Fatal("new reader got the lock while a writer is waiting")
+	}
+	if rw.RLockTimeout(10 * time.Millisecond) {
+		t.Fatal("RLockTimeout succeeded while a writer is waiting")
+	}
+
+	rw.RUnlock()
+	<-locked
+	rw.Unlock()
+	if !rw.TryRLock() {
+		t.Error("reader blocked after the writer left")
+	}
+	rw.RUnlock()
+}
+
+// TestCancelledWriter 检查放弃等待的写者会让被它挡住的读者继续执行。
+func TestCancelledWriter(t *testing.T) {
+	var rw RWMutex
+	rw.RLock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- rw.LockContext(ctx) }()
+	waitFor(t, func() bool {
+		s := <-rw.state
+		rw.state <- s
+		return s.waiting == 1
+	})
+
+	reader := make(chan struct{})
+	go func() {
+		rw.RLock()
+		close(reader)
+	}()
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("LockContext = %v, want context.Canceled", err)
+	}
+	select {
+	case <-reader:
+	case <-time.After(time.Second):
+		t.Fatal("reader still blocked after the waiting writer gave up")
+	}
+	rw.RUnlock()
+	rw.RUnlock()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+基准测试。竞争不激烈时（单个goroutine），sync.Mutex加锁只需要一次CAS，
+而基于channel的实现需要进入channel的锁，所以慢得多。
+竞争激烈时两者的差距会缩小。读写锁的情况与03-rwmutex.go中说的一样：
+只有读多写少并且竞争激烈时，RWMutex才比Mutex有优势。
+*/
+
+func benchmarkLock(b *testing.B, lock, unlock func()) {
+	b.Run("uncontended", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lock()
+			unlock()
+		}
+	})
+	b.Run("contended", func(b *testing.B) {
+		b.SetParallelism(4)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lock()
+				unlock()
+			}
+		})
+	})
+}
+
+func BenchmarkSyncMutex(b *testing.B) {
+	var mu sync.Mutex
+	benchmarkLock(b, mu.Lock, mu.Unlock)
+}
+
+func BenchmarkMutex(b *testing.B) {
+	var mu Mutex
+	benchmarkLock(b, mu.Lock, mu.Unlock)
+}
+
+// benchmarkReadMostly 中每10次操作有1次写，临界区内做一点工作，使读者真的有机会并发。
+func benchmarkReadMostly(b *testing.B, lock, unlock, rlock, runlock func()) {
+	var shared [64]int
+	b.SetParallelism(4)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			if i%10 == 0 {
+				lock()
+				shared[i%len(shared)]++
+				unlock()
+				continue
+			}
+			rlock()
+			sum := 0
+			for _, v := range shared {
+				sum += v
+			}
+			_ = sum
+			runlock()
+		}
+	})
+}
+
+func BenchmarkReadMostlySyncMutex(b *testing.B) {
+	var mu sync.Mutex
+	benchmarkReadMostly(b, mu.Lock, mu.Unlock, mu.Lock, mu.Unlock)
+}
+
+func BenchmarkReadMostlySyncRWMutex(b *testing.B) {
+	var mu sync.RWMutex
+	benchmarkReadMostly(b, mu.Lock, mu.Unlock, mu.RLock, mu.RUnlock)
+}
+
+func BenchmarkReadMostlyMutex(b *testing.B) {
+	var mu Mutex
+	benchmarkReadMostly(b, mu.Lock, mu.Unlock, mu.Lock, mu.Unlock)
+}
+
+func BenchmarkReadMostlyRWMutex(b *testing.B) {
+	var mu RWMutex
+	benchmarkReadMostly(b, mu.Lock, mu.Unlock, mu.RLock, mu.RUnlock)
+}
+
+func BenchmarkRWMutexRLock(b *testing.B) {
+	var mu RWMutex
+	benchmarkLock(b, mu.RLock, mu.RUnlock)
+}
+
+func BenchmarkSyncRWMutexRLock(b *testing.B) {
+	var mu sync.RWMutex
+	benchmarkLock(b, mu.RLock, mu.RUnlock)
+}
@@ -13,4 +13,7 @@ package main
 word3利用了第2中方式构建随机测试。
 通过使用当前时间作为伪随机数的种子源，在测试的整个生命周期中，每次运行的时候都会得到新的输入。
 如果你的项目使用自动化系统来间周期地运行测试，这一点很重要。
+
+word3的IsPalindromeMode支持Unicode规范化（NFC/NFD、去掉变音符号、完整的大小写折叠、字素簇），
+TestRandomGraphemePalindromes随机地用组合形式或分解形式书写每个字符，构建带组合字符的回文。
 */
@@ -93,4 +93,7 @@ func Benchmark1000(b *testing.B) { benchmark(b, 1000) }
 随着的程序演变，或者它的输入增长了，或者它被部署在其他的操作系统上并拥有一些新特性，我们仍然
 可以重用基准测试来回顾当初的设计决策。
 
+word3的BenchmarkLongest在不同的输入上比较了最长回文子串的Manacher算法和朴素的中心扩展算法：
+在随机文本上朴素算法并不慢，在"aaa..."这样重复的文本上它是平方时间的。palfind目录是使用它的命令。
+
 */
@@ -1,3 +1,7 @@
 module echo
 
 go 1.19
+
+require script v0.0.0
+
+replace script => ../script
@@ -1,30 +1,267 @@
 // Echo prints its command-line arguments.
+//
+// Usage:
+//
+//	echo [-neEi] [-s sep] [--] [arg...]
+//
+// Options may be combined (-ne) and -s takes its value either attached (-s,
+// or -s=,) or as the next argument. "--" ends the options. As with POSIX echo, the
+// first argument that is not a valid option starts the operands, so
+// "echo -x" prints "-x".
+//
+// The forms the flag package accepts still work for each single option:
+// --n, -n=false, --s=sep and --s sep. An invalid boolean value such as
+// -n=maybe is an error.
+//
+//	-n	omit the trailing newline
+//	-s sep	separate arguments with sep instead of a space
+//	-e	interpret backslash escapes (see unescape)
+//	-E	do not interpret backslash escapes (default)
+//	-i	also read arguments from standard input, one per line
 package main
 
 import (
-	"flag"
+	"bufio"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 )
 
-var (
-	n = flag.Bool("n", false, "omit trailing newline")
-	s = flag.String("s", " ", "separator")
-)
 var out io.Writer = os.Stdout // modified during testing
+var in io.Reader = os.Stdin   // modified during testing
+
 func main() {
-	flag.Parse()
-	if err := echo(!*n, *s, flag.Args()); err != nil {
+	opts, args, err := parseArgs(os.Args[1:])
+	if err == nil {
+		err = echo(opts, args)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "echo: %v\n", err)
 		os.Exit(1)
 	}
 }
-func echo(newline bool, sep string, args []string) error {
-	fmt.Fprint(out, strings.Join(args, sep))
-	if newline {
-		fmt.Fprintln(out)
+
+type options struct {
+	newline bool   // print a trailing newline
+	sep     string // separator between arguments
+	escapes bool   // interpret backslash escapes
+	stdin   bool   // read more arguments from in
+}
+
+// parseArgs splits the command line into options and operands.
+func parseArgs(args []string) (options, []string, error) {
+	opts := options{newline: true, sep: " "}
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return opts, args[1:], nil
+		}
+		if name, value, hasValue, ok := flagForm(arg); ok {
+			args = args[1:]
+			if name == "s" {
+				if !hasValue {
+					if len(args) == 0 {
+						return opts, nil, fmt.Errorf("option -s requires an argument")
+					}
+					value, args = args[0], args[1:]
+				}
+				opts.sep = value
+				continue
+			}
+			on := true
+			if hasValue {
+				var err error
+				if on, err = strconv.ParseBool(value); err != nil {
+					return opts, nil, fmt.Errorf("invalid boolean value %q for -%s", value, name)
+				}
+			}
+			opts.setBool(name[0], on)
+			continue
+		}
+		if len(arg) < 2 || arg[0] != '-' || !validFlags(arg[1:]) {
+			break
+		}
+		args = args[1:]
+	flags:
+		for i := 1; i < len(arg); i++ {
+			switch arg[i] {
+			case 'n', 'e', 'E', 'i':
+				opts.setBool(arg[i], true)
+			case 's':
+				if i+1 < len(arg) {
+					opts.sep = strings.TrimPrefix(arg[i+1:], "=")
+				} else if len(args) > 0 {
+					opts.sep, args = args[0], args[1:]
+				} else {
+					return opts, nil, fmt.Errorf("option -s requires an argument")
+				}
+				break flags
+			}
+		}
+	}
+	return opts, args, nil
+}
+
+// setBool sets the boolean option c (n, e, E or i) to on.
+func (opts *options) setBool(c byte, on bool) {
+	switch c {
+	case 'n':
+		opts.newline = !on
+	case 'e':
+		opts.escapes = on
+	case 'E':
+		opts.escapes = !on
+	case 'i':
+		opts.stdin = on
+	}
+}
+
+// flagForm reports whether arg is a single option written the way the flag
+// package accepts it: --name, --name=value or -name=value. It returns the
+// option name and the value after "=", if any.
+func flagForm(arg string) (name, value string, hasValue, ok bool) {
+	var body string
+	switch {
+	case strings.HasPrefix(arg, "--"):
+		body = arg[2:]
+	case strings.HasPrefix(arg, "-"):
+		body = arg[1:]
+	default:
+		return "", "", false, false
+	}
+	name, value, hasValue = strings.Cut(body, "=")
+	if !hasValue && body == arg[1:] {
+		return "", "", false, false // -n, -ne and -s, are option groups
+	}
+	switch name {
+	case "n", "e", "E", "i", "s":
+		return name, value, hasValue, true
+	}
+	return "", "", false, false
+}
+
+// validFlags reports whether the characters after "-" form a valid option
+// group. Everything after an s is its value.
+func validFlags(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 'n', 'e', 'E', 'i':
+		case 's':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func echo(opts options, args []string) error {
+	if opts.stdin {
+		sc := bufio.NewScanner(in)
+		for sc.Scan() {
+			args = append(args, sc.Text())
+		}
+		if err := sc.Err(); err != nil {
+			return err
+		}
+	}
+	var b strings.Builder
+	stop := false
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(opts.sep)
+		}
+		if !opts.escapes {
+			b.WriteString(arg)
+			continue
+		}
+		if stop = unescape(&b, arg); stop {
+			break
+		}
+	}
+	if opts.newline && !stop {
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// unescape writes s to b with backslash escapes interpreted:
+//
+//	\\	backslash
+//	\a \b \e \f \n \r \t \v	alert, backspace, escape, form feed, newline, carriage return, tab, vertical tab
+//	\0nnn	the byte with octal value nnn (zero to three digits)
+//	\xHH	the byte with hexadecimal value HH (one or two digits)
+//	\c	produce no further output, not even the trailing newline
+//
+// Any other backslash is written as is. unescape reports whether it saw \c.
+func unescape(b *strings.Builder, s string) (stop bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case '\\':
+			b.WriteByte('\\')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'e':
+			b.WriteByte(0x1b)
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'c':
+			return true
+		case '0':
+			v, n := digits(s[i+1:], 3, 8)
+			b.WriteByte(byte(v))
+			i += n
+		case 'x':
+			v, n := digits(s[i+1:], 2, 16)
+			if n == 0 {
+				b.WriteString(`\x`) // no hex digits: not an escape
+				break
+			}
+			b.WriteByte(byte(v))
+			i += n
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return false
+}
+
+// digits parses up to max leading digits of s in the given base and returns
+// their value and how many there were.
+func digits(s string, max, base int) (v, n int) {
+	for n < max && n < len(s) {
+		d := strings.IndexByte("0123456789abcdef", lower(s[n]))
+		if d < 0 || d >= base {
+			break
+		}
+		v = v*base + d
+		n++
+	}
+	return v, n
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
 	}
-	return nil
+	return c
 }
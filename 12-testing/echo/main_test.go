@@ -2,34 +2,172 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+
+	"script"
 )
 
+var cmds map[string]string // the echo binary built by TestMain
+
+func TestMain(m *testing.M) {
+	script.Main(m, &cmds, map[string]string{"echo": "."})
+}
+
+// TestEcho runs the scripts in testdata/script against the echo binary.
 func TestEcho(t *testing.T) {
+	script.Run(t, script.Params{Dir: "testdata/script", Cmds: cmds})
+}
+
+func TestEscapes(t *testing.T) {
+	saved := out
+	defer func() { out = saved }()
+	var tests = []struct {
+		args []string
+		want string
+	}{
+		{[]string{`a\\b`}, "a\\b\n"},
+		{[]string{`\a`}, "\a\n"},
+		{[]string{`\b`}, "\b\n"},
+		{[]string{`\e`}, "\x1b\n"},
+		{[]string{`\f`}, "\f\n"},
+		{[]string{`\n`}, "\n\n"},
+		{[]string{`\r`}, "\r\n"},
+		{[]string{`\t`}, "\t\n"},
+		{[]string{`\v`}, "\v\n"},
+		{[]string{`\0`}, "\x00\n"},
+		{[]string{`\0101`}, "A\n"},
+		{[]string{`\01012`}, "A2\n"}, // at most three octal digits
+		{[]string{`\018`}, "\x018\n"},
+		{[]string{`\x41`}, "A\n"},
+		{[]string{`\x4a\x4A`}, "JJ\n"},
+		{[]string{`\x9`}, "\t\n"},
+		{[]string{`\x414`}, "A4\n"}, // at most two hex digits
+		{[]string{`\xg`}, "\\xg\n"},
+		{[]string{`a\cb`, "c"}, "a"}, // \c stops output, including the newline
+		{[]string{"a", `\c`, "b"}, "a "},
+		{[]string{`\q`}, "\\q\n"},
+		{[]string{`end\`}, "end\\\n"},
+		{[]string{`a\tb`, `c\nd`}, "a\tb c\nd\n"},
+	}
+	for _, test := range tests {
+		out = new(bytes.Buffer)
+		if err := echo(options{newline: true, sep: " ", escapes: true}, test.args); err != nil {
+			t.Errorf("echo -e %q failed: %v", test.args, err)
+			continue
+		}
+		if got := out.(*bytes.Buffer).String(); got != test.want {
+			t.Errorf("echo -e %q = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	def := options{newline: true, sep: " "}
 	var tests = []struct {
-		newline bool
-		sep     string
-		args    []string
-		want    string
+		args []string
+		opts options
+		rest []string
 	}{
-		{true, "", []string{}, "\n"},
-		{false, "", []string{}, ""},
-		{true, "\t", []string{"one", "two", "three"}, "one\ttwo\tthree\n"},
-		{true, ",", []string{"a", "b", "c"}, "a,b,c\n"},
-		{false, ":", []string{"1", "2", "3"}, "1:2:3"},
+		{[]string{"a", "b"}, def, []string{"a", "b"}},
+		{[]string{"-n", "a"}, options{sep: " "}, []string{"a"}},
+		{[]string{"-ne", "a"}, options{sep: " ", escapes: true}, []string{"a"}},
+		{[]string{"-e", "-E", "a"}, def, []string{"a"}},
+		{[]string{"-s", ",", "a"}, options{newline: true, sep: ","}, []string{"a"}},
+		{[]string{"-ns,", "a"}, options{sep: ","}, []string{"a"}},
+		{[]string{"-s=,", "a"}, options{newline: true, sep: ","}, []string{"a"}},
+		{[]string{"-ns=", "a"}, options{sep: ""}, []string{"a"}},
+		{[]string{"-s==", "a"}, options{newline: true, sep: "="}, []string{"a"}},
+		{[]string{"-s", "-n"}, options{newline: true, sep: "-n"}, nil},
+		{[]string{"-i"}, options{newline: true, sep: " ", stdin: true}, nil},
+		{[]string{"--", "-n"}, def, []string{"-n"}},
+		{[]string{"-n", "--", "--"}, options{sep: " "}, []string{"--"}},
+		{[]string{"-x", "-n"}, def, []string{"-x", "-n"}}, // not an option: operands start here
+		{[]string{"-nx"}, def, []string{"-nx"}},
+		{[]string{"-"}, def, []string{"-"}},
+		{[]string{"a", "-n"}, def, []string{"a", "-n"}},
+		// The forms of the flag package.
+		{[]string{"--n", "a"}, options{sep: " "}, []string{"a"}},
+		{[]string{"-n=true", "a"}, options{sep: " "}, []string{"a"}},
+		{[]string{"-n", "-n=false", "a"}, def, []string{"a"}},
+		{[]string{"--n=0", "--e=1", "a"}, options{newline: true, sep: " ", escapes: true}, []string{"a"}},
+		{[]string{"--s", ":", "a"}, options{newline: true, sep: ":"}, []string{"a"}},
+		{[]string{"--s=", "a"}, options{newline: true, sep: ""}, []string{"a"}},
+		{[]string{"--x", "a"}, def, []string{"--x", "a"}},
+		{[]string{"--ne", "a"}, def, []string{"--ne", "a"}}, // no groups with two dashes
 	}
 	for _, test := range tests {
-		descr := fmt.Sprintf("echo(%v, %q, %q)",
-			test.newline, test.sep, test.args)
-		out = new(bytes.Buffer) // captured output
-		if err := echo(test.newline, test.sep, test.args); err != nil {
-			t.Errorf("%s failed: %v", descr, err)
+		opts, rest, err := parseArgs(test.args)
+		if err != nil {
+			t.Errorf("parseArgs(%q) failed: %v", test.args, err)
 			continue
 		}
-		got := out.(*bytes.Buffer).String()
-		if got != test.want {
-			t.Errorf("%s = %q, want %q", descr, got, test.want)
+		if opts != test.opts || fmt.Sprintf("%q", rest) != fmt.Sprintf("%q", test.rest) {
+			t.Errorf("parseArgs(%q) = %+v, %q; want %+v, %q", test.args, opts, rest, test.opts, test.rest)
+		}
+	}
+	for _, args := range [][]string{{"-n", "-s"}, {"--s"}, {"-n=maybe"}} {
+		if _, _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q) succeeded", args)
+		}
+	}
+}
+
+func TestStdin(t *testing.T) {
+	savedIn, savedOut := in, out
+	defer func() { in, out = savedIn, savedOut }()
+	in = strings.NewReader("c\nd e\n")
+	out = new(bytes.Buffer)
+	if err := echo(options{newline: true, sep: ",", stdin: true}, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.(*bytes.Buffer).String(), "a,b,c,d e\n"; got != want {
+		t.Errorf("echo -i = %q, want %q", got, want)
+	}
+}
+
+// errWriter accepts limit bytes and then fails.
+type errWriter struct {
+	limit int
+	buf   bytes.Buffer
+}
+
+var errFull = errors.New("disk full")
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		w.buf.Write(p[:w.limit])
+		n := w.limit
+		w.limit = 0
+		return n, errFull
+	}
+	w.limit -= len(p)
+	return w.buf.Write(p)
+}
+
+func TestWriteError(t *testing.T) {
+	saved := out
+	defer func() { out = saved }()
+	var tests = []struct {
+		limit int
+		opts  options
+		args  []string
+		err   error
+	}{
+		{0, options{newline: true, sep: " "}, []string{"a"}, errFull},
+		{1, options{newline: true, sep: " "}, []string{"a"}, errFull}, // the newline does not fit
+		{2, options{newline: true, sep: " "}, []string{"a"}, nil},
+		{1, options{sep: " "}, []string{"a"}, nil},
+		{3, options{newline: true, sep: " ", escapes: true}, []string{`abc\c`}, nil},
+		{0, options{newline: false, sep: " "}, nil, nil}, // nothing to write
+	}
+	for _, test := range tests {
+		w := &errWriter{limit: test.limit}
+		out = w
+		if err := echo(test.opts, test.args); err != test.err {
+			t.Errorf("echo(%+v, %q) with %d bytes of space = %v, want %v", test.opts, test.args, test.limit, err, test.err)
 		}
 	}
 }
@@ -0,0 +1,7 @@
+module palfind
+
+go 1.19
+
+require word v0.0.0
+
+replace word => ../word3
@@ -0,0 +1,149 @@
+// Palfind 在文本文件中查找回文的单词和短语。
+//
+// 它逐行读取文件，用word包的Words和Phrases找出每一行中至少k个字母的回文单词，
+// 以及从单词边界开始、在单词边界结束的回文短语（如"Step on no pets"），
+// 报告它们的位置。包含在更长的短语中的短语不报告，跨行的回文不会被发现。
+//
+// 用法：
+//
+//	$ palfind [-k 5] [-fold] [-digits] [-noaccents] [file...]
+//
+// 没有参数或者参数是"-"时读取标准输入。输出的格式是
+//
+//	文件名:行号:列号: word|phrase 回文
+//
+// 列号是从1开始的字符（rune）数。与grep相同，找到回文时退出码是0，没有找到时是1，出错时是2。
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"unicode/utf8"
+
+	"word"
+)
+
+// 退出码
+const (
+	exitFound    = 0
+	exitNotFound = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 解析命令行参数并查找回文，返回进程的退出码。
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("palfind", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	k := flags.Int("k", 5, "minimum length in letters")
+	fold := flags.Bool("fold", false, "use full case folding (ß matches ss)")
+	digits := flags.Bool("digits", false, "count digits as letters")
+	noAccents := flags.Bool("noaccents", false, "ignore accents (é matches e)")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *k < 1 {
+		fmt.Fprintln(stderr, "palfind: -k must be positive")
+		return exitError
+	}
+	f := finder{k: *k, mode: word.Default, out: stdout}
+	if *noAccents {
+		f.mode = word.StripMarks
+	}
+	if *fold {
+		f.mode |= word.FoldCase
+	}
+	if *digits {
+		f.mode |= word.Digits
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	failed := false
+	for _, name := range files {
+		if err := f.file(name, stdin); err != nil {
+			fmt.Fprintf(stderr, "palfind: %v\n", err)
+			failed = true
+		}
+	}
+	switch {
+	case failed:
+		return exitError
+	case f.found == 0:
+		return exitNotFound
+	}
+	return exitFound
+}
+
+// finder 查找回文并输出结果。
+type finder struct {
+	k     int
+	mode  word.Mode
+	out   io.Writer
+	found int
+}
+
+// file 查找文件name中的回文，name是"-"时读取stdin。
+func (f *finder) file(name string, stdin io.Reader) error {
+	r := stdin
+	if name == "-" {
+		name = "<stdin>"
+	} else {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		f.line(name, line, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+type match struct {
+	kind string
+	word.Span
+}
+
+// line 按位置顺序输出一行中的回文单词和短语。
+func (f *finder) line(name string, lineno int, text string) {
+	var matches []match
+	for _, sp := range word.Words(text, f.mode, f.k) {
+		matches = append(matches, match{"word", sp})
+	}
+	end := 0 // 已报告的短语的结尾
+	for _, sp := range word.Phrases(text, f.mode, f.k) {
+		if sp.End <= end {
+			continue // 在更长的短语之中，比如"Madam, in Eden, I'm Adam"中的"m Adam"
+		}
+		matches = append(matches, match{"phrase", sp})
+		end = sp.End
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].Len > matches[j].Len
+	})
+	for _, m := range matches {
+		col := utf8.RuneCountInString(text[:m.Start]) + 1
+		fmt.Fprintf(f.out, "%s:%d:%d: %s %s\n", name, lineno, col, m.kind, text[m.Start:m.End])
+		f.found++
+	}
+}
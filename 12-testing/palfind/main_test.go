@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const poem = `Madam, in Eden, I'm Adam.
+Was it a car or a cat I saw?
+Nothing here.
+The kayak and the racecar; Step on no pets!
+Ésope reste ici et se repose
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "poem.txt")
+	if err := os.WriteFile(file, []byte(poem), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		args  []string
+		stdin string
+		code  int
+		want  string
+	}{
+		{[]string{file}, "", exitFound, `
+poem.txt:1:1: phrase Madam, in Eden, I'm Adam
+poem.txt:1:1: word Madam
+poem.txt:2:1: phrase Was it a car or a cat I saw
+poem.txt:4:5: word kayak
+poem.txt:4:19: word racecar
+poem.txt:4:28: phrase Step on no pets
+`},
+		{[]string{"-k", "18", file}, "", exitFound, `
+poem.txt:2:1: phrase Was it a car or a cat I saw
+`},
+		{[]string{"-k", "18", "-noaccents", file}, "", exitFound, `
+poem.txt:2:1: phrase Was it a car or a cat I saw
+poem.txt:5:1: phrase Ésope reste ici et se repose
+`},
+		{[]string{"-k", "30"}, poem, exitNotFound, ""},
+		{[]string{"-k", "3"}, "wow, 12321 Straße ssarts", exitFound, `
+<stdin>:1:1: word wow
+`},
+		{[]string{"-k", "3", "-digits", "-fold"}, "wow, 12321 Straße ssarts", exitFound, `
+<stdin>:1:1: word wow
+<stdin>:1:6: word 12321
+<stdin>:1:12: phrase Straße ssarts
+`},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		got := strings.ReplaceAll(stdout.String(), dir+string(filepath.Separator), "")
+		if code != test.code || got != strings.TrimPrefix(test.want, "\n") {
+			t.Errorf("palfind %q: exit %d, output:\n%s\nwant exit %d, output:\n%s\nstderr: %s",
+				test.args, code, got, test.code, strings.TrimPrefix(test.want, "\n"), stderr.String())
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-k", "0"},
+		{"-x"},
+		{filepath.Join(t.TempDir(), "missing")},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, strings.NewReader(""), &stdout, &stderr); code != exitError {
+			t.Errorf("palfind %q: exit %d, want %d", args, code, exitError)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("palfind %q: no error message", args)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Main 是使用脚本的包的TestMain：它在临时目录中编译srcs（参数与Build相同），
+// 把结果存入*cmds，运行测试，删除临时目录后以测试的结果退出。
+//
+//	var cmds map[string]string
+//
+//	func TestMain(m *testing.M) {
+//		script.Main(m, &cmds, map[string]string{"echo": "."})
+//	}
+func Main(m *testing.M, cmds *map[string]string, srcs map[string]string) {
+	os.Exit(buildAndRun(m, cmds, srcs))
+}
+
+func buildAndRun(m *testing.M, cmds *map[string]string, srcs map[string]string) int {
+	dir, err := os.MkdirTemp("", "script-cmds")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	if *cmds, err = Build(dir, srcs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return m.Run()
+}
+
+// Build 把cmds中的每个命令编译到outDir中，返回命令名到可执行文件路径的映射，可以直接作为Params.Cmds。
+// cmds的值是包的目录或者单个.go文件（章节中的程序都是单个文件）。
+// 单个文件在它所在的目录中编译，所以不受当前模块的go.mod影响。
+func Build(outDir string, cmds map[string]string) (map[string]string, error) {
+	bins := make(map[string]string, len(cmds))
+	for name, src := range cmds {
+		src, err := filepath.Abs(src)
+		if err != nil {
+			return nil, err
+		}
+		bin := filepath.Join(outDir, name)
+		if runtime.GOOS == "windows" {
+			bin += ".exe"
+		}
+		cmd := exec.Command("go", "build", "-o", bin)
+		if strings.HasSuffix(src, ".go") {
+			cmd.Dir = filepath.Dir(src)
+			cmd.Args = append(cmd.Args, filepath.Base(src))
+		} else {
+			cmd.Dir = src
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("building %s: %v\n%s", name, err, out)
+		}
+		bins[name] = bin
+	}
+	return bins, nil
+}
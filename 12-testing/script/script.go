@@ -0,0 +1,611 @@
+// Package script 用txtar格式的脚本测试命令行程序，思路与testscript相同。
+//
+// 12-testing/echo的TestEcho通过替换包级变量out来测试命令，这样只能测试echo函数，
+// 测不到参数解析、标准输入、退出码这些main函数里的东西。script把程序编译成可执行文件，
+// 在临时目录中按脚本运行它，检查输出和退出码。
+//
+// 每个脚本是一个txtar文件：注释部分是命令，文件部分在运行前被写到工作目录$WORK中，
+// 可以作为目录树、标准输入或者期望的输出：
+//
+//	# 统计目录树中的文件
+//	exec du tree
+//	stdout '^2 files 0.0 MB$'
+//
+//	! exec du missing
+//	status 1
+//	stderr 'no such file'
+//
+//	-- tree/a.txt --
+//	hello
+//	-- tree/sub/b.txt --
+//	world
+//
+// 每一行是一条命令，参数用空白分隔。'...'中的内容原样保留（两个单引号表示一个单引号），
+// "..."按Go的字符串字面量解释（可以写"\t"）。单引号之外的$NAME和${NAME}被替换为环境变量，
+// 替换的结果不再拆分，所以正则表达式应该放在单引号中。#开始的行是注释。
+// 命令前面加上"! "表示期望命令失败（对exec来说是退出码不为0；对stdout等来说是不匹配）。
+//
+// 支持的命令：
+//
+//	exec program [args...]  运行程序。program先在Params.Cmds中查找，然后在PATH中查找。
+//	                        最后一个参数是&时程序在后台运行，exec不等它退出
+//	wait [program...]       等待后台程序退出，没有参数时等待所有后台程序。
+//	                        stdout和stderr是它们按启动顺序连接起来的输出
+//	kill                    杀死所有后台程序，不检查它们的输出和退出码
+//	stdin [-open] file      下一条exec的标准输入是file的内容；没有stdin时标准输入是空的。
+//	                        -open表示读完内容之后标准输入不结束（读不到EOF），直到程序退出
+//	stdout [-count=N] regex 上一条exec的标准输出匹配regex（多行模式，^和$匹配每一行）
+//	stderr [-count=N] regex 上一条exec的标准错误匹配regex
+//	status N                上一条exec的退出码是N
+//	cmp file1 file2         两个文件的内容相同，文件名stdout和stderr表示上一条exec的输出
+//	env KEY=VALUE...        设置环境变量
+//	cd dir                  改变之后的命令的工作目录
+//	exists path...          文件存在
+//	mkdir path...           创建目录
+//	rm path...              删除文件或目录
+//
+// 后台程序的退出码在wait时检查，"! exec program &"表示期望它失败。后台程序同样受Params.Timeout限制，
+// 脚本结束时还在运行的后台程序被杀死，所以服务器之类不会自己退出的程序可以放在后台，不必kill。
+package script
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Params 是运行脚本的参数。
+type Params struct {
+	// Dir 是包含脚本的目录，其中每个*.txtar文件作为一个子测试运行。
+	Dir string
+	// Cmds 把exec的命令名映射到可执行文件的路径，通常是Build的返回值。
+	Cmds map[string]string
+	// Env 是额外的环境变量，形如"KEY=VALUE"。
+	Env []string
+	// Timeout 是每条exec的超时，为0时是10秒。超时的程序被杀死，脚本失败。
+	Timeout time.Duration
+}
+
+// Run 把p.Dir中的每个脚本作为t的一个并行的子测试运行。
+func Run(t *testing.T, p Params) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(p.Dir, "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no scripts in %s", p.Dir)
+	}
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".txtar"), func(t *testing.T) {
+			t.Parallel()
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var log strings.Builder
+			if err := runScript(&p, file, data, t.TempDir(), &log); err != nil {
+				t.Fatalf("\n%s\nFAIL: %v", log.String(), err)
+			}
+		})
+	}
+}
+
+// runScript 在work目录中运行脚本，把运行过程写到log中。
+func runScript(p *Params, file string, data []byte, work string, log io.Writer) error {
+	a := Parse(data)
+	if err := a.extract(work); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	s := &state{p: p, dir: work, log: log, status: -1}
+	defer s.kill(false, nil) // 脚本结束时还在运行的后台程序
+	s.env = append([]string{
+		"WORK=" + work,
+		"HOME=/no-home",
+		"PATH=" + os.Getenv("PATH"),
+		"TMPDIR=" + filepath.Join(work, ".tmp"),
+	}, p.Env...)
+	if err := os.Mkdir(filepath.Join(work, ".tmp"), 0o777); err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(a.Comment), "\n") {
+		if err := s.line(line); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", file, i+1, strings.TrimSpace(line), err)
+		}
+	}
+	return nil
+}
+
+// state 是运行一个脚本时的状态。
+type state struct {
+	p   *Params
+	dir string // 当前目录
+	env []string
+	log io.Writer
+
+	stdin          *input  // 下一条exec的标准输入
+	bg             []*proc // 在后台运行的程序
+	stdout, stderr string  // 上一条exec的输出
+	status         int     // 上一条exec的退出码，还没有运行过exec时是-1
+}
+
+// input 是stdin命令给出的标准输入。
+type input struct {
+	data string
+	open bool // 读完data之后不结束，直到程序退出
+}
+
+type command func(s *state, neg bool, args []string) error
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"exec":   (*state).exec,
+		"stdin":  (*state).setStdin,
+		"stdout": func(s *state, neg bool, args []string) error { return s.match("stdout", s.stdout, neg, args) },
+		"stderr": func(s *state, neg bool, args []string) error { return s.match("stderr", s.stderr, neg, args) },
+		"status": (*state).checkStatus,
+		"cmp":    (*state).cmp,
+		"env":    (*state).setEnv,
+		"cd":     (*state).cd,
+		"exists": (*state).exists,
+		"mkdir":  (*state).mkdir,
+		"rm":     (*state).rm,
+		"wait":   (*state).wait,
+		"kill":   (*state).kill,
+	}
+}
+
+// 不能加"!"的命令
+var positiveOnly = map[string]bool{"stdin": true, "env": true, "cd": true, "mkdir": true, "rm": true, "status": true, "wait": true, "kill": true}
+
+func (s *state) line(line string) error {
+	args, err := s.fields(line)
+	if err != nil || len(args) == 0 {
+		return err
+	}
+	fmt.Fprintf(s.log, "> %s\n", strings.TrimSpace(line))
+	neg := false
+	if args[0] == "!" {
+		neg, args = true, args[1:]
+		if len(args) == 0 {
+			return errors.New("missing command after !")
+		}
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if neg && positiveOnly[args[0]] {
+		return fmt.Errorf("%s does not support !", args[0])
+	}
+	return cmd(s, neg, args[1:])
+}
+
+// fields 把一行拆分成参数，处理引号和环境变量。
+func (s *state) fields(line string) ([]string, error) {
+	var (
+		args   []string
+		cur    strings.Builder
+		inWord bool
+	)
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+			i++
+		case c == '#' && !inWord:
+			i = len(line)
+		case c == '\'':
+			j := i + 1
+			for {
+				k := strings.IndexByte(line[j:], '\'')
+				if k < 0 {
+					return nil, errors.New("unterminated ' string")
+				}
+				cur.WriteString(line[j : j+k])
+				j += k + 1
+				if j < len(line) && line[j] == '\'' { // ''表示一个单引号
+					cur.WriteByte('\'')
+					j++
+					continue
+				}
+				break
+			}
+			i, inWord = j, true
+		case c == '"':
+			q, err := strconv.QuotedPrefix(line[i:])
+			if err != nil {
+				return nil, fmt.Errorf("bad \" string: %v", err)
+			}
+			u, _ := strconv.Unquote(q)
+			cur.WriteString(os.Expand(u, s.getenv))
+			i, inWord = i+len(q), true
+		default:
+			j := i
+			for j < len(line) && !strings.ContainsRune(" \t\r'\"", rune(line[j])) {
+				j++
+			}
+			cur.WriteString(os.Expand(line[i:j], s.getenv))
+			i, inWord = j, true
+		}
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func (s *state) getenv(key string) string {
+	for i := len(s.env) - 1; i >= 0; i-- {
+		if k, v, _ := strings.Cut(s.env[i], "="); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// path 把脚本中的相对路径解释为相对于当前目录的路径。
+func (s *state) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+func (s *state) exec(neg bool, args []string) error {
+	bg := len(args) > 0 && args[len(args)-1] == "&"
+	if bg {
+		args = args[:len(args)-1]
+	}
+	if len(args) == 0 {
+		return errors.New("usage: exec program [args...] [&]")
+	}
+	program, ok := s.p.Cmds[args[0]]
+	if !ok {
+		var err error
+		if program, err = exec.LookPath(args[0]); err != nil {
+			return err
+		}
+	}
+	timeout := s.p.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	p := &proc{name: args[0], neg: neg, timeout: timeout, done: make(chan struct{})}
+	p.ctx, p.cancel = context.WithTimeout(context.Background(), timeout)
+	cmd := exec.CommandContext(p.ctx, program, args[1:]...)
+	cmd.Dir = s.dir
+	cmd.Env = s.env
+	cmd.Stdout, cmd.Stderr = &p.stdout, &p.stderr
+	in := s.stdin
+	s.stdin = nil
+	go func() {
+		p.err = run(cmd, in)
+		close(p.done)
+	}()
+	if bg {
+		s.bg = append(s.bg, p)
+		return nil
+	}
+	return s.finish(p)
+}
+
+// proc 是exec运行的一个程序。
+type proc struct {
+	name    string
+	neg     bool
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	stdout, stderr bytes.Buffer
+	done           chan struct{} // 程序退出之后关闭
+	err            error         // run的结果，done关闭之后才能读
+}
+
+// finish 等待p退出，把它的输出和退出码作为上一条exec的结果，检查退出码是否符合预期。
+func (s *state) finish(p *proc) error {
+	<-p.done
+	timedOut := p.ctx.Err() == context.DeadlineExceeded
+	p.cancel()
+	s.stdout, s.stderr = p.stdout.String(), p.stderr.String()
+	s.logOutput(s.stdout, s.stderr)
+	if timedOut {
+		return fmt.Errorf("timed out after %v", p.timeout)
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case p.err == nil:
+		s.status = 0
+	case errors.As(p.err, &exitErr):
+		s.status = exitErr.ExitCode()
+		fmt.Fprintf(s.log, "[%v]\n", p.err)
+	default:
+		return p.err
+	}
+	if p.neg && s.status == 0 {
+		return errors.New("unexpected success")
+	}
+	if !p.neg && s.status != 0 {
+		return fmt.Errorf("unexpected exit status %d", s.status)
+	}
+	return nil
+}
+
+func (s *state) logOutput(stdout, stderr string) {
+	if stdout != "" {
+		fmt.Fprintf(s.log, "[stdout]\n%s", stdout)
+	}
+	if stderr != "" {
+		fmt.Fprintf(s.log, "[stderr]\n%s", stderr)
+	}
+}
+
+// run 运行cmd，in是前面的stdin命令给出的标准输入，为nil时标准输入是空的。
+func run(cmd *exec.Cmd, in *input) error {
+	if in == nil {
+		return cmd.Run()
+	}
+	if !in.open {
+		cmd.Stdin = strings.NewReader(in.data)
+		return cmd.Run()
+	}
+	// 程序退出之后才关闭管道写的一端，所以程序读完data之后会阻塞在读上，而不是读到EOF。
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = r
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		w.Close()
+		return err
+	}
+	written := make(chan struct{})
+	go func() {
+		w.WriteString(in.data) // 程序没有读完就退出时写入失败，剩下的内容被丢弃
+		close(written)
+	}()
+	err = cmd.Wait()
+	<-written
+	w.Close()
+	return err
+}
+
+// wait 等待名字在args中的后台程序退出，args为空时等待所有后台程序。
+// stdout和stderr是它们按启动顺序连接起来的输出，退出码是最后一个程序的退出码。
+func (s *state) wait(_ bool, args []string) error {
+	for _, name := range args {
+		if !s.running(name) {
+			return fmt.Errorf("no background program %s", name)
+		}
+	}
+	var stdout, stderr strings.Builder
+	var firstErr error
+	bg := s.bg[:0]
+	for _, p := range s.bg {
+		if len(args) > 0 && !contains(args, p.name) {
+			bg = append(bg, p)
+			continue
+		}
+		fmt.Fprintf(s.log, "[wait %s]\n", p.name)
+		if err := s.finish(p); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v", p.name, err)
+		}
+		stdout.WriteString(s.stdout)
+		stderr.WriteString(s.stderr)
+	}
+	s.bg = bg
+	s.stdout, s.stderr = stdout.String(), stderr.String()
+	return firstErr
+}
+
+// running 判断是否有名字是name的后台程序。
+func (s *state) running(name string) bool {
+	for _, p := range s.bg {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// kill 杀死所有后台程序并等待它们退出，不检查它们的输出和退出码。
+func (s *state) kill(_ bool, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: kill")
+	}
+	for _, p := range s.bg {
+		p.cancel()
+		<-p.done
+		fmt.Fprintf(s.log, "[killed %s]\n", p.name)
+		s.logOutput(p.stdout.String(), p.stderr.String())
+	}
+	s.bg = nil
+	return nil
+}
+
+func (s *state) setStdin(_ bool, args []string) error {
+	in := new(input)
+	if len(args) > 0 && args[0] == "-open" {
+		in.open, args = true, args[1:]
+	}
+	if len(args) != 1 {
+		return errors.New("usage: stdin [-open] file")
+	}
+	data, err := os.ReadFile(s.path(args[0]))
+	if err != nil {
+		return err
+	}
+	in.data = string(data)
+	s.stdin = in
+	return nil
+}
+
+func (s *state) match(name, text string, neg bool, args []string) error {
+	count := -1
+	if len(args) > 0 && strings.HasPrefix(args[0], "-count=") {
+		n, err := strconv.Atoi(strings.TrimPrefix(args[0], "-count="))
+		if err != nil || n < 0 {
+			return fmt.Errorf("bad %s", args[0])
+		}
+		count, args = n, args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s [-count=N] regex", name)
+	}
+	if neg && count >= 0 {
+		return fmt.Errorf("! %s does not support -count", name)
+	}
+	if s.status < 0 {
+		return fmt.Errorf("no exec before %s", name)
+	}
+	re, err := regexp.Compile("(?m)" + args[0])
+	if err != nil {
+		return err
+	}
+	switch n := len(re.FindAllStringIndex(text, -1)); {
+	case neg && n > 0:
+		return fmt.Errorf("unexpected match for %#q in %s", args[0], name)
+	case !neg && count < 0 && n == 0:
+		return fmt.Errorf("no match for %#q in %s", args[0], name)
+	case count >= 0 && n != count:
+		return fmt.Errorf("%d matches for %#q in %s, want %d", n, args[0], name, count)
+	}
+	return nil
+}
+
+func (s *state) checkStatus(_ bool, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: status N")
+	}
+	want, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	if s.status != want {
+		return fmt.Errorf("exit status is %d, want %d", s.status, want)
+	}
+	return nil
+}
+
+// read 返回文件的内容，stdout和stderr表示上一条exec的输出。
+func (s *state) read(name string) (string, error) {
+	switch name {
+	case "stdout":
+		return s.stdout, nil
+	case "stderr":
+		return s.stderr, nil
+	}
+	data, err := os.ReadFile(s.path(name))
+	return string(data), err
+}
+
+func (s *state) cmp(neg bool, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: cmp file1 file2")
+	}
+	got, err := s.read(args[0])
+	if err != nil {
+		return err
+	}
+	want, err := s.read(args[1])
+	if err != nil {
+		return err
+	}
+	switch {
+	case neg && got == want:
+		return fmt.Errorf("%s and %s are the same", args[0], args[1])
+	case !neg && got != want:
+		return fmt.Errorf("%s and %s differ:\n--- %s\n%s--- %s\n%s", args[0], args[1], args[0], got, args[1], want)
+	}
+	return nil
+}
+
+func (s *state) setEnv(_ bool, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: env KEY=VALUE...")
+	}
+	for _, kv := range args {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+			return fmt.Errorf("bad environment variable %q", kv)
+		}
+		s.env = append(s.env, kv)
+	}
+	return nil
+}
+
+func (s *state) cd(_ bool, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: cd dir")
+	}
+	dir := s.path(args[0])
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+	s.dir = dir
+	return nil
+}
+
+func (s *state) exists(neg bool, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: exists path...")
+	}
+	for _, name := range args {
+		_, err := os.Stat(s.path(name))
+		switch {
+		case neg && err == nil:
+			return fmt.Errorf("%s exists", name)
+		case !neg && err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *state) mkdir(_ bool, args []string) error {
+	for _, name := range args {
+		if err := os.MkdirAll(s.path(name), 0o777); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *state) rm(_ bool, args []string) error {
+	for _, name := range args {
+		if err := os.RemoveAll(s.path(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 设置了SCRIPT_HELPER时，测试程序本身作为脚本中的helper命令运行：
+//
+//	helper echo args...   把参数用空格连接后输出到标准输出
+//	helper cat            把标准输入复制到标准输出
+//	helper line           把标准输入的第一行复制到标准输出
+//	helper warn args...   输出到标准错误
+//	helper exit N         以退出码N退出
+//	helper sleep d        睡眠d
+func TestMain(m *testing.M) {
+	if os.Getenv("SCRIPT_HELPER") == "1" {
+		os.Exit(helper(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+func helper(args []string) int {
+	if len(args) == 0 {
+		return 2
+	}
+	switch args[0] {
+	case "echo":
+		fmt.Println(strings.Join(args[1:], " "))
+	case "cat":
+		io.Copy(os.Stdout, os.Stdin)
+	case "line":
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		fmt.Print(line)
+	case "warn":
+		fmt.Fprintln(os.Stderr, strings.Join(args[1:], " "))
+	case "exit":
+		n, _ := strconv.Atoi(args[1])
+		return n
+	case "sleep":
+		d, _ := time.ParseDuration(args[1])
+		time.Sleep(d)
+	default:
+		return 2
+	}
+	return 0
+}
+
+var helperParams = Params{
+	Cmds: map[string]string{"helper": os.Args[0]},
+	Env:  []string{"SCRIPT_HELPER=1"},
+}
+
+func TestScripts(t *testing.T) {
+	p := helperParams
+	p.Dir = "testdata/script"
+	Run(t, p)
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		data    string
+		comment string
+		files   string
+	}{
+		{"", "", ""},
+		{"exec a\n", "exec a\n", ""},
+		{"exec a\n-- f --\nx\n-- d/g --\ny\nz\n", "exec a\n", "f:\"x\\n\" d/g:\"y\\nz\\n\""},
+		{"-- f --\n", "", "f:\"\""},
+		{"-- f --\nno newline", "", "f:\"no newline\""},
+		{"-- f --\r\nx\r\n", "", "f:\"x\\r\\n\""},
+		{"--  --\n-- f --x\n-f-\n", "--  --\n-- f --x\n-f-\n", ""}, // 不是文件标记
+	} {
+		a := Parse([]byte(test.data))
+		var files []string
+		for _, f := range a.Files {
+			files = append(files, fmt.Sprintf("%s:%q", f.Name, f.Data))
+		}
+		if string(a.Comment) != test.comment || strings.Join(files, " ") != test.files {
+			t.Errorf("Parse(%q) = %q, [%s]; want %q, [%s]", test.data, a.Comment, strings.Join(files, " "), test.comment, test.files)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	s := &state{env: []string{"A=1", "B=two words", "A=3"}}
+	for _, test := range []struct {
+		line string
+		want string
+	}{
+		{"", "[]"},
+		{"  # comment", "[]"},
+		{"exec a b\tc", `["exec" "a" "b" "c"]`},
+		{"echo $A ${A}x $B", `["echo" "3" "3x" "two words"]`},
+		{"echo '$A' 'it''s' ''", `["echo" "$A" "it's" ""]`},
+		{`echo "a\tb" "$B"`, `["echo" "a\tb" "two words"]`},
+		{`echo a'b c'"d"`, `["echo" "ab cd"]`},
+		{"echo a#b # c", `["echo" "a#b"]`},
+		{"stdout '^x$' # comment", `["stdout" "^x$"]`},
+	} {
+		args, err := s.fields(test.line)
+		if err != nil {
+			t.Errorf("fields(%q) failed: %v", test.line, err)
+			continue
+		}
+		if got := fmt.Sprintf("%q", args); got != test.want {
+			t.Errorf("fields(%q) = %s, want %s", test.line, got, test.want)
+		}
+	}
+	for _, line := range []string{"echo 'a", `echo "a`, `echo "\q"`} {
+		if _, err := s.fields(line); err == nil {
+			t.Errorf("fields(%q) succeeded", line)
+		}
+	}
+}
+
+// TestErrors 检查失败的脚本报告出错的行。
+func TestErrors(t *testing.T) {
+	for _, test := range []struct {
+		script string
+		err    string
+	}{
+		{"nope", "x.txtar:1: nope: unknown command"},
+		{"!", "missing command"},
+		{"exec helper exit 1", "unexpected exit status 1"},
+		{"! exec helper echo", "unexpected success"},
+		{"exec no-such-program", "executable file not found"},
+		{"stdout x", "no exec before stdout"},
+		{"exec helper echo a\n\nstdout b", "x.txtar:3: stdout b: no match for `b` in stdout"},
+		{"exec helper echo a\n! stdout a", "unexpected match"},
+		{"exec helper echo a a\nstdout -count=1 a", "2 matches for `a` in stdout, want 1"},
+		{"exec helper warn a\nstderr b", "no match for `b` in stderr"},
+		{"! exec helper exit 3\nstatus 4", "exit status is 3, want 4"},
+		{"! status 0", "does not support !"},
+		{"exec helper echo a\ncmp stdout want\n-- want --\nb\n", "stdout and want differ"},
+		{"exists missing", "no such file"},
+		{"cd f\n-- f --\n", "not a directory"},
+		{"env X", "bad environment variable"},
+		{"stdin missing", "no such file"},
+		{"stdin -open", "usage: stdin [-open] file"},
+		{"exec &", "usage: exec"},
+		{"exec helper exit 1 &\nexec helper echo\nwait", "x.txtar:3: wait: helper: unexpected exit status 1"},
+		{"! kill", "does not support !"},
+		{"exec helper sleep 1m &\nwait other", "no background program other"},
+		{"-- ../f --\n", "outside the work directory"},
+	} {
+		err := runScript(&helperParams, "x.txtar", []byte(test.script), t.TempDir(), io.Discard)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("script %q: error %v, want %q", test.script, err, test.err)
+		}
+	}
+
+	p := helperParams
+	p.Timeout = 500 * time.Millisecond
+	if err := runScript(&p, "x.txtar", []byte("exec helper sleep 1m"), t.TempDir(), io.Discard); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("sleeping past the timeout: error %v", err)
+	}
+	if err := runScript(&p, "x.txtar", []byte("exec helper sleep 1m &\nwait"), t.TempDir(), io.Discard); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("waiting for a background program past the timeout: error %v", err)
+	}
+	script := "stdin -open in\nexec helper cat\n-- in --\nx\n" // cat读不到EOF
+	if err := runScript(&p, "x.txtar", []byte(script), t.TempDir(), io.Discard); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("cat with stdin -open: error %v", err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	src := t.TempDir()
+	prog := "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"built\") }\n"
+	if err := os.WriteFile(src+"/hello.go", []byte(prog), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	cmds, err := Build(t.TempDir(), map[string]string{"hello": src + "/hello.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "exec hello\nstdout '^built$'\n"
+	if err := runScript(&Params{Cmds: cmds}, "x.txtar", []byte(script), t.TempDir(), io.Discard); err != nil {
+		t.Error(err)
+	}
+	if _, err := Build(t.TempDir(), map[string]string{"bad": src + "/missing.go"}); err == nil {
+		t.Error("Build of a missing file succeeded")
+	}
+}
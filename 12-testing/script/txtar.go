@@ -0,0 +1,86 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Archive 是一个txtar文件：开头的注释（也就是脚本），后面是若干个文件。
+//
+//	# 这里是脚本
+//	exec echo hello
+//	-- a.txt --
+//	a.txt的内容
+//	-- dir/b.txt --
+//	dir/b.txt的内容
+//
+// 文件以"-- 名字 --"开始，到下一个文件或者txtar的结尾为止。
+type Archive struct {
+	Comment []byte
+	Files   []File
+}
+
+// File 是Archive中的一个文件。
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Parse 解析txtar格式的数据。
+func Parse(data []byte) *Archive {
+	a := new(Archive)
+	var name string
+	a.Comment, name, data = findFile(data)
+	for name != "" {
+		f := File{Name: name}
+		f.Data, name, data = findFile(data)
+		a.Files = append(a.Files, f)
+	}
+	return a
+}
+
+// findFile 返回下一个文件标记之前的内容、下一个文件的名字和它之后的数据。
+func findFile(data []byte) (before []byte, name string, after []byte) {
+	for i := 0; i < len(data); {
+		line := data[i:]
+		end := bytes.IndexByte(line, '\n')
+		if end >= 0 {
+			line = line[:end+1]
+		}
+		if n := markerName(line); n != "" {
+			return data[:i], n, data[i+len(line):]
+		}
+		i += len(line)
+	}
+	return data, "", nil
+}
+
+// markerName 在line是"-- name --"时返回name。
+func markerName(line []byte) string {
+	s := strings.TrimRight(string(line), "\r\n")
+	if !strings.HasPrefix(s, "-- ") || !strings.HasSuffix(s, " --") || len(s) < len("-- x --") {
+		return ""
+	}
+	return strings.TrimSpace(s[3 : len(s)-3])
+}
+
+// extract 把a中的文件写到dir下。文件名必须是dir下的相对路径。
+func (a *Archive) extract(dir string) error {
+	for _, f := range a.Files {
+		name := filepath.Clean(filepath.FromSlash(f.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("file %q is outside the work directory", f.Name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.Data, 0o666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+//go:build ignore
+
+// Gen writes tables.go from the Unicode Character Database.
+//
+// Download UnicodeData.txt, CompositionExclusions.txt and CaseFolding.txt
+// from https://www.unicode.org/Public/<version>/ucd/ into a directory and run
+//
+//	go run gen.go -ucd dir
+//
+// Pick the version that matches unicode.Version of the Go toolchain, so that
+// the tables agree with unicode.IsLetter and friends; TestUnicodeVersion
+// checks this. The checked-in tables are from Unicode 17.0.0, the version of
+// Go 1.27. Go 1.21 to 1.26 have Unicode 15.0.0 and need tables from 15.0.0.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ucd    = flag.String("ucd", "ucd", "directory containing the UCD files")
+	output = flag.String("o", "tables.go", "output file")
+)
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("gen: ")
+
+	decomp := map[rune][]rune{} // canonical, one step
+	class := map[rune]uint8{}
+	scan("UnicodeData.txt", func(f []string) {
+		r := parseRune(f[0])
+		if c, _ := strconv.Atoi(f[3]); c != 0 {
+			class[r] = uint8(c)
+		}
+		if f[5] != "" && !strings.HasPrefix(f[5], "<") { // <compat> and friends are not canonical
+			for _, s := range strings.Fields(f[5]) {
+				decomp[r] = append(decomp[r], parseRune(s))
+			}
+		}
+	})
+
+	// A pair composes unless its composite is in Full_Composition_Exclusion:
+	// the exclusions listed in the file, singletons and non-starter decompositions.
+	excluded := map[rune]bool{}
+	scan("CompositionExclusions.txt", func(f []string) { excluded[parseRune(f[0])] = true })
+	compose := map[[2]rune]rune{}
+	for r, d := range decomp {
+		if len(d) == 2 && !excluded[r] && class[r] == 0 && class[d[0]] == 0 {
+			compose[[2]rune{d[0], d[1]}] = r
+		}
+	}
+
+	folding := map[rune][]rune{} // full foldings that differ from the simple ones
+	var version string
+	scan("CaseFolding.txt", func(f []string) {
+		if strings.TrimSpace(f[1]) != "F" {
+			return
+		}
+		r := parseRune(f[0])
+		for _, s := range strings.Fields(f[2]) {
+			folding[r] = append(folding[r], parseRune(s))
+		}
+	})
+	version = ucdVersion("CaseFolding.txt")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen.go from Unicode %s; DO NOT EDIT.\n\npackage word\n\n", version)
+	fmt.Fprintf(&b, "// UnicodeVersion is the version of the Unicode Character Database the tables come from.\n")
+	fmt.Fprintf(&b, "const UnicodeVersion = %q\n\n", version)
+
+	fmt.Fprintf(&b, "// decompositions maps a rune to its canonical decomposition (one step; apply repeatedly).\n")
+	fmt.Fprintf(&b, "var decompositions = map[rune]string{\n")
+	for _, r := range sortedKeys(decomp) {
+		fmt.Fprintf(&b, "\t%#04x: %+q,\n", r, string(decomp[r]))
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// compositions is the inverse of decompositions for the pairs that canonical composition may join.\n")
+	fmt.Fprintf(&b, "var compositions = map[[2]rune]rune{\n")
+	pairs := make([][2]rune, 0, len(compose))
+	for p := range compose {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return compose[pairs[i]] < compose[pairs[j]] })
+	for _, p := range pairs {
+		fmt.Fprintf(&b, "\t{%#04x, %#04x}: %#04x,\n", p[0], p[1], compose[p])
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// combiningClasses lists the runs of runes with a nonzero canonical combining class.\n")
+	fmt.Fprintf(&b, "var combiningClasses = []classRange{\n")
+	runes := sortedKeys(class)
+	for i := 0; i < len(runes); {
+		j := i
+		for j+1 < len(runes) && runes[j+1] == runes[j]+1 && class[runes[j+1]] == class[runes[i]] {
+			j++
+		}
+		fmt.Fprintf(&b, "\t{%#04x, %#04x, %d},\n", runes[i], runes[j], class[runes[i]])
+		i = j + 1
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// fullFoldings holds the case foldings that map one rune to several (status F in CaseFolding.txt).\n")
+	fmt.Fprintf(&b, "var fullFoldings = map[rune]string{\n")
+	for _, r := range sortedKeys(folding) {
+		fmt.Fprintf(&b, "\t%#04x: %+q,\n", r, string(folding[r]))
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o666); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// scan calls f with the semicolon-separated fields of each data line of a UCD file.
+func scan(name string, f func(fields []string)) {
+	file, err := os.Open(filepath.Join(*ucd, name))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		f(fields)
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ucdVersion reads the version from the "# Name-15.0.0.txt" header line of a UCD file.
+func ucdVersion(name string) string {
+	data, err := os.ReadFile(filepath.Join(*ucd, name))
+	if err != nil {
+		log.Fatal(err)
+	}
+	first, _, _ := strings.Cut(string(data), "\n")
+	prefix := "# " + strings.TrimSuffix(name, ".txt") + "-"
+	if !strings.HasPrefix(first, prefix) || !strings.HasSuffix(first, ".txt") {
+		log.Fatalf("%s: no version in header %q", name, first)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(first, prefix), ".txt")
+}
+
+func parseRune(s string) rune {
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rune(r)
+}
+
+func sortedKeys[V any](m map[rune]V) []rune {
+	keys := make([]rune, 0, len(m))
+	for r := range m {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,366 @@
+package word
+
+//go:generate go run gen.go -ucd ucd
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Mode selects how text is normalized before it is compared.
+// Modes are bit flags; the zero Mode compares lower-cased letters rune by
+// rune with no normalization, which is what IsPalindrome used to do.
+//
+// NFC, NFD, StripMarks and FoldCase all make canonically equivalent texts
+// compare equal: "é" written as U+00E9 or as U+0065 U+0301 is the same letter.
+// The text is canonically decomposed, transformed, and composed again
+// unless NFD is set.
+type Mode uint
+
+const (
+	NFC        Mode = 1 << iota // compare canonically composed text
+	NFD                         // compare canonically decomposed text
+	StripMarks                  // remove nonspacing marks, so diacritics are ignored
+	FoldCase                    // full case folding ("ß" matches "ss") instead of lower-casing
+	Digits                      // count decimal digits as well as letters
+	Graphemes                   // compare grapheme clusters instead of runes
+)
+
+var modeNames = []string{"NFC", "NFD", "StripMarks", "FoldCase", "Digits", "Graphemes"}
+
+func (m Mode) String() string {
+	if m == 0 {
+		return "0"
+	}
+	var names []string
+	for i, name := range modeNames {
+		if m&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Default is the Mode used by IsPalindrome.
+//
+// NFC composes a letter and its marks into one rune where Unicode has a
+// precomposed form, so "été" is a palindrome however it is spelled, while
+// "éte" is not: an accented letter differs from the plain one. A mark with
+// no precomposed form is not a letter and is skipped in rune-by-rune
+// comparison; add Graphemes to compare it, or use StripMarks to ignore
+// diacritics altogether.
+const Default = NFC
+
+// IsPalindromeMode reports whether s reads the same forward and backward
+// after normalization with mode.
+//
+// Without Graphemes the letters of s (and digits, with Digits) are
+// compared rune by rune and everything else is ignored. With Graphemes the
+// elements compared are user-perceived characters: a letter together with
+// its combining marks, a Hangul syllable or an emoji sequence. A cluster
+// counts when its first rune is a letter (or digit), so "é" and "e"
+// differ unless StripMarks is set.
+func IsPalindromeMode(s string, mode Mode) bool {
+	return isPalindrome(normalize(s, mode))
+}
+
+// Normalize returns the elements of s that IsPalindromeMode compares.
+func Normalize(s string, mode Mode) []string {
+	units := normalize(s, mode)
+	elems := make([]string, len(units))
+	for i, u := range units {
+		elems[i] = u.text
+	}
+	return elems
+}
+
+// A unit is one compared element together with the bytes of the input it
+// came from. Runes from the same grapheme cluster share its byte range.
+type unit struct {
+	text       string
+	start, end int
+}
+
+func normalize(s string, mode Mode) []unit {
+	var units []unit
+	var buf []rune
+	for start := 0; start < len(s); {
+		end := start + clusterLen(s[start:])
+		buf = normalizeCluster(buf[:0], s[start:end], mode)
+		if mode&Graphemes != 0 {
+			if len(buf) > 0 && counts(buf[0], mode) {
+				units = append(units, unit{string(buf), start, end})
+			}
+		} else {
+			for _, r := range buf {
+				if counts(r, mode) {
+					units = append(units, unit{string(r), start, end})
+				}
+			}
+		}
+		start = end
+	}
+	return units
+}
+
+// counts reports whether r takes part in the comparison.
+func counts(r rune, mode Mode) bool {
+	return unicode.IsLetter(r) || mode&Digits != 0 && unicode.IsDigit(r)
+}
+
+// normalizeCluster appends the normalized runes of the cluster c to b.
+func normalizeCluster(b []rune, c string, mode Mode) []rune {
+	if len(c) == 1 { // ASCII: every mode just lower-cases it
+		return append(b, unicode.ToLower(rune(c[0])))
+	}
+	if mode&(NFC|NFD|StripMarks|FoldCase) == 0 {
+		for _, r := range c {
+			b = append(b, unicode.ToLower(r))
+		}
+		return b
+	}
+	var d []rune
+	for _, r := range c {
+		d = decompose(d, r)
+	}
+	if mode&FoldCase != 0 {
+		// Canonical caseless matching: NFD(fold(NFD(s))). The order of the
+		// marks matters here, because U+0345 folds to the letter ι.
+		reorder(d)
+		var f []rune
+		for _, r := range d {
+			f = fold(f, r)
+		}
+		d = d[:0]
+		for _, r := range f {
+			d = decompose(d, r)
+		}
+	} else {
+		for i, r := range d {
+			d[i] = unicode.ToLower(r)
+		}
+	}
+	if mode&StripMarks != 0 {
+		kept := d[:0]
+		for _, r := range d {
+			if !unicode.Is(unicode.Mn, r) {
+				kept = append(kept, r)
+			}
+		}
+		d = kept
+	}
+	reorder(d)
+	if mode&NFD == 0 {
+		d = compose(d)
+	}
+	return append(b, d...)
+}
+
+// fold appends the full case folding of r to b.
+func fold(b []rune, r rune) []rune {
+	if f, ok := fullFoldings[r]; ok {
+		for _, r := range f {
+			b = append(b, r)
+		}
+		return b
+	}
+	if r == 'ı' { // dotless i has no folding, but ToUpper would make it I
+		return append(b, r)
+	}
+	return append(b, unicode.ToLower(unicode.ToUpper(r)))
+}
+
+// Hangul syllables are decomposed and composed algorithmically.
+const (
+	sBase, lBase, vBase, tBase = 0xAC00, 0x1100, 0x1161, 0x11A7
+	lCount, vCount, tCount     = 19, 21, 28
+	nCount                     = vCount * tCount
+	sCount                     = lCount * nCount
+)
+
+// decompose appends the full canonical decomposition of r to b.
+func decompose(b []rune, r rune) []rune {
+	if s := r - sBase; 0 <= s && s < sCount {
+		b = append(b, lBase+s/nCount, vBase+s%nCount/tCount)
+		if t := s % tCount; t != 0 {
+			b = append(b, tBase+t)
+		}
+		return b
+	}
+	d, ok := decompositions[r]
+	if !ok {
+		return append(b, r)
+	}
+	for _, r := range d {
+		b = decompose(b, r)
+	}
+	return b
+}
+
+// reorder puts each run of combining marks into canonical order: a stable
+// sort by combining class.
+func reorder(d []rune) {
+	for i := 0; i < len(d); {
+		if combiningClass(d[i]) == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < len(d) && combiningClass(d[j]) != 0 {
+			j++
+		}
+		run := d[i:j]
+		sort.SliceStable(run, func(x, y int) bool { return combiningClass(run[x]) < combiningClass(run[y]) })
+		i = j
+	}
+}
+
+// compose applies canonical composition to the decomposed, reordered d in place.
+func compose(d []rune) []rune {
+	if len(d) == 0 {
+		return d
+	}
+	out := d[:1]
+	starter := 0 // index in out of the last starter
+	lastClass := combiningClass(d[0])
+	if lastClass != 0 {
+		starter = -1
+	}
+	for _, r := range d[1:] {
+		class := combiningClass(r)
+		// r may join the starter unless a mark of the same or higher class
+		// lies between them (it is blocked).
+		blocked := len(out)-1 != starter && (lastClass == 0 || lastClass >= class)
+		if starter >= 0 && !blocked {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		lastClass = class
+		out = append(out, r)
+	}
+	return out
+}
+
+func composePair(a, b rune) (rune, bool) {
+	if l, v := a-lBase, b-vBase; 0 <= l && l < lCount && 0 <= v && v < vCount {
+		return sBase + (l*vCount+v)*tCount, true
+	}
+	if s, t := a-sBase, b-tBase; 0 <= s && s < sCount && s%tCount == 0 && 0 < t && t < tCount {
+		return a + t, true
+	}
+	c, ok := compositions[[2]rune{a, b}]
+	return c, ok
+}
+
+type classRange struct {
+	lo, hi rune
+	class  uint8
+}
+
+// combiningClass returns the canonical combining class of r.
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(combiningClasses), func(i int) bool { return combiningClasses[i].hi >= r })
+	if i < len(combiningClasses) && combiningClasses[i].lo <= r {
+		return combiningClasses[i].class
+	}
+	return 0
+}
+
+// clusterLen returns the length in bytes of the grapheme cluster at the
+// start of s. It implements the main rules of extended grapheme clusters
+// (UAX #29): CR LF, marks and joiners extend the preceding character,
+// Hangul jamo form syllables, emoji join across ZWJ and regional
+// indicators pair into flags. Prepend characters are not handled.
+func clusterLen(s string) int {
+	prev, n := utf8.DecodeRuneInString(s)
+	if prev == '\r' && len(s) > 1 && s[1] == '\n' {
+		return 2
+	}
+	if isControl(prev) {
+		return n
+	}
+	ri := 0 // regional indicators seen
+	if isRegional(prev) {
+		ri = 1
+	}
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case isControl(r):
+			return n
+		case isExtend(r):
+		case prev == '\u200d' && isPictographic(r):
+		case joinsHangul(prev, r):
+		case ri == 1 && isRegional(r):
+			ri = 2
+		default:
+			return n
+		}
+		prev = r
+		n += size
+	}
+	return n
+}
+
+func isControl(r rune) bool {
+	return r == '\r' || r == '\n' || unicode.IsControl(r) || unicode.In(r, unicode.Zl, unicode.Zp)
+}
+
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == '\u200d' || // zero width joiner
+		0x1F3FB <= r && r <= 0x1F3FF // emoji skin tone modifiers
+}
+
+func isPictographic(r rune) bool {
+	return unicode.Is(unicode.So, r) || 0x1F000 <= r && r <= 0x1FAFF
+}
+
+func isRegional(r rune) bool { return 0x1F1E6 <= r && r <= 0x1F1FF }
+
+// Hangul syllable types for the grapheme cluster rules.
+const (
+	hangulNone = iota
+	hangulL
+	hangulV
+	hangulT
+	hangulLV
+	hangulLVT
+)
+
+func hangulType(r rune) int {
+	switch {
+	case 0x1100 <= r && r <= 0x115F, 0xA960 <= r && r <= 0xA97C:
+		return hangulL
+	case 0x1160 <= r && r <= 0x11A7, 0xD7B0 <= r && r <= 0xD7C6:
+		return hangulV
+	case 0x11A8 <= r && r <= 0x11FF, 0xD7CB <= r && r <= 0xD7FB:
+		return hangulT
+	case sBase <= r && r < sBase+sCount:
+		if (r-sBase)%tCount == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+func joinsHangul(a, b rune) bool {
+	ta, tb := hangulType(a), hangulType(b)
+	switch ta {
+	case hangulL:
+		return tb == hangulL || tb == hangulV || tb == hangulLV || tb == hangulLVT
+	case hangulLV, hangulV:
+		return tb == hangulV || tb == hangulT
+	case hangulLVT, hangulT:
+		return tb == hangulT
+	}
+	return false
+}
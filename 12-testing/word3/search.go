@@ -0,0 +1,165 @@
+package word
+
+import "sort"
+
+// A Span is a palindrome found in a text.
+type Span struct {
+	Start, End int // byte offsets of the palindrome in the input
+	Len        int // length in elements after normalization
+}
+
+// Longest returns the longest palindrome in s after normalization with mode
+// (see IsPalindromeMode). It starts and ends on elements that take part in
+// the comparison, so ignored punctuation around it is not included. Among
+// palindromes of equal length the first one wins. If s has no letters,
+// Longest returns the zero Span.
+//
+// Longest uses Manacher's algorithm and runs in time linear in len(s).
+func Longest(s string, mode Mode) Span {
+	units := normalize(s, mode)
+	var best Span
+	for c, l := range manacher(units) {
+		if l > best.Len {
+			best = span(units, c, l)
+		}
+	}
+	return best
+}
+
+// MaximalPalindromes returns the maximal palindromes in s of at least k
+// elements, ordered by position. A palindrome is maximal if it cannot be
+// extended by one element on both sides; there is at most one around each
+// center.
+func MaximalPalindromes(s string, mode Mode, k int) []Span {
+	if k < 1 {
+		k = 1
+	}
+	units := normalize(s, mode)
+	var spans []Span
+	for c, l := range manacher(units) {
+		if l >= k {
+			spans = append(spans, span(units, c, l))
+		}
+	}
+	sortSpans(spans)
+	return spans
+}
+
+// Words returns the words of s that are palindromes of at least k elements.
+// A word is a run of compared elements with nothing ignored in between, so
+// "Bob's" is two words.
+func Words(s string, mode Mode, k int) []Span {
+	units := normalize(s, mode)
+	word := wordIndexes(units)
+	var spans []Span
+	for i := 0; i < len(units); {
+		j := i
+		for j < len(units) && word[j] == word[i] {
+			j++
+		}
+		if j-i >= k && isPalindrome(units[i:j]) {
+			spans = append(spans, Span{units[i].start, units[j-1].end, j - i})
+		}
+		i = j
+	}
+	return spans
+}
+
+// Phrases returns the palindromes of s of at least k elements that span two
+// or more words and start and end on word boundaries, like "Step on no pets".
+// Around each center only the longest such phrase is reported.
+//
+// Checking the boundaries takes time proportional to the length of the
+// maximal palindrome around each center, so Phrases is quadratic in the
+// worst case ("aaa...").
+func Phrases(s string, mode Mode, k int) []Span {
+	if k < 1 {
+		k = 1
+	}
+	units := normalize(s, mode)
+	word := wordIndexes(units)
+	var spans []Span
+	for c, max := range manacher(units) {
+		for l := max; l >= k; l -= 2 {
+			i, j := (c-l)/2, (c+l)/2-1 // first and last element
+			if word[i] == word[j] {
+				break // shorter ones are inside one word too
+			}
+			if (i == 0 || word[i-1] != word[i]) && (j == len(units)-1 || word[j+1] != word[j]) {
+				spans = append(spans, span(units, c, l))
+				break
+			}
+		}
+	}
+	sortSpans(spans)
+	return spans
+}
+
+// manacher returns the length of the longest palindrome around each of the
+// 2n+1 centers of units: center 2i+1 is element i and center 2i is the gap
+// before it. The palindrome around center c with length l covers elements
+// (c-l)/2 through (c+l)/2-1.
+func manacher(units []unit) []int {
+	m := 2*len(units) + 1
+	p := make([]int, m)
+	// match compares the odd centers a and b, which are elements; even
+	// centers are gaps and always match.
+	match := func(a, b int) bool {
+		return a%2 == 0 || units[a/2].text == units[b/2].text
+	}
+	c, r := 0, 0 // the palindrome reaching furthest right: center and right edge
+	for k := 0; k < m; k++ {
+		if k < r {
+			p[k] = p[2*c-k] // mirror image inside the palindrome around c
+			if p[k] > r-k {
+				p[k] = r - k
+			}
+		}
+		for k-p[k]-1 >= 0 && k+p[k]+1 < m && match(k-p[k]-1, k+p[k]+1) {
+			p[k]++
+		}
+		if k+p[k] > r {
+			c, r = k, k+p[k]
+		}
+	}
+	return p
+}
+
+// span returns the Span of the palindrome of length l around center c.
+func span(units []unit, c, l int) Span {
+	if l == 0 {
+		return Span{}
+	}
+	return Span{units[(c-l)/2].start, units[(c+l)/2-1].end, l}
+}
+
+// wordIndexes numbers the words of units: elements are in the same word if
+// nothing of the input lies between them.
+func wordIndexes(units []unit) []int {
+	word := make([]int, len(units))
+	for i := 1; i < len(units); i++ {
+		word[i] = word[i-1]
+		if units[i].start > units[i-1].end {
+			word[i]++
+		}
+	}
+	return word
+}
+
+func isPalindrome(units []unit) bool {
+	for i, n := 0, len(units); i < n/2; i++ {
+		if units[i].text != units[n-1-i].text {
+			return false
+		}
+	}
+	return true
+}
+
+func sortSpans(spans []Span) {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		return spans[i].Len > spans[j].Len
+	})
+}
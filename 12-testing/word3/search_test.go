@@ -0,0 +1,184 @@
+package word
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// longestNaive is the quadratic algorithm Longest replaces: expand around
+// each of the 2n+1 centers.
+func longestNaive(units []unit) (start, length int) {
+	n := len(units)
+	for c := 0; c <= 2*n; c++ {
+		i, j := c/2-1, c/2 // gap before element c/2
+		if c%2 == 1 {
+			i, j = c/2-1, c/2+1 // element c/2
+		}
+		for i >= 0 && j < n && units[i].text == units[j].text {
+			i--
+			j++
+		}
+		if l := j - i - 1; l > length {
+			start, length = i+1, l
+		}
+	}
+	return start, length
+}
+
+// text returns the part of s that sp covers.
+func text(s string, sp Span) string { return s[sp.Start:sp.End] }
+
+func TestLongest(t *testing.T) {
+	var tests = []struct {
+		input string
+		mode  Mode
+		want  string
+	}{
+		{"", Default, ""},
+		{"!?", Default, ""},
+		{"x", Default, "x"},
+		{"abc", Default, "a"},
+		{"abba", Default, "abba"},
+		{"xyz racecar!", Default, "racecar"},
+		{"He said: A man, a plan, a canal: Panama!", Default, "A man, a plan, a canal: Panama"},
+		{"Zum: été — été", Default, "été — été"},
+		{"ab été", NFC | Graphemes, "été"},
+		{"ab été ba", NFC | Graphemes, "ab été ba"},
+		{"xyyx\u00e9yy\u00e9", NFC, "xyyx"}, // ties: the first wins
+		{"Straße ssar", FoldCase, "raße ssar"},
+		{"1221 abc", Default, "a"},
+		{"1221 abc", Digits, "1221"},
+	}
+	for _, test := range tests {
+		sp := Longest(test.input, test.mode)
+		if got := text(test.input, sp); got != test.want {
+			t.Errorf("Longest(%+q, %v) = %+q, want %+q", test.input, test.mode, got, test.want)
+		}
+	}
+}
+
+// TestRandomLongest compares Longest with the naive algorithm on random
+// strings over a small alphabet, which are full of palindromes.
+func TestRandomLongest(t *testing.T) {
+	seed := time.Now().UTC().UnixNano()
+	t.Logf("Random seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < 1000; i++ {
+		b := make([]byte, rng.Intn(40))
+		for j := range b {
+			b[j] = "abAB ,"[rng.Intn(6)]
+		}
+		s := string(b)
+		units := normalize(s, Default)
+		start, length := longestNaive(units)
+		want := Span{}
+		if length > 0 {
+			want = Span{units[start].start, units[start+length-1].end, length}
+		}
+		if got := Longest(s, Default); got != want {
+			t.Errorf("Longest(%q) = %v (%q), want %v (%q)", s, got, text(s, got), want, text(s, want))
+		}
+		for _, sp := range MaximalPalindromes(s, Default, 1) {
+			if !IsPalindrome(text(s, sp)) {
+				t.Errorf("MaximalPalindromes(%q) returned %q", s, text(s, sp))
+			}
+		}
+	}
+}
+
+func spansText(s string, spans []Span) string {
+	var texts []string
+	for _, sp := range spans {
+		texts = append(texts, fmt.Sprintf("%d:%s", sp.Len, text(s, sp)))
+	}
+	return strings.Join(texts, "|")
+}
+
+func TestMaximalPalindromes(t *testing.T) {
+	var tests = []struct {
+		input string
+		k     int
+		want  string
+	}{
+		{"", 1, ""},
+		{"abc", 1, "1:a|1:b|1:c"},
+		{"abc", 2, ""},
+		{"abacaba", 3, "7:abacaba|3:aba|3:aba"},
+		{"aaaa", 3, "4:aaaa|3:aaa|3:aaa"},
+		{"x-y-x! y", 3, "3:x-y-x|3:y-x! y"},
+	}
+	for _, test := range tests {
+		got := spansText(test.input, MaximalPalindromes(test.input, Default, test.k))
+		if got != test.want {
+			t.Errorf("MaximalPalindromes(%q, %d) = %q, want %q", test.input, test.k, got, test.want)
+		}
+	}
+}
+
+func TestWordsAndPhrases(t *testing.T) {
+	var tests = []struct {
+		input   string
+		k       int
+		words   string
+		phrases string
+	}{
+		{"Step on no pets, Anna!", 3, "4:Anna", "12:Step on no pets"},
+		{"a level a", 3, "5:level", "7:a level a"},
+		{"Bob's kayak", 3, "3:Bob|5:kayak", ""},
+		{"no on", 3, "", "4:no on"},
+		{"ab ba", 5, "", ""},
+		{"xno ony", 3, "", ""}, // not on word boundaries
+		{"Was it a car or a cat I saw?", 5, "", "19:Was it a car or a cat I saw"},
+		{"Ésope reste ici et se repose", 5, "", ""}, // É is not e
+		{"été ressasser", 3, "3:été|9:ressasser", ""},
+	}
+	for _, test := range tests {
+		if got := spansText(test.input, Words(test.input, Default, test.k)); got != test.words {
+			t.Errorf("Words(%q, %d) = %q, want %q", test.input, test.k, got, test.words)
+		}
+		if got := spansText(test.input, Phrases(test.input, Default, test.k)); got != test.phrases {
+			t.Errorf("Phrases(%q, %d) = %q, want %q", test.input, test.k, got, test.phrases)
+		}
+	}
+}
+
+// benchText returns n random letters from an alphabet of the given size.
+func benchText(n, alphabet int) string {
+	rng := rand.New(rand.NewSource(1))
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = 'a' + byte(rng.Intn(alphabet))
+	}
+	return string(b)
+}
+
+// BenchmarkLongest compares Manacher's algorithm with the naive expansion.
+// On random text palindromes are short and the naive expansion stops after a
+// step or two at each center, so it is no slower; on repetitive text it
+// takes quadratic time.
+func BenchmarkLongest(b *testing.B) {
+	for _, in := range []struct {
+		name string
+		text string
+	}{
+		{"random-1k", benchText(1000, 26)},
+		{"random-10k", benchText(10000, 26)},
+		{"binary-10k", benchText(10000, 2)},
+		{"same-10k", strings.Repeat("a", 10000)}, // the naive worst case
+	} {
+		units := normalize(in.text, Default)
+		b.Run("manacher/"+in.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				manacher(units)
+			}
+		})
+		b.Run("naive/"+in.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				longestNaive(units)
+			}
+		})
+	}
+}
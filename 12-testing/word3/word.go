@@ -4,20 +4,12 @@ package word
 import "unicode"
 
 // IsPalindrome reports whether s reads the same forward and backward.
-// Letter case is ignored, as are non-letters.
+// Letter case and non-letters are ignored. Text is compared in NFC, so
+// precomposed and decomposed accents give the same answer, but an accented
+// letter is not the same as the plain one (see Default). See
+// IsPalindromeMode for other ways of comparing.
 func IsPalindrome(s string) bool {
-	var letters []rune
-	for _, r := range s {
-		if unicode.IsLetter(r) {
-			letters = append(letters, unicode.ToLower(r))
-		}
-	}
-	for i := range letters {
-		if letters[i] != letters[len(letters)-1-i] {
-			return false
-		}
-	}
-	return true
+	return IsPalindromeMode(s, Default)
 }
 
 func IsPalindrome2(s string) bool {
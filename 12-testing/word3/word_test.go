@@ -1,8 +1,11 @@
 package word
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
+	"unicode"
 )
 
 import "math/rand"
@@ -26,12 +29,248 @@ func TestRandomPalindromes(t *testing.T) {
 	rng := rand.New(rand.NewSource(seed))
 	for i := 0; i < 1000; i++ {
 		p := randomPalindrome(rng)
+		// The zero Mode compares rune by rune. IsPalindrome compares in NFC,
+		// see TestRandomUnmarkedPalindromes.
+		if !IsPalindromeMode(p, 0) {
+			t.Errorf("IsPalindromeMode(%q, 0) = false", p)
+		}
+	}
+}
+
+// TestRandomUnmarkedPalindromes checks IsPalindrome on random palindromes
+// without marks. In NFC a mark composes with the letter before it, which
+// differs on the two sides of the palindrome.
+func TestRandomUnmarkedPalindromes(t *testing.T) {
+	seed := time.Now().UTC().UnixNano()
+	t.Logf("Random seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	dropMarks := func(r rune) rune {
+		if unicode.IsMark(r) {
+			return -1
+		}
+		return r
+	}
+	for i := 0; i < 1000; i++ {
+		p := strings.Map(dropMarks, randomPalindrome(rng))
 		if !IsPalindrome(p) {
 			t.Errorf("IsPalindrome(%q) = false", p)
 		}
 	}
 }
 
+// TestUnicodeVersion checks that the tables come from the Unicode version
+// of package unicode, or IsLetter and IsMark disagree with them about the
+// characters added in between.
+func TestUnicodeVersion(t *testing.T) {
+	if UnicodeVersion != unicode.Version {
+		t.Errorf("tables are from Unicode %s, package unicode from %s; regenerate them with gen.go", UnicodeVersion, unicode.Version)
+	}
+}
+
+// A cluster is one user-perceived character in two canonically equivalent
+// spellings.
+type cluster struct{ composed, decomposed string }
+
+var clusters = []cluster{
+	{"a", "a"},
+	{"K", "K"},
+	{"\u00e9", "e\u0301"},            // é
+	{"\u00c5", "A\u030a"},            // Å
+	{"\u00f1", "n\u0303"},            // ñ
+	{"\u01d6", "u\u0308\u0304"},      // ǖ
+	{"\u1ec7", "e\u0302\u0323"},      // ệ, marks out of canonical order
+	{"q\u0301", "q\u0301"},           // no precomposed form
+	{"\ud55c", "\u1112\u1161\u11ab"}, // 한
+	{"\u03ce", "\u03c9\u0301"},       // ώ
+	{"\u0431", "\u0431"},             // б
+}
+
+// randomGraphemePalindrome returns a palindrome of user-perceived characters
+// in which each character is independently spelled composed or decomposed,
+// upper or lower case, and separated by random punctuation.
+func randomGraphemePalindrome(rng *rand.Rand) string {
+	n := rng.Intn(12)
+	picks := make([]cluster, n)
+	for i := 0; i < (n+1)/2; i++ {
+		c := clusters[rng.Intn(len(clusters))]
+		picks[i], picks[n-1-i] = c, c
+	}
+	var b strings.Builder
+	for _, c := range picks {
+		s := c.composed
+		if rng.Intn(2) == 0 {
+			s = c.decomposed
+		}
+		if rng.Intn(2) == 0 {
+			s = strings.ToUpper(s)
+		}
+		b.WriteString(s)
+		if rng.Intn(3) == 0 {
+			b.WriteByte(",.;:' "[rng.Intn(6)])
+		}
+	}
+	return b.String()
+}
+
+func TestRandomGraphemePalindromes(t *testing.T) {
+	seed := time.Now().UTC().UnixNano()
+	t.Logf("Random seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < 1000; i++ {
+		p := randomGraphemePalindrome(rng)
+		for _, mode := range []Mode{Default, NFC, NFC | Graphemes, NFD | Graphemes, StripMarks | Graphemes, FoldCase | Graphemes} {
+			if !IsPalindromeMode(p, mode) {
+				t.Errorf("IsPalindromeMode(%+q, %v) = false", p, mode)
+			}
+		}
+	}
+}
+
+// TestRandomMarkMismatches changes the marks on one character of a
+// grapheme palindrome: it stops being one unless marks are stripped.
+// IsPalindrome tells the characters apart when the marked letter has a
+// precomposed form; otherwise the mark is not a letter and is skipped.
+func TestRandomMarkMismatches(t *testing.T) {
+	seed := time.Now().UTC().UnixNano()
+	t.Logf("Random seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	marks := []rune{'\u0300', '\u0301', '\u0308', '\u0323', '\u0327'}
+	for i := 0; i < 1000; i++ {
+		n := 2 + rng.Intn(10)
+		letters := make([]rune, n)
+		for j := 0; j < (n+1)/2; j++ {
+			r := 'a' + rune(rng.Intn(26))
+			letters[j], letters[n-1-j] = r, r
+		}
+		j := rng.Intn(n / 2) // has a distinct mirror image
+		m := marks[rng.Intn(len(marks))]
+		var b strings.Builder
+		for k, r := range letters {
+			b.WriteRune(r)
+			if k == j {
+				b.WriteRune(m)
+			}
+		}
+		s := b.String()
+		if rng.Intn(2) == 0 {
+			s = strings.Join(Normalize(s, NFC|Graphemes), "")
+		}
+		if IsPalindromeMode(s, NFC|Graphemes) || IsPalindromeMode(s, NFD|Graphemes) {
+			t.Errorf("%+q is a grapheme palindrome", s)
+		}
+		if !IsPalindromeMode(s, StripMarks|Graphemes) {
+			t.Errorf("%+q is not a palindrome without marks", s)
+		}
+		precomposed := len([]rune(Normalize(string([]rune{letters[j], m}), NFC|Graphemes)[0])) == 1
+		if IsPalindrome(s) == precomposed {
+			t.Errorf("IsPalindrome(%+q) = %v", s, !precomposed)
+		}
+	}
+}
+
+func TestIsPalindromeMode(t *testing.T) {
+	var tests = []struct {
+		input string
+		mode  Mode
+		want  bool
+	}{
+		{"\u00e9xe\u0301", 0, false}, // the old behavior
+		{"\u00e9xe\u0301", Default, true},
+		{"\u00e9xe\u0301", NFC, true},
+		{"\u00e9xe", NFC, false},
+		{"\u00e9xe", Default, false}, // accented letters stay distinct
+		{"\u00e9xe", StripMarks, true},
+		{"\u00e9te", Default, false},
+		{"e\u0301te\u0301", Default, true},
+		{"\u00e9xe", NFC | Graphemes, false},
+		{"\u00e9xe", StripMarks | Graphemes, true},
+		{"q\u0301xq", NFC, true}, // marks are not letters
+		{"q\u0301xq", NFC | Graphemes, false},
+		{"q\u0301xq\u0301", NFC | Graphemes, true},
+		{"\u1ec7x\u0065\u0302\u0323", NFC | Graphemes, true},
+		{"\ud55cx\u1112\u1161\u11ab", NFC | Graphemes, true},
+		{"\ud55cx\u1112\u1161\u11ab", NFD, false}, // jamo reversed
+		{"\ud55cx\u1112\u1161\u11ab", NFD | Graphemes, true},
+		{"Ma\u00df, ssam", Default, false},
+		{"Ma\u00df, ssam", FoldCase, true},
+		{"\ufb01f", Default, false}, // ﬁ is one letter
+		{"\ufb01f", FoldCase, true}, // unless folded to "fi"
+		{"f\ufb01", FoldCase, false},
+		{"\ufb01if", FoldCase, true},
+		{"\u0131xI", FoldCase, false}, // dotless i is not I
+		{"\u03a3x\u03c2", FoldCase, true},
+		{"\u212a\u006b", FoldCase, true}, // Kelvin sign
+		{"1a2", Default, true},
+		{"1a2", Digits, false},
+		{"2a1a2", Digits, true},
+		{"\U0001f44d\U0001f3fda\U0001f44d", Graphemes, true}, // emoji are not letters
+	}
+	for _, test := range tests {
+		if got := IsPalindromeMode(test.input, test.mode); got != test.want {
+			t.Errorf("IsPalindromeMode(%+q, %v) = %v", test.input, test.mode, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	var tests = []struct {
+		input string
+		mode  Mode
+		want  []string
+	}{
+		{"\u00c7a!", 0, []string{"\u00e7", "a"}},
+		{"\u00c7a!", NFD, []string{"c", "a"}},
+		{"\u00c7a!", NFD | Graphemes, []string{"c\u0327", "a"}},
+		{"C\u0327a", NFC | Graphemes, []string{"\u00e7", "a"}},
+		{"\u00c7a", StripMarks, []string{"c", "a"}},
+		{"\ufb03", FoldCase, []string{"f", "f", "i"}},
+		{"\ufb03", FoldCase | Graphemes, []string{"ffi"}},
+		{"\u1f88", FoldCase | Graphemes, []string{"\u1f00\u03b9"}}, // ᾈ
+		{"\u0130", FoldCase | NFD | Graphemes, []string{"i\u0307"}},
+		{"\u1112\u1161\u11ab", Graphemes, []string{"\u1112\u1161\u11ab"}},
+		{"\u1112\u1161\u11ab", NFC | Graphemes, []string{"\ud55c"}},
+		{"\U0001f1eb\U0001f1f7\U0001f1e9e", Graphemes, []string{"e"}}, // a flag, then a lone indicator
+		{"a\r\nb", Graphemes, []string{"a", "b"}},
+		{"x\u0301\u0301\u200d", Graphemes, []string{"x\u0301\u0301\u200d"}},
+	}
+	for _, test := range tests {
+		got := Normalize(test.input, test.mode)
+		if fmt.Sprintf("%+q", got) != fmt.Sprintf("%+q", test.want) {
+			t.Errorf("Normalize(%+q, %v) = %+q, want %+q", test.input, test.mode, got, test.want)
+		}
+	}
+}
+
+// TestClusterLen checks that clusters tile the input.
+func TestClusterLen(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		s := randomGraphemePalindrome(rng) + string(rune(rng.Intn(0x3000)))
+		n := 0
+		for n < len(s) {
+			l := clusterLen(s[n:])
+			if l <= 0 {
+				t.Fatalf("clusterLen(%+q) = %d", s[n:], l)
+			}
+			if unicode.IsMark([]rune(s[n:])[0]) && n > 0 {
+				t.Errorf("%+q: cluster at %d starts with a mark", s, n)
+			}
+			n += l
+		}
+	}
+}
+
+func BenchmarkIsPalindromeMode(b *testing.B) {
+	const s = "\u00c9sope reste ici et se repose"
+	for _, mode := range []Mode{0, Default, NFC | Graphemes, FoldCase | Graphemes} {
+		b.Run(mode.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				IsPalindromeMode(s, mode)
+			}
+		})
+	}
+}
+
 func BenchmarkIsPalindrome(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		IsPalindrome("A man, a plan, a canal: Panama")
@@ -5,6 +5,7 @@ import (
 	"time"
 )
 
+// 调度的统计（P的利用率、调度延迟、GC停顿）见gmptrace目录，不同GOMAXPROCS下的加速比见scaling目录。
 func main() {
 	var wg sync.WaitGroup
 	for i := 0; i < 2000; i++ {
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// goroutine的状态，与go tool trace -d=parsed输出中的名字相同。
+const (
+	stateRunning  = "Running"
+	stateRunnable = "Runnable"
+	stateWaiting  = "Waiting"
+	stateSyscall  = "Syscall"
+	stateNotExist = "NotExist"
+)
+
+// Summary 是对一个trace的统计。
+type Summary struct {
+	Duration   time.Duration // 第一个事件到最后一个事件的时间
+	Procs      []ProcStats   // 下标是P的ID
+	Goroutines int           // trace中出现过的goroutine数量
+	Created    int           // trace期间创建的goroutine数量
+
+	// StateTime 是所有goroutine处于各个状态的时间之和。
+	StateTime map[string]time.Duration
+	// SchedLatency 是goroutine从变成可运行到开始运行的等待时间（调度延迟）。
+	SchedLatency Distribution
+	// Samples 是均匀分布在trace期间的各个时刻，处于各个状态的goroutine数量。
+	Samples []Sample
+
+	GCCycles int
+	// Pauses 是stop-the-world的停顿，按原因分组，按总时间从大到小排列。
+	Pauses []PauseStats
+}
+
+// ProcStats 是一个P的统计。
+type ProcStats struct {
+	Busy time.Duration // 有goroutine在这个P上运行的时间
+	Runs int           // goroutine在这个P上开始运行的次数
+}
+
+// Sample 是某一时刻处于各个状态的goroutine数量。
+type Sample struct {
+	At                         time.Duration // 相对于trace开始的时间
+	Running, Runnable, Waiting int
+	Syscall                    int
+}
+
+// PauseStats 是一种stop-the-world停顿的统计。
+type PauseStats struct {
+	Reason string
+	Distribution
+}
+
+// Distribution 是一组时间的分布。
+type Distribution struct {
+	Count      int
+	Total, Max time.Duration
+	P50, P99   time.Duration
+}
+
+// Mean 返回平均值。
+func (d Distribution) Mean() time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Total / time.Duration(d.Count)
+}
+
+func newDistribution(ds []time.Duration) Distribution {
+	if len(ds) == 0 {
+		return Distribution{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	d := Distribution{Count: len(ds), Max: ds[len(ds)-1]}
+	for _, x := range ds {
+		d.Total += x
+	}
+	quantile := func(p float64) time.Duration { return ds[int(p*float64(len(ds)-1))] }
+	d.P50, d.P99 = quantile(0.5), quantile(0.99)
+	return d
+}
+
+// analyzer 按时间顺序接收事件，维护每个goroutine的状态。
+type analyzer struct {
+	start, end int64
+	gs         map[int64]*gstate
+	created    int
+	procs      []ProcStats
+	stateTime  map[string]time.Duration
+	latencies  []time.Duration
+
+	counts   Sample   // 当前各个状态的goroutine数量，At是最近一次变化的时间
+	timeline []Sample // counts的每一次变化，用于最后计算Samples
+
+	gcCycles int
+	ranges   map[string]int64 // 进行中的stop-the-world，键是名字和作用域
+	pauses   map[string][]time.Duration
+}
+
+type gstate struct {
+	state string
+	since int64 // 进入state的时间，0表示未知（第一次出现之前的状态）
+	p     int   // 正在运行时所在的P
+}
+
+func newAnalyzer() *analyzer {
+	return &analyzer{
+		gs:        make(map[int64]*gstate),
+		stateTime: make(map[string]time.Duration),
+		ranges:    make(map[string]int64),
+		pauses:    make(map[string][]time.Duration),
+	}
+}
+
+func (a *analyzer) event(ev event) {
+	if ev.time == 0 {
+		return
+	}
+	if a.start == 0 {
+		a.start = ev.time
+	}
+	a.end = ev.time
+	switch ev.kind {
+	case "StateTransition":
+		if ev.goID >= 0 {
+			a.transition(ev)
+		}
+	case "RangeBegin":
+		if ev.name == "GC concurrent mark phase" {
+			a.gcCycles++
+		}
+		if strings.HasPrefix(ev.name, "stop-the-world") {
+			a.ranges[ev.name+"/"+ev.scope] = ev.time
+		}
+	case "RangeEnd":
+		key := ev.name + "/" + ev.scope
+		if begin, ok := a.ranges[key]; ok {
+			delete(a.ranges, key)
+			reason := strings.TrimSuffix(strings.TrimPrefix(ev.name, "stop-the-world ("), ")")
+			a.pauses[reason] = append(a.pauses[reason], time.Duration(ev.time-begin))
+		}
+	}
+}
+
+func (a *analyzer) transition(ev event) {
+	g := a.gs[ev.goID]
+	if g == nil {
+		g = &gstate{state: ev.from, p: -1}
+		a.gs[ev.goID] = g
+		if ev.from == stateNotExist {
+			a.created++
+		}
+	}
+	if g.since != 0 {
+		d := time.Duration(ev.time - g.since)
+		a.stateTime[g.state] += d
+		if g.state == stateRunnable && ev.to == stateRunning {
+			a.latencies = append(a.latencies, d)
+		}
+		if g.state == stateRunning && g.p >= 0 {
+			a.proc(g.p).Busy += d
+		}
+	}
+	a.count(g.state, -1)
+	g.state, g.since, g.p = ev.to, ev.time, -1
+	if ev.to == stateRunning {
+		g.p = ev.p
+		if ev.p >= 0 {
+			a.proc(ev.p).Runs++
+		}
+	}
+	a.count(g.state, +1)
+	a.counts.At = time.Duration(ev.time - a.start)
+	a.timeline = append(a.timeline, a.counts)
+}
+
+func (a *analyzer) proc(id int) *ProcStats {
+	for id >= len(a.procs) {
+		a.procs = append(a.procs, ProcStats{})
+	}
+	return &a.procs[id]
+}
+
+func (a *analyzer) count(state string, delta int) {
+	switch state {
+	case stateRunning:
+		a.counts.Running += delta
+	case stateRunnable:
+		a.counts.Runnable += delta
+	case stateWaiting:
+		a.counts.Waiting += delta
+	case stateSyscall:
+		a.counts.Syscall += delta
+	}
+}
+
+// summary 结束所有进行中的状态，返回统计结果。samples是Samples的个数。
+func (a *analyzer) summary(samples int) *Summary {
+	for _, g := range a.gs {
+		if g.since == 0 {
+			continue
+		}
+		d := time.Duration(a.end - g.since)
+		a.stateTime[g.state] += d
+		if g.state == stateRunning && g.p >= 0 {
+			a.proc(g.p).Busy += d
+		}
+	}
+	delete(a.stateTime, stateNotExist)
+
+	s := &Summary{
+		Duration:     time.Duration(a.end - a.start),
+		Procs:        a.procs,
+		Goroutines:   len(a.gs),
+		Created:      a.created,
+		StateTime:    a.stateTime,
+		SchedLatency: newDistribution(a.latencies),
+		GCCycles:     a.gcCycles,
+	}
+
+	// 在timeline中找到每个采样时刻之前的最后一次变化。
+	j := 0
+	var cur Sample
+	for i := 1; i <= samples; i++ {
+		at := s.Duration * time.Duration(i) / time.Duration(samples)
+		for j < len(a.timeline) && a.timeline[j].At <= at {
+			cur = a.timeline[j]
+			j++
+		}
+		cur.At = at
+		s.Samples = append(s.Samples, cur)
+	}
+
+	for reason, ds := range a.pauses {
+		s.Pauses = append(s.Pauses, PauseStats{reason, newDistribution(ds)})
+	}
+	sort.Slice(s.Pauses, func(i, j int) bool { return s.Pauses[i].Total > s.Pauses[j].Total })
+	return s
+}
@@ -0,0 +1,130 @@
+// Gmptrace 在runtime/trace下运行一个负载，然后把trace整理成文本的摘要。
+//
+// trace.go只是把trace写到trace.out，gmp.go启动了2000个空转的goroutine，
+// 但要看懂调度器做了什么，只能打开交互式的go tool trace。
+// gmptrace用go tool trace -d=parsed把trace解析成事件，统计并打印：
+//   - 每个P的利用率：有goroutine在P上运行的时间占trace时长的比例；
+//   - 均匀分布在trace期间的各个时刻，运行、可运行、等待和系统调用中的goroutine数量；
+//   - 所有goroutine处于各个状态的总时间，以及从可运行到开始运行的等待时间（调度延迟）；
+//   - GC的次数和stop-the-world停顿。
+//
+// 用法：
+//
+//	$ gmptrace [-w gmp] [-n 200] [-interval 1ms] [-procs 0] [-o trace.out]
+//	$ gmptrace -i trace.out        # 分析已有的trace，比如trace.go写的trace.out
+//
+// -d=parsed是go tool trace的调试输出，格式可能随Go的版本变化。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gmptrace", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	name := flags.String("w", "gmp", "workload: "+strings.Join(workloadNames(), ", "))
+	n := flags.Int("n", 200, "number of goroutines")
+	interval := flags.Duration("interval", time.Millisecond, "interval between goroutine starts (gmp workload)")
+	procs := flags.Int("procs", 0, "GOMAXPROCS for the workload (0: unchanged)")
+	output := flags.String("o", "", "keep the trace in this file")
+	input := flags.String("i", "", "analyze this trace instead of running a workload")
+	samples := flags.Int("samples", 10, "number of goroutine count samples")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 || *samples <= 0 {
+		flags.Usage()
+		return 2
+	}
+
+	file := *input
+	if file == "" {
+		w, ok := workloads[*name]
+		if !ok {
+			fmt.Fprintf(stderr, "gmptrace: unknown workload %q\n", *name)
+			return 2
+		}
+		var err error
+		file, err = record(*output, func() {
+			if *procs > 0 {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(*procs))
+			}
+			w.run(*n, *interval)
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "gmptrace: %v\n", err)
+			return 1
+		}
+		if *output == "" {
+			defer os.Remove(file)
+		}
+	}
+
+	s, err := analyze(file, *samples)
+	if err != nil {
+		fmt.Fprintf(stderr, "gmptrace: %v\n", err)
+		return 1
+	}
+	printSummary(stdout, s)
+	return 0
+}
+
+// record 在trace下运行f，把trace写到file中；file为空时写到一个临时文件中。返回文件名。
+func record(file string, f func()) (string, error) {
+	var out *os.File
+	var err error
+	if file == "" {
+		out, err = os.CreateTemp("", "gmptrace-*.out")
+	} else {
+		out, err = os.Create(file)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := trace.Start(out); err != nil {
+		out.Close()
+		return "", err
+	}
+	f()
+	trace.Stop()
+	return out.Name(), out.Close()
+}
+
+// analyze 用go tool trace -d=parsed解析file并统计。
+func analyze(file string, samples int) (*Summary, error) {
+	cmd := exec.Command("go", "tool", "trace", "-d=parsed", file)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	a := newAnalyzer()
+	parseErr := parseEvents(stdout, a.event)
+	if parseErr != nil {
+		io.Copy(io.Discard, stdout) // 让go tool trace能够退出
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("go tool trace: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return a.summary(samples), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEvent(t *testing.T) {
+	for _, c := range []struct {
+		line string
+		want event
+	}{
+		{
+			`M=1 P=0 G=1 StateTransition Time=2000 GoID=2 NotExist->Runnable Reason=""`,
+			event{kind: "StateTransition", time: 2000, p: 0, goID: 2, procID: -1, from: "NotExist", to: "Runnable"},
+		},
+		{
+			`M=1 P=-1 G=-1 StateTransition Time=1000 ProcID=3 Idle->Running Reason=""`,
+			event{kind: "StateTransition", time: 1000, p: -1, goID: -1, procID: 3, from: "Idle", to: "Running"},
+		},
+		{
+			`M=1 P=0 G=3 RangeEnd Time=8000 Name="GC concurrent mark phase" Scope=None Attributes=["a b"=Value{Uint64(1)}]`,
+			event{kind: "RangeEnd", time: 8000, p: 0, goID: -1, procID: -1, name: "GC concurrent mark phase", scope: "None"},
+		},
+		{
+			`M=1 P=0 G=1 Metric Time=1000 Name="/sched/gomaxprocs:threads" Value=Value{Uint64(2)}`,
+			event{kind: "Metric", time: 1000, p: 0, goID: -1, procID: -1, name: "/sched/gomaxprocs:threads"},
+		},
+	} {
+		got, err := parseEvent(c.line)
+		if err != nil || got != c.want {
+			t.Errorf("parseEvent(%q)\n got %+v, %v\nwant %+v", c.line, got, err, c.want)
+		}
+	}
+	for _, line := range []string{`M=1 P=x Sync Time=1`, `M=1 P=0 Time=1`, `M=1 Range Name="unterminated`} {
+		if _, err := parseEvent(line); err == nil {
+			t.Errorf("parseEvent(%q) succeeded", line)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	f, err := os.Open("testdata/parsed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a := newAnalyzer()
+	if err := parseEvents(f, a.event); err != nil {
+		t.Fatal(err)
+	}
+	s := a.summary(2)
+	us := time.Microsecond
+
+	want := &Summary{
+		Duration:   10 * us,
+		Procs:      []ProcStats{{Busy: 8 * us, Runs: 3}, {Busy: 4 * us, Runs: 1}},
+		Goroutines: 3,
+		Created:    2,
+		StateTime: map[string]time.Duration{
+			stateRunning: 12 * us, stateRunnable: 6 * us, stateWaiting: 5 * us,
+		},
+		SchedLatency: Distribution{Count: 3, Total: 6 * us, Max: 4 * us, P50: us, P99: us},
+		Samples: []Sample{
+			{At: 5 * us, Running: 2, Waiting: 1}, // 相对于trace开始的时间，即Time=6000
+			{At: 10 * us, Running: 1},
+		},
+		GCCycles: 1,
+		Pauses: []PauseStats{
+			{"GC mark termination", Distribution{Count: 1, Total: us, Max: us, P50: us, P99: us}},
+			{"GC sweep termination", Distribution{Count: 1, Total: us / 2, Max: us / 2, P50: us / 2, P99: us / 2}},
+		},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("summary\n got %+v\nwant %+v", s, want)
+	}
+
+	var out bytes.Buffer
+	printSummary(&out, s)
+	for _, substr := range []string{
+		"trace: 10µs, 3 goroutines (2 created), 2 Ps",
+		"runnable   6µs  26.1%",
+		"n=3 mean=2µs p50=1µs p99=1µs max=4µs",
+		"GC: 1 cycles",
+	} {
+		if !strings.Contains(out.String(), substr) {
+			t.Errorf("output does not contain %q:\n%s", substr, out.String())
+		}
+	}
+}
+
+// TestRun 运行一个真实的负载并用go tool trace解析。
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go tool trace")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-w", "burst", "-n", "8", "-procs", "2"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, substr := range []string{"P utilization:", "goroutines over time:", "scheduler latency", "GC:"} {
+		if !strings.Contains(out, substr) {
+			t.Errorf("output does not contain %q:\n%s", substr, out)
+		}
+	}
+
+	if code := run([]string{"-w", "nope"}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown workload: exit %d", code)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// event 是go tool trace -d=parsed输出的一个事件，只保留分析需要的字段。
+//
+// 每个事件占一行，形如
+//
+//	M=13186 P=0 G=1 StateTransition Time=2124865252736 GoID=6 NotExist->Runnable Reason=""
+//	M=13186 P=0 G=29 RangeBegin Time=2124871479936 Name="stop-the-world (GC mark termination)" Scope=Goroutine(29)
+//
+// 后面可能跟着缩进的调用栈，调用栈和空行都被忽略。
+type event struct {
+	kind     string // StateTransition、RangeBegin、RangeEnd、Metric……
+	time     int64  // 纳秒
+	p        int    // 事件发生时M持有的P，-1表示没有
+	goID     int64  // goroutine的状态转换中是goroutine的ID，否则是-1
+	procID   int    // P的状态转换中是P的ID，否则是-1
+	from, to string // 状态转换前后的状态
+	name     string // Range的名字
+	scope    string // Range的作用域，比如Goroutine(29)、Proc(0)、None
+}
+
+// parseEvents 从r中读取go tool trace -d=parsed的输出，对每个事件调用f。
+func parseEvents(r io.Reader, f func(event)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		line := sc.Text()
+		if !strings.HasPrefix(line, "M=") {
+			continue // 调用栈、空行
+		}
+		ev, err := parseEvent(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineno, err)
+		}
+		f(ev)
+	}
+	return sc.Err()
+}
+
+// parseEvent 解析一行事件。
+func parseEvent(line string) (event, error) {
+	ev := event{p: -1, goID: -1, procID: -1}
+	rest := line
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			break
+		}
+		i := strings.IndexAny(rest, "= ")
+		if i < 0 || rest[i] == ' ' {
+			// 没有值的字段：第一个是事件的类型，"A->B"是状态转换。
+			tok := rest
+			if i >= 0 {
+				tok, rest = rest[:i], rest[i:]
+			} else {
+				rest = ""
+			}
+			if from, to, ok := strings.Cut(tok, "->"); ok {
+				ev.from, ev.to = from, to
+			} else if ev.kind == "" {
+				ev.kind = tok
+			}
+			continue
+		}
+		key := rest[:i]
+		rest = rest[i+1:]
+		if key == "Attributes" || key == "Value" || key == "Args" {
+			break // 这些字段的值可能包含空格，而且总在行尾，不需要解析
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			q, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return ev, fmt.Errorf("bad quoted value for %s: %v", key, err)
+			}
+			rest = rest[len(q):]
+			value, _ = strconv.Unquote(q)
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+
+		var err error
+		switch key {
+		case "Time":
+			ev.time, err = strconv.ParseInt(value, 10, 64)
+		case "P":
+			ev.p, err = strconv.Atoi(value)
+		case "GoID":
+			ev.goID, err = strconv.ParseInt(value, 10, 64)
+		case "ProcID":
+			ev.procID, err = strconv.Atoi(value)
+		case "Name":
+			ev.name = value
+		case "Scope":
+			ev.scope = value
+		}
+		if err != nil {
+			return ev, fmt.Errorf("bad %s: %v", key, err)
+		}
+	}
+	if ev.kind == "" {
+		return ev, fmt.Errorf("no event kind in %q", line)
+	}
+	return ev, nil
+}
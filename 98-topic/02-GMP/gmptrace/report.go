@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printSummary 把s打印成几张文本的表格。
+func printSummary(w io.Writer, s *Summary) {
+	fmt.Fprintf(w, "trace: %v, %d goroutines (%d created), %d Ps\n",
+		round(s.Duration), s.Goroutines, s.Created, len(s.Procs))
+
+	fmt.Fprintln(w, "\nP utilization:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "P\tbusy\tutil\truns\t")
+	for id, p := range s.Procs {
+		fmt.Fprintf(tw, "%d\t%v\t%s\t%d\t\n", id, round(p.Busy), percent(p.Busy, s.Duration), p.Runs)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\ngoroutines over time:")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "time\trunning\trunnable\twaiting\tsyscall\t")
+	for _, x := range s.Samples {
+		fmt.Fprintf(tw, "%v\t%d\t%d\t%d\t%d\t\n", round(x.At), x.Running, x.Runnable, x.Waiting, x.Syscall)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\ngoroutine time by state:")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	var total time.Duration
+	for _, d := range s.StateTime {
+		total += d
+	}
+	for _, state := range []string{stateRunning, stateRunnable, stateWaiting, stateSyscall} {
+		fmt.Fprintf(tw, "%s\t%v\t%s\t\n", strings.ToLower(state), round(s.StateTime[state]), percent(s.StateTime[state], total))
+	}
+	tw.Flush()
+	l := s.SchedLatency
+	fmt.Fprintf(w, "scheduler latency (runnable -> running): n=%d mean=%v p50=%v p99=%v max=%v\n",
+		l.Count, round(l.Mean()), round(l.P50), round(l.P99), round(l.Max))
+
+	fmt.Fprintf(w, "\nGC: %d cycles\n", s.GCCycles)
+	if len(s.Pauses) > 0 {
+		tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "stop-the-world\tcount\ttotal\tmean\tmax\t")
+		for _, p := range s.Pauses {
+			fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\t\n", p.Reason, p.Count, round(p.Total), round(p.Mean()), round(p.Max))
+		}
+		tw.Flush()
+	}
+}
+
+func percent(d, total time.Duration) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+}
+
+// round 把d舍入到3位有效数字左右，使表格更容易阅读。
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond)
+	}
+	return d
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// workload 是在trace下运行的负载。n是goroutine的数量，interval是启动goroutine的间隔。
+type workload struct {
+	doc string
+	run func(n int, interval time.Duration)
+}
+
+var workloads = map[string]workload{
+	"gmp": {
+		doc: "gmp.go: 每隔interval启动一个空转1e6次的goroutine",
+		run: spinEvery,
+	},
+	"burst": {
+		doc: "同时启动n个空转的goroutine，可运行队列中排满了goroutine",
+		run: func(n int, _ time.Duration) { spinEvery(n, 0) },
+	},
+	"alloc": {
+		doc: "同时启动n个不断分配内存的goroutine，触发多次GC",
+		run: allocate,
+	},
+}
+
+func workloadNames() []string {
+	var names []string
+	for name := range workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sink 防止编译器把空转的循环和分配优化掉。
+var sink struct {
+	sync.Mutex
+	n   int
+	buf []byte
+}
+
+func spinEvery(n int, interval time.Duration) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := 0
+			for i := 0; i < 1e6; i++ {
+				a += 1
+			}
+			sink.Lock()
+			sink.n += a
+			sink.Unlock()
+		}()
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+	wg.Wait()
+}
+
+func allocate(n int, _ time.Duration) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf []byte
+			for i := 0; i < 100; i++ {
+				buf = make([]byte, 64<<10)
+			}
+			sink.Lock()
+			sink.buf = buf
+			sink.Unlock()
+		}()
+	}
+	wg.Wait()
+}
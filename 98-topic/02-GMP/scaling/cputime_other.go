@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// cpuTime 在没有getrusage的系统上总是返回0，表格中的CPU时间和并行度没有意义。
+func cpuTime() time.Duration { return 0 }
@@ -0,0 +1,7 @@
+module scaling
+
+go 1.19
+
+require semaphore v0.0.0
+
+replace semaphore => ../../06-struct/empty-struct/semaphore
@@ -0,0 +1,199 @@
+// Scaling 测量几种典型负载在不同的GOMAXPROCS和goroutine数量下的运行时间。
+//
+// gmp.go和01-goroutine.go中的fib/spinner都在说明goroutine可以并行运行，但没有测量过。
+// scaling对每个负载、每个GOMAXPROCS和每个goroutine数量完成同样的工作量，记录：
+//   - 运行时间和CPU时间，CPU时间与运行时间之比是实际的并行度；
+//   - 加速比：与最小的GOMAXPROCS相比，运行时间缩短了多少倍；
+//   - 调度延迟：goroutine从可运行到开始运行等待的时间，来自runtime/metrics。
+//
+// 结果打印成表格，也可以写成CSV以便画图。
+//
+// 用法：
+//
+//	$ scaling [-w fib,mutex] [-procs 1,2,4,8] [-g 1,4,16,64] [-scale 1] [-count 1] [-csv out.csv]
+//
+// CPU密集的fib应该随GOMAXPROCS线性加速，直到P的数量超过CPU核数；
+// pingpong每次只有一对goroutine中的一个能运行，更多的P只会增加跨线程唤醒的开销；
+// mutex的锁内操作是串行的，goroutine越多争用越激烈；du的瓶颈在系统调用。
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("scaling", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var names []string
+	for _, w := range workloads {
+		names = append(names, w.name)
+	}
+	wflag := flags.String("w", strings.Join(names, ","), "comma-separated workloads: "+strings.Join(names, ", "))
+	procsFlag := flags.String("procs", defaultProcs(), "comma-separated GOMAXPROCS values")
+	gFlag := flags.String("g", "1,4,16,64", "comma-separated goroutine counts")
+	scale := flags.Float64("scale", 1, "scale the amount of work")
+	count := flags.Int("count", 1, "run each configuration this many times and keep the fastest")
+	csvFile := flags.String("csv", "", "also write the results as CSV to this file (- for stdout)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	usage := func(format string, args ...any) int {
+		fmt.Fprintf(stderr, "scaling: "+format+"\n", args...)
+		return 2
+	}
+	procs, err := parseInts(*procsFlag)
+	if err != nil {
+		return usage("-procs: %v", err)
+	}
+	sort.Ints(procs) // 加速比以最小的GOMAXPROCS为基准
+	gs, err := parseInts(*gFlag)
+	if err != nil {
+		return usage("-g: %v", err)
+	}
+	if *scale <= 0 || *count <= 0 {
+		return usage("-scale and -count must be positive")
+	}
+	var selected []*workload
+	for _, name := range strings.Split(*wflag, ",") {
+		w := lookup(strings.TrimSpace(name))
+		if w == nil {
+			return usage("unknown workload %q", name)
+		}
+		selected = append(selected, w)
+	}
+
+	var results []Result
+	for _, w := range selected {
+		rs, err := runWorkload(w, procs, gs, *scale, *count)
+		if err != nil {
+			fmt.Fprintf(stderr, "scaling: %s: %v\n", w.name, err)
+			return 1
+		}
+		results = append(results, rs...)
+	}
+
+	printTable(stdout, results)
+	if *csvFile != "" {
+		out := stdout
+		if *csvFile != "-" {
+			f, err := os.Create(*csvFile)
+			if err != nil {
+				fmt.Fprintf(stderr, "scaling: %v\n", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		} else {
+			fmt.Fprintln(out)
+		}
+		if err := writeCSV(out, results); err != nil {
+			fmt.Fprintf(stderr, "scaling: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// defaultProcs 返回1、2、4……直到CPU核数，最后总是包括CPU核数。
+func defaultProcs() string {
+	var ps []string
+	n := runtime.NumCPU()
+	for p := 1; p < n; p *= 2 {
+		ps = append(ps, strconv.Itoa(p))
+	}
+	return strings.Join(append(ps, strconv.Itoa(n)), ",")
+}
+
+func parseInts(s string) ([]int, error) {
+	var ns []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// runWorkload 对procs和gs的每一种组合运行w，返回测量结果。
+// procs按从小到大排列，加速比相对于procs[0]。
+func runWorkload(w *workload, procs, gs []int, scale float64, count int) ([]Result, error) {
+	if w.setup != nil {
+		cleanup, err := w.setup(scale)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+	}
+	var results []Result
+	for _, g := range gs {
+		var base time.Duration // 最小的GOMAXPROCS的运行时间
+		for i, p := range procs {
+			var best Result
+			for c := 0; c < count; c++ {
+				var err error
+				r := measure(p, func() { err = w.run(g, scale) })
+				if err != nil {
+					return nil, err
+				}
+				if c == 0 || r.Wall < best.Wall {
+					best = r
+				}
+			}
+			best.Workload, best.Goroutines = w.name, w.actual(g)
+			if i == 0 {
+				base = best.Wall
+			}
+			if best.Wall > 0 {
+				best.Speedup = float64(base) / float64(best.Wall)
+			}
+			results = append(results, best)
+		}
+	}
+	return results, nil
+}
+
+func printTable(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "workload\tprocs\tgoroutines\twall\tcpu\tcpu/wall\tspeedup\tsched p50\tsched p99\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%.2f\t%.2fx\t%v\t%v\t\n",
+			r.Workload, r.Procs, r.Goroutines, r.Wall.Round(time.Microsecond), r.CPU.Round(time.Microsecond),
+			r.Parallelism(), r.Speedup, r.SchedP50, r.SchedP99)
+	}
+	tw.Flush()
+}
+
+// writeCSV 把结果写成CSV，时间的单位是秒。
+func writeCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"workload", "procs", "goroutines", "wall_s", "cpu_s", "speedup", "sched_p50_s", "sched_p99_s", "goroutines_created"})
+	sec := func(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'g', 6, 64) }
+	for _, r := range results {
+		cw.Write([]string{
+			r.Workload, strconv.Itoa(r.Procs), strconv.Itoa(r.Goroutines),
+			sec(r.Wall), sec(r.CPU), strconv.FormatFloat(r.Speedup, 'f', 3, 64),
+			sec(r.SchedP50), sec(r.SchedP99), strconv.FormatUint(r.Created, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-procs", "1,2", "-g", "1,3", "-scale", "0.01", "-csv", "-"}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	table, data, ok := strings.Cut(stdout.String(), "\n\n")
+	if !ok {
+		t.Fatalf("no CSV after the table:\n%s", stdout.String())
+	}
+	if !strings.Contains(table, "speedup") || !strings.Contains(table, "pingpong") {
+		t.Errorf("table:\n%s", table)
+	}
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个负载有2个GOMAXPROCS乘以2个goroutine数量共4行，再加上表头。
+	if want := 1 + 4*len(workloads); len(records) != want {
+		t.Fatalf("%d CSV records, want %d:\n%s", len(records), want, data)
+	}
+	if records[0][0] != "workload" || records[1][0] != "fib" || records[1][1] != "1" || records[1][5] != "1.000" {
+		t.Errorf("CSV = %q", records[:2])
+	}
+}
+
+// TestBaseline 检查-procs的顺序不影响加速比的基准，以及pingpong报告实际的goroutine数量。
+func TestBaseline(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-w", "pingpong", "-procs", "2,1", "-g", "1,5", "-scale", "0.01", "-csv", "-"}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	_, data, _ := strings.Cut(stdout.String(), "\n\n")
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, r := range records[1:] {
+		got = append(got, []string{r[1], r[2]})
+	}
+	want := [][]string{{"1", "2"}, {"2", "2"}, {"1", "4"}, {"2", "4"}}
+	if len(got) != len(want) {
+		t.Fatalf("procs and goroutines = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i][0] != want[i][0] || got[i][1] != want[i][1] {
+			t.Errorf("procs and goroutines = %q, want %q", got, want)
+			break
+		}
+	}
+	for _, r := range records[1:] {
+		if r[1] == "1" && r[5] != "1.000" {
+			t.Errorf("speedup with GOMAXPROCS=1 is %s, want the baseline 1.000", r[5])
+		}
+	}
+}
+
+func TestBadFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-w", "nope"},
+		{"-procs", "0"},
+		{"-g", "x"},
+		{"-scale", "-1"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	buckets := []float64{0, 1e-6, 1e-3, math.Inf(1)}
+	for _, c := range []struct {
+		counts []uint64
+		p      float64
+		want   time.Duration
+	}{
+		{[]uint64{0, 0, 0}, 0.5, 0},
+		{[]uint64{10, 0, 0}, 0.99, time.Microsecond},
+		{[]uint64{50, 50, 0}, 0.5, time.Microsecond},
+		{[]uint64{50, 50, 0}, 0.51, time.Millisecond},
+		{[]uint64{1, 0, 99}, 0.99, time.Millisecond}, // 最后一个桶的上界是+Inf，返回下界
+	} {
+		if got := quantile(c.counts, buckets, c.p); got != c.want {
+			t.Errorf("quantile(%v, %v) = %v, want %v", c.counts, c.p, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+// Result 是一个负载在一组GOMAXPROCS和goroutine数量下的测量结果。
+type Result struct {
+	Workload   string
+	Procs      int // GOMAXPROCS
+	Goroutines int
+	Wall       time.Duration // 运行时间
+	CPU        time.Duration // 进程消耗的CPU时间（用户态加内核态）
+	// SchedP50和SchedP99 是goroutine从可运行到开始运行的等待时间（调度延迟）的分位数，
+	// 来自runtime/metrics的/sched/latencies:seconds，精度受直方图的桶限制。
+	SchedP50, SchedP99 time.Duration
+	Created            uint64  // 运行期间创建的goroutine数量
+	Speedup            float64 // 与同一负载、同样goroutine数量、最小的GOMAXPROCS相比的加速比
+}
+
+// Parallelism 返回平均同时在运行的线程数，即CPU时间与运行时间的比值。
+func (r Result) Parallelism() float64 {
+	if r.Wall == 0 {
+		return 0
+	}
+	return float64(r.CPU) / float64(r.Wall)
+}
+
+const (
+	metricSchedLatency = "/sched/latencies:seconds"
+	metricCreated      = "/sched/goroutines-created:goroutines"
+)
+
+// snapshot 是测量开始或结束时的状态。
+type snapshot struct {
+	wall    time.Time
+	cpu     time.Duration
+	latency *metrics.Float64Histogram
+	created uint64
+	ok      bool // created是否可用，旧版本的Go没有这个指标
+}
+
+func takeSnapshot() snapshot {
+	samples := []metrics.Sample{{Name: metricSchedLatency}, {Name: metricCreated}}
+	metrics.Read(samples)
+	s := snapshot{wall: time.Now(), cpu: cpuTime()}
+	if samples[0].Value.Kind() == metrics.KindFloat64Histogram {
+		s.latency = samples[0].Value.Float64Histogram()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		s.created, s.ok = samples[1].Value.Uint64(), true
+	}
+	return s
+}
+
+// measure 以procs为GOMAXPROCS运行f，返回测量结果。
+func measure(procs int, f func()) Result {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+	runtime.GC() // 避免上一次运行产生的垃圾在这一次运行中回收
+	before := takeSnapshot()
+	f()
+	after := takeSnapshot()
+
+	r := Result{
+		Procs: procs,
+		Wall:  after.wall.Sub(before.wall),
+		CPU:   after.cpu - before.cpu,
+	}
+	if before.ok && after.ok {
+		r.Created = after.created - before.created
+	}
+	if before.latency != nil && after.latency != nil {
+		counts := make([]uint64, len(after.latency.Counts))
+		for i := range counts {
+			counts[i] = after.latency.Counts[i] - before.latency.Counts[i]
+		}
+		r.SchedP50 = quantile(counts, after.latency.Buckets, 0.5)
+		r.SchedP99 = quantile(counts, after.latency.Buckets, 0.99)
+	}
+	return r
+}
+
+// quantile 返回直方图的p分位数所在桶的上界（最后一个桶的上界是+Inf时返回下界）。
+func quantile(counts []uint64, buckets []float64, p float64) time.Duration {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p * float64(total)))
+	var cum uint64
+	for i, c := range counts {
+		cum += c
+		if cum >= rank {
+			bound := buckets[i+1]
+			if math.IsInf(bound, 1) {
+				bound = buckets[i]
+			}
+			return time.Duration(bound * float64(time.Second))
+		}
+	}
+	return 0
+}
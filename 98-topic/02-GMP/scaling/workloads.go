@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"semaphore"
+)
+
+// workload 是一个可以用不同数量的goroutine完成的固定工作量。
+// 总工作量与goroutine的数量无关，所以运行时间的变化只来自并行和调度。
+type workload struct {
+	name string
+	doc  string
+	// setup 在测量这个负载之前调用一次，返回的函数在测量之后调用。可以为nil。
+	setup func(scale float64) (cleanup func(), err error)
+	// run 用goroutines个goroutine完成工作，scale按比例缩放工作量。
+	run func(goroutines int, scale float64) error
+	// goroutines 返回要求用g个goroutine时run实际启动的数量。为nil时就是g。
+	goroutines func(g int) int
+}
+
+// actual 返回要求用g个goroutine时w实际启动的数量，结果中报告的是这个数。
+func (w *workload) actual(g int) int {
+	if w.goroutines == nil {
+		return g
+	}
+	return w.goroutines(g)
+}
+
+var workloads []*workload
+
+// register 注册一个负载，负载按注册的顺序运行。
+func register(w *workload) {
+	for _, x := range workloads {
+		if x.name == w.name {
+			panic("scaling: duplicate workload " + w.name)
+		}
+	}
+	workloads = append(workloads, w)
+}
+
+func lookup(name string) *workload {
+	for _, w := range workloads {
+		if w.name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+func init() {
+	register(&workload{
+		name: "fib",
+		doc:  "CPU密集：计算fib(27)，任务在goroutine之间分配（01-goroutine.go中的fib）",
+		run:  runFib,
+	})
+	register(&workload{
+		name: "pingpong",
+		doc:  "每对goroutine通过两个无缓冲channel来回传递消息",
+		run:  runPingPong,
+		// goroutine总是成对的：奇数向下取整，至少一对。
+		goroutines: func(g int) int { return 2 * pingPongPairs(g) },
+	})
+	register(&workload{
+		name: "mutex",
+		doc:  "所有goroutine争用同一把互斥锁，锁外有少量计算",
+		run:  runMutex,
+	})
+	du := &duWorkload{}
+	register(&workload{
+		name:  "du",
+		doc:   "并发遍历一棵临时目录树，同时读取目录的goroutine数量由信号量限制（10-goroutine/du）",
+		setup: du.setup,
+		run:   du.run,
+	})
+}
+
+func scaled(n int, scale float64) int {
+	if m := int(float64(n) * scale); m > 0 {
+		return m
+	}
+	return 1
+}
+
+func fib(x int) int {
+	if x < 2 {
+		return x
+	}
+	return fib(x-1) + fib(x-2)
+}
+
+// sink 防止编译器把计算优化掉。
+var sink atomic.Int64
+
+func runFib(goroutines int, scale float64) error {
+	tasks := int64(scaled(256, scale))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 每个goroutine领取下一个任务，直到任务分完，这样快的goroutine不会空等慢的。
+			for next.Add(1) <= tasks {
+				sink.Add(int64(fib(27)))
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// pingPongPairs 返回用goroutines个goroutine时pingpong的对数。
+func pingPongPairs(goroutines int) int {
+	if goroutines < 2 {
+		return 1
+	}
+	return goroutines / 2
+}
+
+func runPingPong(goroutines int, scale float64) error {
+	pairs := pingPongPairs(goroutines)
+	rounds := scaled(200000, scale) / pairs
+	var wg sync.WaitGroup
+	for i := 0; i < pairs; i++ {
+		ping, pong := make(chan int), make(chan int)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				ping <- j
+				<-pong
+			}
+			close(ping)
+		}()
+		go func() {
+			defer wg.Done()
+			for v := range ping {
+				pong <- v
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func runMutex(goroutines int, scale float64) error {
+	ops := scaled(500000, scale)
+	var mu sync.Mutex
+	counter := 0
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		n := ops / goroutines
+		if i < ops%goroutines {
+			n++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := 0
+			for j := 0; j < n; j++ {
+				for k := 0; k < 50; k++ { // 锁外的计算
+					local += k
+				}
+				mu.Lock()
+				counter++
+				mu.Unlock()
+			}
+			sink.Add(int64(local))
+		}()
+	}
+	wg.Wait()
+	if counter != ops {
+		return fmt.Errorf("mutex: counter = %d, want %d", counter, ops)
+	}
+	return nil
+}
+
+// duWorkload 遍历setup创建的目录树。
+type duWorkload struct {
+	root  string
+	files int
+}
+
+func (d *duWorkload) setup(scale float64) (func(), error) {
+	root, err := os.MkdirTemp("", "scaling-du-")
+	if err != nil {
+		return nil, err
+	}
+	d.root, d.files = root, 0
+	fanout := 4
+	if scale >= 1 {
+		fanout = 8
+	}
+	if err := d.mkTree(root, 3, fanout); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	return func() { os.RemoveAll(root) }, nil
+}
+
+// mkTree 创建depth层、每层fanout个子目录的目录树，每个目录中有fanout个小文件。
+func (d *duWorkload) mkTree(dir string, depth, fanout int) error {
+	for i := 0; i < fanout; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", i)), make([]byte, 100), 0o644); err != nil {
+			return err
+		}
+		d.files++
+	}
+	if depth == 0 {
+		return nil
+	}
+	for i := 0; i < fanout; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%d", i))
+		if err := os.Mkdir(sub, 0o755); err != nil {
+			return err
+		}
+		if err := d.mkTree(sub, depth-1, fanout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run 与du中的walker相同：每个子目录由一个新的goroutine遍历，
+// 同时读取目录的goroutine不超过goroutines个。
+func (d *duWorkload) run(goroutines int, _ float64) error {
+	sema := semaphore.NewWeighted(int64(goroutines))
+	var files, errs atomic.Int64
+	var wg sync.WaitGroup
+	var walk func(dir string)
+	walk = func(dir string) {
+		defer wg.Done()
+		sema.Acquire(context.Background(), 1)
+		entries, err := os.ReadDir(dir)
+		sema.Release(1)
+		if err != nil {
+			errs.Add(1)
+			return
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				wg.Add(1)
+				go walk(filepath.Join(dir, e.Name()))
+			} else if _, err := e.Info(); err == nil {
+				files.Add(1)
+			}
+		}
+	}
+	wg.Add(1)
+	walk(d.root)
+	wg.Wait()
+	if errs.Load() > 0 || files.Load() != int64(d.files) {
+		return fmt.Errorf("du: found %d files with %d errors, want %d files", files.Load(), errs.Load(), d.files)
+	}
+	return nil
+}
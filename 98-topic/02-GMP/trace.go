@@ -6,6 +6,7 @@ import (
 	"runtime/trace"
 )
 
+// 调度的统计（P的利用率、调度延迟、GC停顿）见gmptrace目录。
 func main() {
 	f, err := os.Create("trace.out")
 	if err != nil {
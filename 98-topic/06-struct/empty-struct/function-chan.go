@@ -9,6 +9,7 @@ import (
 # 作为通道的信号传输
 使用通道时候，有时候我们只关心是否有数据从通道内传输出来，而不关心数据内容，这时候通道数据相当于一个信号，比如我们实现退出时候。下面例子是基于通道实现的信号量。
 这个例子很废柴，此处只做演示
+真正可用的信号量（带权重、先来先服务、可以被context取消）见semaphore目录。
 **/
 // empty struct
 var emtpy = struct{}{}
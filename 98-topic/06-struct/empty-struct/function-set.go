@@ -3,46 +3,52 @@ package main
 /**
 # 实现集合数据结构
 集合数据结构我们可以使用map来实现：只关心key，不必关心value，我们就可以值设置为空结构体类型变量（或者底层类型是空结构体的变量）。
+元素类型是类型参数T，编译器会检查放入的值的类型，不可比较的类型（比如切片）根本无法实例化Set。
+带集合运算、迭代和JSON的完整版本见set目录，元素是稠密的非负整数时可以用set/intset中的位向量。
+
+set是仓库中唯一不用go 1.19的模块，它的go.mod是go 1.24：Set.All返回的iter.Seq要用于for range需要Go 1.23，
+set/concurrent用maphash.Comparable计算任意可比较的键的哈希值来选择分片，需要Go 1.24。
 **/
 
-type Set struct {
-	items map[interface{}]emptyItem
+type Set[T comparable] struct {
+	items map[T]emptyItem
 }
 
 type emptyItem struct{}
 
 var itemExists = emptyItem{}
 
-func NewSet() *Set {
-	set := &Set{items: make(map[interface{}]emptyItem)}
+func NewSet[T comparable]() *Set[T] {
+	set := &Set[T]{items: make(map[T]emptyItem)}
 	return set
 }
 
 // 添加元素到集合
-func (set *Set) Add(item interface{}) {
+func (set *Set[T]) Add(item T) {
 	set.items[item] = itemExists
 }
 
 // 从集合中删除元素
-func (set *Set) Remove(item interface{}) {
+func (set *Set[T]) Remove(item T) {
 	delete(set.items, item)
 }
 
 // 判断元素是否存在集合中
-func (set *Set) Contains(item interface{}) bool {
+func (set *Set[T]) Contains(item T) bool {
 	_, contains := set.items[item]
 	return contains
 }
 
 // 返回集合大小
-func (set *Set) Size() int {
+func (set *Set[T]) Size() int {
 	return len(set.items)
 }
 
 func main() {
-	set := NewSet()
+	set := NewSet[string]()
 	set.Add("hello")
 	set.Add("world")
+	// set.Add(42) // 编译错误：42不是string
 	println(set.Contains("hello"))
 	println(set.Contains("Hello"))
 	println(set.Size())
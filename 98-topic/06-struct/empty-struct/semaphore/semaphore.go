@@ -0,0 +1,130 @@
+// Package semaphore 提供一个带权重、可以被context取消的信号量。
+//
+// function-chan.go中的Semaphore用带缓冲的channel实现，但它的P/V/Lock/Unlock语义是颠倒的，
+// 一次获取n个资源要循环发送n次，两个同时获取多个资源的goroutine可能各拿到一部分而互相等待，
+// 演示程序也只是靠time.Sleep的时机才能运行。
+//
+// Weighted有固定的容量，Acquire(ctx, n)获取n个许可，Release(n)归还n个许可。
+// 等待者按先来先服务（FIFO）的顺序被满足：如果队首的等待者需要的许可不够，
+// 即使后面需要较少许可的等待者可以被满足，也要排在它后面。这样大的请求不会被源源不断的小请求饿死。
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Weighted 是一个带权重的信号量。
+type Weighted struct {
+	size    int64
+	mu      sync.Mutex
+	cur     int64     // 已经被获取的许可数
+	waiters list.List // *waiter，按到达顺序排列
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{} // 获得许可时关闭
+}
+
+// NewWeighted 返回一个有n个许可的信号量。
+func NewWeighted(n int64) *Weighted {
+	return &Weighted{size: n}
+}
+
+// Acquire 获取n个许可，阻塞直到许可足够或者ctx被取消。
+// 成功时返回nil；失败时返回ctx.Err()，并且不获取任何许可。
+// 如果n超过了信号量的容量，Acquire永远不可能成功，它立即返回错误。n为负数时Acquire会panic。
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	checkN(n)
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.mu.Unlock()
+		return fmt.Errorf("semaphore: acquire %d exceeds size %d", n, s.size)
+	}
+
+	w := &waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// 在ctx被取消之后才获得许可。就当没有获得过，把许可还回去。
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			front := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// 如果队首的等待者放弃了，它后面的等待者可能已经可以被满足了。
+			if front {
+				s.notifyWaiters()
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// TryAcquire 尝试获取n个许可，不会阻塞，返回是否成功。
+// 如果有等待者在排队，即使许可足够也会失败，以保持FIFO的顺序。n为负数时TryAcquire会panic。
+func (s *Weighted) TryAcquire(n int64) bool {
+	checkN(n)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release 归还n个许可。归还的许可比获取的多或者n为负数时Release会panic。
+func (s *Weighted) Release(n int64) {
+	checkN(n)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		s.cur += n
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// checkN 在n为负数时panic：负数的许可会悄悄地破坏计数，
+// 比如Acquire(-1)让可用的许可比容量还多。
+func checkN(n int64) {
+	if n < 0 {
+		panic("semaphore: negative count")
+	}
+}
+
+// notifyWaiters 按顺序满足队首的等待者，直到许可不够为止。调用者必须持有s.mu。
+func (s *Weighted) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*waiter)
+		if s.size-s.cur < w.n {
+			// 不跳过队首去满足后面较小的请求，否则大的请求可能永远得不到满足。
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
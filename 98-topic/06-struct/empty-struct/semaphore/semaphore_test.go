@@ -0,0 +1,223 @@
+package semaphore
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	s := NewWeighted(3)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !s.TryAcquire(1) {
+		t.Fatal("TryAcquire(1) failed with 1 permit left")
+	}
+	if s.TryAcquire(1) {
+		t.Fatal("TryAcquire(1) succeeded with no permits left")
+	}
+	s.Release(3)
+	if !s.TryAcquire(3) {
+		t.Fatal("TryAcquire(3) failed after releasing everything")
+	}
+	s.Release(3)
+	if err := s.Acquire(ctx, 4); err == nil {
+		t.Fatal("Acquire(4) on a semaphore of size 3 succeeded")
+	}
+}
+
+func TestReleaseTooMuch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Release of unheld permits did not panic")
+		}
+	}()
+	NewWeighted(1).Release(1)
+}
+
+func TestNegative(t *testing.T) {
+	s := NewWeighted(1)
+	for name, f := range map[string]func(){
+		"Acquire":    func() { s.Acquire(context.Background(), -1) },
+		"TryAcquire": func() { s.TryAcquire(-1) },
+		"Release":    func() { s.Release(-1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s(-1) did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+	if !s.TryAcquire(1) || s.TryAcquire(1) {
+		t.Error("the count changed after the rejected calls")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s := NewWeighted(1)
+	s.Acquire(context.Background(), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("Acquire = %v, want DeadlineExceeded", err)
+	}
+	s.Release(1)
+	// 放弃的等待者没有拿走许可。
+	if !s.TryAcquire(1) {
+		t.Error("permit lost after a cancelled Acquire")
+	}
+}
+
+// waitQueued 等待信号量的等待队列长度变为n。
+func waitQueued(t *testing.T, s *Weighted, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		l := s.waiters.Len()
+		s.mu.Unlock()
+		if l == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d waiters queued, want %d", l, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestFIFO 检查大的请求不会被后来的小请求饿死。
+func TestFIFO(t *testing.T) {
+	s := NewWeighted(10)
+	ctx := context.Background()
+	s.Acquire(ctx, 9)
+
+	order := make(chan string, 2)
+	go func() {
+		s.Acquire(ctx, 10)
+		order <- "big"
+		s.Release(10)
+	}()
+	waitQueued(t, s, 1)
+	// 还有1个许可，但大的请求在排队，小的请求必须排在它后面。
+	if s.TryAcquire(1) {
+		t.Fatal("TryAcquire(1) jumped the queue")
+	}
+	go func() {
+		s.Acquire(ctx, 1)
+		order <- "small"
+		s.Release(1)
+	}()
+	waitQueued(t, s, 2)
+
+	s.Release(9)
+	if first, second := <-order, <-order; first != "big" || second != "small" {
+		t.Errorf("served %s then %s, want big then small", first, second)
+	}
+}
+
+// TestCancelFront 检查队首的等待者放弃之后，后面的等待者会被唤醒。
+func TestCancelFront(t *testing.T) {
+	s := NewWeighted(10)
+	s.Acquire(context.Background(), 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- s.Acquire(ctx, 10) }()
+	waitQueued(t, s, 1)
+	small := make(chan struct{})
+	go func() {
+		s.Acquire(context.Background(), 5)
+		close(small)
+	}()
+	waitQueued(t, s, 2)
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("big Acquire = %v, want Canceled", err)
+	}
+	select {
+	case <-small:
+	case <-time.After(time.Second):
+		t.Fatal("small waiter not served after the big one gave up")
+	}
+}
+
+// config 是性质测试的随机参数。
+type config struct {
+	Size    uint8
+	Workers uint8
+	Seed    int64
+}
+
+// TestProperties 用testing/quick生成随机的容量、goroutine数量和操作序列，
+// 检查在任何时刻被持有的许可数都不小于0、不超过容量，结束后所有许可都被归还。
+func TestProperties(t *testing.T) {
+	property := func(c config) bool {
+		size := int64(c.Size%16) + 1
+		workers := int(c.Workers%8) + 1
+		s := NewWeighted(size)
+		var held atomic.Int64
+		var bad atomic.Bool
+		check := func() {
+			if h := held.Load(); h < 0 || h > size {
+				bad.Store(true)
+			}
+			s.mu.Lock()
+			if s.cur < 0 || s.cur > size {
+				bad.Store(true)
+			}
+			s.mu.Unlock()
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(rng *rand.Rand) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					n := rng.Int63n(size) + 1
+					switch rng.Intn(3) {
+					case 0:
+						if err := s.Acquire(context.Background(), n); err != nil {
+							bad.Store(true)
+							return
+						}
+					case 1:
+						if !s.TryAcquire(n) {
+							continue
+						}
+					case 2:
+						ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rng.Intn(100))*time.Microsecond)
+						err := s.Acquire(ctx, n)
+						cancel()
+						if err != nil {
+							continue
+						}
+					}
+					held.Add(n)
+					check()
+					held.Add(-n)
+					s.Release(n)
+					check()
+				}
+			}(rand.New(rand.NewSource(c.Seed + int64(w))))
+		}
+		wg.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return !bad.Load() && s.cur == 0 && s.waiters.Len() == 0
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
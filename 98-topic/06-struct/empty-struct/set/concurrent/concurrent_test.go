@@ -0,0 +1,225 @@
+package concurrent
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	for _, shards := range []int{0, 1, 3, 16} {
+		m := NewMap[string, int](shards)
+		if n := len(m.shards); n&(n-1) != 0 || n < shards {
+			t.Errorf("NewMap(%d): %d shards", shards, n)
+		}
+		m.Store("a", 1)
+		if v, ok := m.Load("a"); !ok || v != 1 {
+			t.Errorf("Load(a) = %d, %v", v, ok)
+		}
+		if v, loaded := m.LoadOrStore("a", 2); !loaded || v != 1 {
+			t.Errorf("LoadOrStore(a, 2) = %d, %v", v, loaded)
+		}
+		if v, loaded := m.LoadOrStore("b", 2); loaded || v != 2 {
+			t.Errorf("LoadOrStore(b, 2) = %d, %v", v, loaded)
+		}
+		m.Delete("a")
+		if _, ok := m.Load("a"); ok || m.Len() != 1 {
+			t.Errorf("after Delete: Len() = %d", m.Len())
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	m := NewMap[string, int](4)
+	incr := func(old int, _ bool) (int, bool) { return old + 1, true }
+	if v, ok := m.Compute("n", incr); v != 1 || !ok {
+		t.Errorf("Compute on missing key = %d, %v", v, ok)
+	}
+	if v, ok := m.Compute("n", func(old int, loaded bool) (int, bool) { return 0, false }); v != 0 || ok {
+		t.Errorf("Compute delete = %d, %v", v, ok)
+	}
+	if _, ok := m.Load("n"); ok {
+		t.Error("key still present after Compute returned keep=false")
+	}
+
+	// 并发的Compute不会丢失更新。
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				m.Compute(fmt.Sprint(j%10), incr)
+			}
+		}()
+	}
+	wg.Wait()
+	total := 0
+	m.Range(func(_ string, v int) bool {
+		total += v
+		return true
+	})
+	if total != goroutines*increments {
+		t.Errorf("total = %d, want %d", total, goroutines*increments)
+	}
+}
+
+// TestLoadOrStoreRace 检查多个goroutine同时LoadOrStore同一个键时，只有一个值被保存，
+// 所有goroutine都得到这个值。
+func TestLoadOrStoreRace(t *testing.T) {
+	m := NewMap[int, int](8)
+	var stored atomic.Int32
+	results := make([]int, 16)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, loaded := m.LoadOrStore(42, i)
+			if !loaded {
+				stored.Add(1)
+			}
+			results[i] = v
+		}(i)
+	}
+	wg.Wait()
+	if stored.Load() != 1 {
+		t.Errorf("%d goroutines stored a value", stored.Load())
+	}
+	for _, v := range results {
+		if v != results[0] {
+			t.Errorf("results differ: %v", results)
+			break
+		}
+	}
+}
+
+func TestRangeSnapshot(t *testing.T) {
+	type point struct{ x, y int } // 任何可比较的类型都可以作为键
+	m := NewMap[point, bool](4)
+	for i := 0; i < 100; i++ {
+		m.Store(point{i, -i}, true)
+	}
+	// f中修改m不会死锁，修改也不会反映在这次遍历中。
+	seen := 0
+	m.Range(func(p point, _ bool) bool {
+		seen++
+		m.Delete(p)
+		m.Store(point{p.x + 1000, 0}, true)
+		return true
+	})
+	if seen != 100 || m.Len() != 100 {
+		t.Errorf("seen %d keys, Len() = %d", seen, m.Len())
+	}
+	seen = 0
+	m.Range(func(point, bool) bool {
+		seen++
+		return seen < 10
+	})
+	if seen != 10 {
+		t.Errorf("Range did not stop: seen %d", seen)
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet[int](4)
+	if !s.Add(1) || s.Add(1) || !s.Add(2) {
+		t.Error("Add")
+	}
+	if !s.Contains(1) || s.Contains(3) || s.Len() != 2 {
+		t.Errorf("Contains or Len, Len() = %d", s.Len())
+	}
+	// 用Compute实现“切换”。
+	toggle := func(present bool) bool { return !present }
+	if s.Compute(1, toggle) || !s.Compute(3, toggle) {
+		t.Error("Compute")
+	}
+	s.Remove(2)
+	var got []int
+	s.Range(func(x int) bool {
+		got = append(got, x)
+		return true
+	})
+	if !slices.Equal(got, []int{3}) {
+		t.Errorf("Range yielded %v", got)
+	}
+}
+
+// 下面的基准测试比较Map、sync.Map和用一把读写锁保护的map（即lookup的做法）。
+// 每次操作以readPercent%的概率读，否则写，键从keys个键中随机选取。
+// 分片的优势要用-cpu 8这样的参数在多核的机器上才能看出来。
+const keys = 1 << 12
+
+type benchMap interface {
+	Load(key int) (int, bool)
+	Store(key, value int)
+}
+
+type rwMap struct {
+	mu sync.RWMutex
+	m  map[int]int
+}
+
+func (m *rwMap) Load(key int) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.m[key]
+	return v, ok
+}
+
+func (m *rwMap) Store(key, value int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m[key] = value
+}
+
+type syncMap struct{ m sync.Map }
+
+func (m *syncMap) Load(key int) (int, bool) {
+	v, ok := m.m.Load(key)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func (m *syncMap) Store(key, value int) { m.m.Store(key, value) }
+
+func benchmarkMix(b *testing.B, readPercent int) {
+	for _, c := range []struct {
+		name string
+		new  func() benchMap
+	}{
+		{"RWMutexMap", func() benchMap { return &rwMap{m: make(map[int]int)} }},
+		{"SyncMap", func() benchMap { return new(syncMap) }},
+		{"Sharded", func() benchMap { return NewMap[int, int](0) }},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			m := c.new()
+			for i := 0; i < keys; i++ {
+				m.Store(i, i)
+			}
+			var seed atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(seed.Add(1)))
+				for pb.Next() {
+					k := rng.Intn(keys)
+					if rng.Intn(100) < readPercent {
+						m.Load(k)
+					} else {
+						m.Store(k, k)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkReadHeavy(b *testing.B)  { benchmarkMix(b, 99) }
+func BenchmarkMixed(b *testing.B)      { benchmarkMix(b, 90) }
+func BenchmarkWriteHeavy(b *testing.B) { benchmarkMix(b, 50) }
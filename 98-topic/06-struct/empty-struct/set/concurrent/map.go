@@ -0,0 +1,174 @@
+// Package concurrent 提供并发安全的、分片的Map和Set。
+//
+// set.Set和普通的map都不是并发安全的；07-function/08-defered_function.go中的lookup
+// 用一把互斥锁保护map，所有goroutine都在这把锁上排队，即使它们访问的是不同的键。
+// Map把键按哈希值分配到多个分片，每个分片有自己的读写锁和map，
+// 访问不同分片的goroutine互不阻塞。键的哈希值由hash/maphash.Comparable计算，
+// 所以任何可比较的类型都可以作为键，不需要调用者提供哈希函数。
+//
+// 与sync.Map相比，Map在写多的场景下更快，而且提供了Compute这样的原子的读-改-写操作；
+// 在键基本不变、读远多于写的场景下sync.Map的读不需要加锁，通常更快。
+package concurrent
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+)
+
+// Map 是并发安全的map。必须用NewMap创建。
+type Map[K comparable, V any] struct {
+	seed   maphash.Seed
+	mask   uint64
+	shards []shard[K, V]
+}
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+	_  [64]byte // 避免相邻分片的锁落在同一个缓存行上（伪共享）
+}
+
+// NewMap 返回一个有shards个分片的空Map。shards会被向上取整为2的幂；
+// shards<=0时使用默认值，即GOMAXPROCS的4倍。
+func NewMap[K comparable, V any](shards int) *Map[K, V] {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	m := &Map[K, V]{
+		seed:   maphash.MakeSeed(),
+		mask:   uint64(n - 1),
+		shards: make([]shard[K, V], n),
+	}
+	for i := range m.shards {
+		m.shards[i].m = make(map[K]V)
+	}
+	return m
+}
+
+func (m *Map[K, V]) shard(key K) *shard[K, V] {
+	if m.mask == 0 {
+		return &m.shards[0]
+	}
+	return &m.shards[maphash.Comparable(m.seed, key)&m.mask]
+}
+
+// Load 返回key对应的值，ok表示key是否存在。
+func (m *Map[K, V]) Load(key K) (value V, ok bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok = s.m[key]
+	return value, ok
+}
+
+// Store 把key对应的值设为value。
+func (m *Map[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = value
+}
+
+// LoadOrStore 在key存在时返回已有的值，loaded为true；
+// 否则保存value并返回它，loaded为false。
+func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	// 先用读锁查找：键已经存在是常见的情况，这样不会阻塞其他读者。
+	s.mu.RLock()
+	actual, loaded = s.m[key]
+	s.mu.RUnlock()
+	if loaded {
+		return actual, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 释放读锁之后，其他goroutine可能已经保存了key。
+	if actual, loaded = s.m[key]; loaded {
+		return actual, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+// Delete 删除key。
+func (m *Map[K, V]) Delete(key K) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, key)
+}
+
+// Compute 原子地更新key对应的值：用key现在的值（不存在时是零值和false）调用f，
+// f返回的keep为true时把key的值设为newValue，为false时删除key。
+// 返回值是更新之后的值和key是否存在。
+//
+// f执行时持有key所在分片的写锁，所以它应该尽快返回，而且不能访问m，否则可能死锁。
+func (m *Map[K, V]) Compute(key K, f func(old V, loaded bool) (newValue V, keep bool)) (V, bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, loaded := s.m[key]
+	value, keep := f(old, loaded)
+	if !keep {
+		delete(s.m, key)
+		var zero V
+		return zero, false
+	}
+	s.m[key] = value
+	return value, true
+}
+
+// Range 对m中的每一个键值对调用f，f返回false时停止遍历。顺序是不确定的。
+//
+// Range遍历的是某一时刻的快照：它同时持有所有分片的读锁复制全部键值对，
+// 然后在不持有任何锁的情况下调用f。所以f可以访问和修改m，
+// 但遍历过程中的修改不会反映在这次遍历中。复制快照需要的内存与m的大小成正比。
+func (m *Map[K, V]) Range(f func(key K, value V) bool) {
+	for _, e := range m.snapshot() {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func (m *Map[K, V]) snapshot() []entry[K, V] {
+	// 按固定的顺序获取所有分片的读锁。其他操作最多只持有一个分片的锁，所以不会死锁。
+	for i := range m.shards {
+		m.shards[i].mu.RLock()
+	}
+	n := 0
+	for i := range m.shards {
+		n += len(m.shards[i].m)
+	}
+	entries := make([]entry[K, V], 0, n)
+	for i := range m.shards {
+		for k, v := range m.shards[i].m {
+			entries = append(entries, entry[K, V]{k, v})
+		}
+		m.shards[i].mu.RUnlock()
+	}
+	return entries
+}
+
+// Len 返回m中键的个数。
+// 各个分片依次计数，所以有并发修改时结果不一定对应某一时刻的状态。
+func (m *Map[K, V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
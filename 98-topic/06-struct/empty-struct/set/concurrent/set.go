@@ -0,0 +1,50 @@
+package concurrent
+
+// Set 是并发安全的集合，用值为空结构体的Map实现。必须用NewSet创建。
+type Set[K comparable] struct {
+	m *Map[K, struct{}]
+}
+
+// NewSet 返回一个有shards个分片的空Set，shards的含义与NewMap相同。
+func NewSet[K comparable](shards int) *Set[K] {
+	return &Set[K]{m: NewMap[K, struct{}](shards)}
+}
+
+// Add 把key添加到集合中，返回key之前是否不在集合中。
+// 它相当于Map的LoadOrStore：多个goroutine同时添加同一个key时，只有一个会得到true。
+func (s *Set[K]) Add(key K) (added bool) {
+	_, loaded := s.m.LoadOrStore(key, struct{}{})
+	return !loaded
+}
+
+// Remove 从集合中删除key。
+func (s *Set[K]) Remove(key K) {
+	s.m.Delete(key)
+}
+
+// Contains 判断key是否在集合中。
+func (s *Set[K]) Contains(key K) bool {
+	_, ok := s.m.Load(key)
+	return ok
+}
+
+// Compute 原子地更新key是否在集合中：用key现在是否在集合中调用f，
+// f返回true时key在集合中，返回false时key不在集合中。返回值是f的返回值。
+// 与Map.Compute一样，f不能访问s。
+func (s *Set[K]) Compute(key K, f func(present bool) bool) bool {
+	_, ok := s.m.Compute(key, func(_ struct{}, loaded bool) (struct{}, bool) {
+		return struct{}{}, f(loaded)
+	})
+	return ok
+}
+
+// Range 对集合中的每一个元素调用f，f返回false时停止遍历。
+// 与Map.Range一样，Range遍历的是某一时刻的快照。
+func (s *Set[K]) Range(f func(key K) bool) {
+	s.m.Range(func(key K, _ struct{}) bool { return f(key) })
+}
+
+// Len 返回集合中元素的个数。
+func (s *Set[K]) Len() int {
+	return s.m.Len()
+}
@@ -0,0 +1,3 @@
+module set
+
+go 1.24
@@ -0,0 +1,219 @@
+package intset
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"set"
+)
+
+func TestIntSet(t *testing.T) {
+	var s IntSet // 零值可以直接使用
+	s.Add(1)
+	s.Add(144)
+	s.Add(9)
+	s.Add(9)
+	if got := s.String(); got != "{1 9 144}" {
+		t.Errorf("String() = %q", got)
+	}
+	if !s.Has(9) || s.Has(10) || s.Has(-1) || s.Has(1000) || s.Len() != 3 {
+		t.Errorf("set = %v, Len() = %d", &s, s.Len())
+	}
+	s.Remove(9)
+	s.Remove(1000)
+	s.Remove(-1)
+	if s.Has(9) || s.Len() != 2 {
+		t.Errorf("after Remove: %v", &s)
+	}
+
+	c := s.Copy()
+	c.Add(42)
+	if s.Has(42) || !c.Has(42) {
+		t.Errorf("Copy shares storage: s = %v, c = %v", &s, c)
+	}
+	s.Clear()
+	if s.Len() != 0 || s.String() != "{}" {
+		t.Errorf("after Clear: %v", &s)
+	}
+}
+
+func TestAddNegative(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Add(-1) did not panic")
+		}
+	}()
+	var s IntSet
+	s.Add(-1)
+}
+
+func TestAlgebra(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		op   func(s, t *IntSet)
+		want string
+	}{
+		{"UnionWith", (*IntSet).UnionWith, "{1 3 64 200 300}"},
+		{"IntersectWith", (*IntSet).IntersectWith, "{64}"},
+		{"DifferenceWith", (*IntSet).DifferenceWith, "{1 200}"},
+		{"SymmetricDifferenceWith", (*IntSet).SymmetricDifferenceWith, "{1 3 200 300}"},
+	} {
+		// a比b长，b比a长两种情况都要覆盖。
+		a, b := of(1, 64, 200), of(3, 64, 300)
+		c.op(a, b)
+		if got := a.String(); got != c.want {
+			t.Errorf("%s = %s, want %s", c.name, got, c.want)
+		}
+		if got := b.String(); got != "{3 64 300}" {
+			t.Errorf("%s modified its argument: %s", c.name, got)
+		}
+	}
+}
+
+func of(elems ...int) *IntSet {
+	var s IntSet
+	for _, x := range elems {
+		s.Add(x)
+	}
+	return &s
+}
+
+// TestQuick 用随机的元素构造IntSet和set.Set[int]，检查两者的运算结果相同。
+func TestQuick(t *testing.T) {
+	toSets := func(xs []uint16) (*IntSet, *set.Set[int]) {
+		var s IntSet
+		m := set.New[int]()
+		for _, x := range xs {
+			s.Add(int(x))
+			m.Add(int(x))
+		}
+		return &s, m
+	}
+	same := func(s *IntSet, m *set.Set[int]) bool {
+		return s.Len() == m.Size() && slices.Equal(s.Elems(), set.Sorted(m))
+	}
+	f := func(x, y []uint16) bool {
+		a, ma := toSets(x)
+		b, mb := toSets(y)
+		if !same(a, ma) {
+			return false
+		}
+		for _, c := range []struct {
+			op   func(s, t *IntSet)
+			want *set.Set[int]
+		}{
+			{(*IntSet).UnionWith, ma.Union(mb)},
+			{(*IntSet).IntersectWith, ma.Intersection(mb)},
+			{(*IntSet).DifferenceWith, ma.Difference(mb)},
+			{(*IntSet).SymmetricDifferenceWith, ma.SymmetricDifference(mb)},
+		} {
+			got := a.Copy()
+			c.op(got, b)
+			if !same(got, c.want) {
+				t.Logf("x = %v, y = %v: got %v, want %v", x, y, got, set.Sorted(c.want))
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// 基准测试的全集是[0, universe)，按密度随机选取其中的元素。
+const universe = 1 << 16
+
+var densities = []float64{0.001, 0.01, 0.1, 0.5, 0.9}
+
+func randomElems(density float64, seed int64) []int {
+	rng := rand.New(rand.NewSource(seed))
+	var elems []int
+	for x := 0; x < universe; x++ {
+		if rng.Float64() < density {
+			elems = append(elems, x)
+		}
+	}
+	return elems
+}
+
+// heapBytes 返回build分配的、在GC之后仍然存活的堆内存字节数。
+func heapBytes(build func() any) float64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return float64(after.HeapAlloc) - float64(before.HeapAlloc)
+}
+
+func BenchmarkAdd(b *testing.B) {
+	for _, d := range densities {
+		elems := randomElems(d, 1)
+		b.Run(fmt.Sprintf("density=%g/IntSet", d), func(b *testing.B) {
+			b.ReportMetric(heapBytes(func() any { return of(elems...) })/float64(len(elems)), "B/elem")
+			for i := 0; i < b.N; i++ {
+				of(elems...)
+			}
+		})
+		b.Run(fmt.Sprintf("density=%g/Set", d), func(b *testing.B) {
+			b.ReportMetric(heapBytes(func() any { return set.New(elems...) })/float64(len(elems)), "B/elem")
+			for i := 0; i < b.N; i++ {
+				set.New(elems...)
+			}
+		})
+	}
+}
+
+func BenchmarkHas(b *testing.B) {
+	for _, d := range densities {
+		elems := randomElems(d, 1)
+		s, m := of(elems...), set.New(elems...)
+		b.Run(fmt.Sprintf("density=%g/IntSet", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.Has(i % universe)
+			}
+		})
+		b.Run(fmt.Sprintf("density=%g/Set", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.Contains(i % universe)
+			}
+		})
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	for _, d := range densities {
+		x, y := randomElems(d, 1), randomElems(d, 2)
+		s, t := of(x...), of(y...)
+		m, n := set.New(x...), set.New(y...)
+		b.Run(fmt.Sprintf("density=%g/IntSet", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.Copy().UnionWith(t)
+			}
+		})
+		b.Run(fmt.Sprintf("density=%g/Set", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.Union(n)
+			}
+		})
+	}
+}
+
+func BenchmarkLen(b *testing.B) {
+	for _, d := range densities {
+		elems := randomElems(d, 1)
+		s := of(elems...)
+		b.Run(fmt.Sprintf("density=%g/IntSet", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.Len()
+			}
+		})
+	}
+}
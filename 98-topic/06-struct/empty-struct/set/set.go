@@ -0,0 +1,206 @@
+// Package set 提供泛型的集合类型Set[T]。
+//
+// 与function-set.go中的Set一样，Set[T comparable]用值为空结构体的map实现，
+// 元素类型在编译时确定，不可比较的类型根本无法实例化Set。
+//
+// 除了Add、Remove、Contains和Size之外，Set还提供集合运算（并、交、差、对称差）、
+// 子集和相等的判断、可以用于for range的迭代器，以及与JSON数组之间的转换。
+// 有序的元素用函数Sorted(s)得到排好序的切片：方法不能在T comparable之外再要求cmp.Ordered，
+// 所以Set[T]无法只为有序的T提供一个排序的Slice方法。
+// Set不是并发安全的。
+package set
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// Set 是元素类型为T的集合。零值是空集合，可以直接使用。
+type Set[T comparable] struct {
+	items map[T]struct{}
+}
+
+// New 返回包含items的集合。
+func New[T comparable](items ...T) *Set[T] {
+	s := &Set[T]{items: make(map[T]struct{}, len(items))}
+	s.Add(items...)
+	return s
+}
+
+// Add 把items添加到集合中。
+func (s *Set[T]) Add(items ...T) {
+	if s.items == nil {
+		s.items = make(map[T]struct{}, len(items))
+	}
+	for _, item := range items {
+		s.items[item] = struct{}{}
+	}
+}
+
+// Remove 从集合中删除items。
+func (s *Set[T]) Remove(items ...T) {
+	for _, item := range items {
+		delete(s.items, item)
+	}
+}
+
+// Contains 判断item是否在集合中。
+func (s *Set[T]) Contains(item T) bool {
+	_, ok := s.items[item]
+	return ok
+}
+
+// Size 返回集合中元素的个数。
+func (s *Set[T]) Size() int {
+	return len(s.items)
+}
+
+// All 返回遍历集合中所有元素的迭代器，顺序是不确定的：
+//
+//	for x := range s.All() { ... }
+//
+// 遍历过程中可以删除元素；添加的元素可能被遍历到，也可能不会。
+func (s *Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for item := range s.items {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Slice 以不确定的顺序返回集合中的所有元素。
+func (s *Set[T]) Slice() []T {
+	items := make([]T, 0, len(s.items))
+	for item := range s.items {
+		items = append(items, item)
+	}
+	return items
+}
+
+// Sorted 按从小到大的顺序返回集合中的所有元素。
+// 它是一个函数而不是方法，因为只有元素类型是有序的才能排序，而方法不能增加类型约束。
+func Sorted[T cmp.Ordered](s *Set[T]) []T {
+	items := s.Slice()
+	slices.Sort(items)
+	return items
+}
+
+// Clone 返回集合的副本。
+func (s *Set[T]) Clone() *Set[T] {
+	c := &Set[T]{items: make(map[T]struct{}, len(s.items))}
+	for item := range s.items {
+		c.items[item] = struct{}{}
+	}
+	return c
+}
+
+// Union 返回s和other的并集。
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	u := s.Clone()
+	for item := range other.items {
+		u.items[item] = struct{}{}
+	}
+	return u
+}
+
+// Intersection 返回s和other的交集。
+func (s *Set[T]) Intersection(other *Set[T]) *Set[T] {
+	small, large := s, other
+	if small.Size() > large.Size() {
+		small, large = large, small
+	}
+	r := New[T]()
+	for item := range small.items {
+		if large.Contains(item) {
+			r.items[item] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Difference 返回在s中但不在other中的元素。
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	r := New[T]()
+	for item := range s.items {
+		if !other.Contains(item) {
+			r.items[item] = struct{}{}
+		}
+	}
+	return r
+}
+
+// SymmetricDifference 返回只在s和other其中一个中的元素。
+func (s *Set[T]) SymmetricDifference(other *Set[T]) *Set[T] {
+	r := s.Difference(other)
+	for item := range other.items {
+		if !s.Contains(item) {
+			r.items[item] = struct{}{}
+		}
+	}
+	return r
+}
+
+// IsSubset 判断s是否是other的子集。
+func (s *Set[T]) IsSubset(other *Set[T]) bool {
+	if s.Size() > other.Size() {
+		return false
+	}
+	for item := range s.items {
+		if !other.Contains(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal 判断s和other是否包含相同的元素。
+func (s *Set[T]) Equal(other *Set[T]) bool {
+	return s.Size() == other.Size() && s.IsSubset(other)
+}
+
+// String 以{a b c}的形式返回集合，元素的顺序是不确定的。
+func (s *Set[T]) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	sep := ""
+	for item := range s.items {
+		fmt.Fprintf(&b, "%s%v", sep, item)
+		sep = " "
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// MarshalJSON 把集合编码为JSON数组，元素的顺序是不确定的。
+// 元素逐个编码：json.Marshal会把[]byte编码成base64字符串，Set[byte]也必须是数组。
+func (s *Set[T]) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	for item := range s.items {
+		if len(b) > 1 {
+			b = append(b, ',')
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, data...)
+	}
+	return append(b, ']'), nil
+}
+
+// UnmarshalJSON 从JSON数组解码集合，重复的元素只保留一个。集合中原有的元素被丢弃。
+func (s *Set[T]) UnmarshalJSON(data []byte) error {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	s.items = make(map[T]struct{}, len(items))
+	s.Add(items...)
+	return nil
+}
@@ -0,0 +1,224 @@
+package set
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSet(t *testing.T) {
+	var s Set[string] // 零值可以直接使用
+	s.Add("hello", "world", "hello")
+	if !s.Contains("hello") || s.Contains("Hello") || s.Size() != 2 {
+		t.Errorf("set = %v", &s)
+	}
+	s.Remove("hello", "missing")
+	if s.Contains("hello") || s.Size() != 1 {
+		t.Errorf("after Remove: %v", &s)
+	}
+	if got := s.String(); got != "{world}" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestAlgebra(t *testing.T) {
+	a := New(1, 2, 3, 4)
+	b := New(3, 4, 5)
+	for _, c := range []struct {
+		name string
+		got  *Set[int]
+		want []int
+	}{
+		{"Union", a.Union(b), []int{1, 2, 3, 4, 5}},
+		{"Intersection", a.Intersection(b), []int{3, 4}},
+		{"Difference", a.Difference(b), []int{1, 2}},
+		{"SymmetricDifference", a.SymmetricDifference(b), []int{1, 2, 5}},
+	} {
+		if got := Sorted(c.got); !slices.Equal(got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, got, c.want)
+		}
+	}
+	// 运算不修改参数。
+	if !a.Equal(New(1, 2, 3, 4)) || !b.Equal(New(5, 4, 3)) {
+		t.Errorf("operands modified: a = %v, b = %v", a, b)
+	}
+	if !New(3, 4).IsSubset(a) || b.IsSubset(a) || !New[int]().IsSubset(b) {
+		t.Error("IsSubset")
+	}
+	if a.Equal(b) || !a.Equal(a.Clone()) {
+		t.Error("Equal")
+	}
+}
+
+func TestAll(t *testing.T) {
+	s := New("a", "b", "c", "d")
+	var got []string
+	for x := range s.All() {
+		got = append(got, x)
+		s.Remove(x) // 遍历时删除是安全的
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"a", "b", "c", "d"}) || s.Size() != 0 {
+		t.Errorf("All() yielded %v, %d left", got, s.Size())
+	}
+	n := 0
+	for range New(1, 2, 3).All() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("break did not stop the iteration")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type doc struct {
+		Tags *Set[string] `json:"tags"`
+	}
+	var d doc
+	if err := json.Unmarshal([]byte(`{"tags":["go","set","go"]}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if got := Sorted(d.Tags); !slices.Equal(got, []string{"go", "set"}) {
+		t.Errorf("decoded %v", got)
+	}
+	data, err := json.Marshal(New(7))
+	if err != nil || string(data) != "[7]" {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+	// Set[byte]也编码为数组，而不是base64字符串。
+	data, err = json.Marshal(New[byte](1, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var elems []int
+	if err := json.Unmarshal(data, &elems); err != nil {
+		t.Errorf("Marshal(Set[byte]) = %s: %v", data, err)
+	}
+	if slices.Sort(elems); !slices.Equal(elems, []int{1, 2, 3}) {
+		t.Errorf("Marshal(Set[byte]) = %s", data)
+	}
+	if data, _ := json.Marshal(New[byte]()); string(data) != "[]" {
+		t.Errorf("Marshal(empty) = %s", data)
+	}
+	var s Set[int]
+	if err := json.Unmarshal([]byte(`{"not":"an array"}`), &s); err == nil {
+		t.Error("decoding an object succeeded")
+	}
+}
+
+// ref 是用于比较的参考实现：元素是字节，集合是一个[256]bool。
+type ref [256]bool
+
+func newRef(data []byte) *ref {
+	var r ref
+	for _, b := range data {
+		r[b] = true
+	}
+	return &r
+}
+
+func (r *ref) elems() []byte {
+	var out []byte
+	for i, ok := range r {
+		if ok {
+			out = append(out, byte(i))
+		}
+	}
+	return out
+}
+
+func (r *ref) op(other *ref, f func(a, b bool) bool) *ref {
+	var out ref
+	for i := range r {
+		out[i] = f(r[i], other[i])
+	}
+	return &out
+}
+
+// FuzzAlgebra 用随机的两个字节序列构造集合，把所有运算的结果与参考实现比较。
+func FuzzAlgebra(f *testing.F) {
+	f.Add([]byte{1, 2, 3}, []byte{2, 3, 4})
+	f.Add([]byte{}, []byte{0, 255})
+	f.Add([]byte{9, 9, 9}, []byte{9})
+	f.Fuzz(func(t *testing.T, x, y []byte) {
+		a, b := New(x...), New(y...)
+		ra, rb := newRef(x), newRef(y)
+		check := func(name string, got *Set[byte], want *ref) {
+			if g, w := Sorted(got), want.elems(); !slices.Equal(g, w) {
+				t.Errorf("%s(%v, %v) = %v, want %v", name, x, y, g, w)
+			}
+		}
+		check("Union", a.Union(b), ra.op(rb, func(p, q bool) bool { return p || q }))
+		check("Intersection", a.Intersection(b), ra.op(rb, func(p, q bool) bool { return p && q }))
+		check("Difference", a.Difference(b), ra.op(rb, func(p, q bool) bool { return p && !q }))
+		check("SymmetricDifference", a.SymmetricDifference(b), ra.op(rb, func(p, q bool) bool { return p != q }))
+
+		subset := true
+		for i := range ra {
+			if ra[i] && !rb[i] {
+				subset = false
+			}
+		}
+		if a.IsSubset(b) != subset {
+			t.Errorf("IsSubset(%v, %v) = %v", x, y, !subset)
+		}
+		if a.Equal(b) != (*ra == *rb) {
+			t.Errorf("Equal(%v, %v) = %v", x, y, !(*ra == *rb))
+		}
+
+		// JSON往返之后集合不变。
+		data, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Set[byte]
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(a) {
+			t.Errorf("JSON round trip of %v gave %v", a, &back)
+		}
+	})
+}
+
+// FuzzStrings 用随机的字符串构造Set[string]，检查运算的基本性质和JSON往返。
+// FuzzAlgebra只覆盖Set[byte]，而JSON对字符串元素的编码（转义、非法UTF-8）完全不同。
+func FuzzStrings(f *testing.F) {
+	f.Add("a,b,c", "b,c,d")
+	f.Add("", ",")
+	f.Add("\"quoted\",<html>", "\xff,\u2028")
+	f.Fuzz(func(t *testing.T, x, y string) {
+		xs, ys := strings.Split(x, ","), strings.Split(y, ",")
+		a, b := New(xs...), New(ys...)
+		for _, e := range xs {
+			if !a.Contains(e) || !a.Union(b).Contains(e) || a.Difference(b).Contains(e) == b.Contains(e) {
+				t.Errorf("element %q of %v: wrong membership", e, a)
+			}
+		}
+		if got := a.Intersection(b).Size() + a.SymmetricDifference(b).Size(); got != a.Union(b).Size() {
+			t.Errorf("|a∩b| + |a△b| = %d, |a∪b| = %d", got, a.Union(b).Size())
+		}
+
+		// json.Marshal把非法的UTF-8替换为U+FFFD，所以只比较合法的字符串。
+		valid := New[string]()
+		for e := range a.All() {
+			if utf8.ValidString(e) {
+				valid.Add(e)
+			}
+		}
+		data, err := json.Marshal(valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Set[string]
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(valid) {
+			t.Errorf("JSON round trip of %v gave %v", valid, &back)
+		}
+	})
+}